@@ -1,12 +1,17 @@
 package cssc
 
 import (
+	"errors"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/samsarahq/go/oops"
 	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/internal/browsers"
 	"github.com/stephen/cssc/internal/logging"
 	"github.com/stephen/cssc/internal/parser"
 	"github.com/stephen/cssc/internal/printer"
@@ -31,18 +36,78 @@ type Options struct {
 	// Resolver is a path resolver. If not specified, the default node-style
 	// resolver will be used.
 	Resolver Resolver
+
+	// Outdir is the directory to write outputs to. Output paths mirror the input
+	// paths relative to Outbase. If neither Outdir nor Outfile is set, outputs are
+	// keyed by their absolute input path.
+	Outdir string
+
+	// Outbase is the directory that output paths are relative to when Outdir is set.
+	// If not specified, the lowest common ancestor directory of all entries is used.
+	Outbase string
+
+	// Outfile is the output path when the compilation has a single output. It
+	// cannot be used with Outdir.
+	Outfile string
+
+	// Minify shortens the output, e.g. by writing 0.50 as .5 and #ffffff as #fff, and
+	// removes rules that are empty.
+	Minify bool
+
+	// Optimize merges rules and declarations where doing so doesn't change which declarations
+	// apply, e.g. by merging adjacent rules with the same selectors and collapsing margin-top,
+	// margin-right, margin-bottom, and margin-left into margin.
+	Optimize bool
+
+	// Modules, if non-nil, compiles every file as a CSS Module, so that its class names, IDs,
+	// and @keyframes names are local to it. The generated names are in Result.Exports.
+	Modules *ModulesOptions
+
+	// SourceMap controls source map generation. By default, no source maps are generated.
+	SourceMap SourceMap
+
+	// SourcesContent controls whether or not source maps contain the original sources.
+	SourcesContent SourcesContent
+
+	// Stdin is an additional entry whose content is passed in directly instead of
+	// being read from disk.
+	Stdin *StdinOptions
+}
+
+// StdinOptions is an entry that is passed in as a string.
+type StdinOptions struct {
+	// Contents is the content of the entry.
+	Contents string
+
+	// ResolveDir is the directory that imports are resolved from. If not
+	// specified, the working directory is used.
+	ResolveDir string
+
+	// Sourcefile is the name of the entry in errors, source maps, and output
+	// paths. If not specified, it is "<stdin>". It must be set when Outdir is.
+	Sourcefile string
 }
 
-func newCompilation(opts Options) *compilation {
+func newCompilation(opts Options, cache *buildCache) *compilation {
 	c := &compilation{
 		sources:        make(map[string]int),
 		sourcesByIndex: make(map[int]*sources.Source),
 		outputsByIndex: make(map[int]struct{}),
 		astsByIndex:    make(map[int]*ast.Stylesheet),
+		importsByIndex: make(map[int][]resolvedImport),
+		imports:        make(map[*ast.AtRule]*ast.Stylesheet),
+		importSources:  make(map[*ast.Stylesheet]*sources.Source),
+		exportsByIndex: make(map[int]map[string]string),
+		modulesPending: make(map[int]bool),
+		reported:       make(map[Diagnostic]bool),
 		result:         newResult(),
 		reporter:       logging.DefaultReporter,
 		transforms:     opts.Transforms,
-		resolver:       &resolver.NodeResolver{},
+		minify:         opts.Minify,
+		optimize:       opts.Optimize,
+		modules:        opts.Modules,
+		resolver:       defaultResolver(),
+		cache:          cache,
 	}
 
 	if opts.Reporter != nil {
@@ -68,19 +133,58 @@ type compilation struct {
 	sourcesByIndexMu sync.RWMutex
 	sourcesByIndex   map[int]*sources.Source
 
+	// astsByIndex is the parsed stylesheet for each source. A source is claimed by
+	// the first caller to parse it, so its entry may be nil while it's being parsed.
 	astsByIndexMu sync.RWMutex
 	astsByIndex   map[int]*ast.Stylesheet
 
+	// importsByIndex is the list of resolved imports for each source, in source order.
+	importsByIndex map[int][]resolvedImport
+
+	// parsing tracks all files that are being parsed.
+	parsing errgroup.Group
+
 	// outputsByIndex is the set of sources to write outputs for.
+	outputsMu      sync.Mutex
 	outputsByIndex map[int]struct{}
 
+	// imports is the stylesheet for every resolved @import in the compilation. It
+	// does not contain imports that would create a cycle. It is built by linkImports
+	// once all files are parsed.
+	imports       map[*ast.AtRule]*ast.Stylesheet
+	importSources map[*ast.Stylesheet]*sources.Source
+
+	// exportsByIndex is the exports of each CSS Module that another module depends on, and
+	// modulesPending is the set of modules whose exports are being computed. They are only used
+	// once all files are parsed, when transforms run one at a time.
+	exportsByIndex map[int]map[string]string
+	modulesPending map[int]bool
+
 	result *Result
 
+	// reported is the set of diagnostics that have been reported, so that a problem in a file
+	// that is used more than once is only reported once.
+	reported map[Diagnostic]bool
+
 	reporter Reporter
 
 	transforms transforms.Options
+	minify     bool
+	optimize   bool
+	modules    *ModulesOptions
+
+	// modulesRoot is the absolute directory that CSS Modules paths are hashed relative to.
+	modulesRoot string
 
 	resolver Resolver
+
+	// cache is the cache from previous compilations when watching. It is nil otherwise.
+	cache *buildCache
+}
+
+// defaultResolver returns the resolver to use if one is not specified in Options.
+func defaultResolver() Resolver {
+	return &resolver.NodeResolver{}
 }
 
 // addSource will read in a path and assign it a source index. If
@@ -88,7 +192,7 @@ type compilation struct {
 func (c *compilation) addSource(path string) (int, error) {
 	abs, err := filepath.Abs(path)
 	if err != nil {
-		return 0, oops.Wrapf(err, "failed to make path absolute: %s", path)
+		return 0, logging.WithCode(logging.CodeRead, oops.Wrapf(err, "failed to make path absolute: %s", path))
 	}
 
 	c.sourcesMu.RLock()
@@ -98,164 +202,530 @@ func (c *compilation) addSource(path string) (int, error) {
 	}
 	c.sourcesMu.RUnlock()
 
+	if c.cache != nil {
+		source, err := c.cache.readSource(abs)
+		if err != nil {
+			return 0, logging.WithCode(logging.CodeRead, err)
+		}
+		return c.registerSource(source), nil
+	}
+
 	in, err := ioutil.ReadFile(abs)
 	if err != nil {
-		return 0, oops.Wrapf(err, "failed to read file: %s", path)
+		return 0, logging.WithCode(logging.CodeRead, oops.Wrapf(err, "failed to read file: %s", path))
 	}
 
-	source := &sources.Source{
+	return c.registerSource(&sources.Source{
 		Content: string(in),
 		Path:    abs,
-	}
+	}), nil
+}
+
+// registerSource assigns a source index to a source that has been read in. If
+// a source with the same path has already been registered, its index is returned.
+func (c *compilation) registerSource(source *sources.Source) int {
+	abs := source.Path
 
 	c.sourcesMu.Lock()
+	if i, ok := c.sources[abs]; ok {
+		// Another caller loaded the same file in the meantime.
+		c.sourcesMu.Unlock()
+		return i
+	}
 	i := c.nextIndex
+	c.nextIndex++
 	c.sources[abs] = i
-	c.sourcesMu.Unlock()
 
 	c.sourcesByIndexMu.Lock()
 	c.sourcesByIndex[i] = source
 	c.sourcesByIndexMu.Unlock()
+	c.sourcesMu.Unlock()
 
-	c.nextIndex++
-	return i, nil
+	return i
 }
 
 func newResult() *Result {
 	return &Result{
-		Files: make(map[string]string),
+		Files:   make(map[string]string),
+		Exports: make(map[string]map[string]string),
 	}
 }
 
 // Result is the results of a compilation.
 type Result struct {
-	mu    sync.Mutex
+	mu sync.Mutex
+
+	// Files is the content of each output, keyed by absolute output path.
 	Files map[string]string
+
+	// Exports is the generated name of each local name in each CSS Module, keyed by absolute
+	// output path. It is only filled in if Options.Modules is set.
+	Exports map[string]map[string]string
+
+	// Inputs is the absolute path of every file that was read during the compilation,
+	// including imported files and CSS Modules dependencies, sorted. Stdin is not included.
+	Inputs []string
+
+	// Errors is every error reported during the compilation, sorted by file and position.
+	Errors []*Diagnostic
+
+	// Warnings is every warning reported during the compilation, sorted by file and position.
+	Warnings []*Diagnostic
 }
 
+// addError reports an error or warning and adds it to the result.
 func (c *compilation) addError(err error) {
+	d := newDiagnostic(err)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.reporter.AddError(err)
+	key := *d
+	key.err = nil
+	if c.reported[key] {
+		return
+	}
+	c.reported[key] = true
+
+	if d.Severity == SeverityWarning {
+		c.result.Warnings = append(c.result.Warnings, d)
+		if w, ok := c.reporter.(WarningReporter); ok {
+			w.AddWarning(d)
+			return
+		}
+	} else {
+		c.result.Errors = append(c.result.Errors, d)
+	}
+	c.reporter.AddError(d)
+}
+
+// sortDiagnostics sorts diagnostics by file and position.
+func sortDiagnostics(diagnostics []*Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].File != diagnostics[j].File {
+			return diagnostics[i].File < diagnostics[j].File
+		}
+		return diagnostics[i].Start < diagnostics[j].Start
+	})
+}
+
+// reporterFunc is an adapter to use a function as a Reporter.
+type reporterFunc func(error)
+
+// AddError implements Reporter.
+func (f reporterFunc) AddError(err error) {
+	f(err)
+}
+
+// resolvedImport is an @import whose target has been added to the compilation.
+type resolvedImport struct {
+	atRule *ast.AtRule
+	index  int
 }
 
 // parseFile assigns the file a source index and parses the source. It also
-// looks at imported files and adds them to the compilation. hasOutput should
-// be called if the file should be included in compilation output.
+// looks at imported files and adds them to the compilation. Imported files are
+// parsed concurrently and tracked by c.parsing. hasOutput should be called if
+// the file should be included in compilation output.
 //
-// parseFile also runs the last transformation pass on the output. Note that we
-// don't make this function print the output as well so that we can make the current
-// file available to any callers as a dependency.
-func (c *compilation) parseFile(file string, hasOutput bool) *ast.Stylesheet {
+// Files are transformed once the whole import graph has been parsed, so that
+// import cycles can be detected and inlined imports can be transformed as part
+// of the file that imports them.
+func (c *compilation) parseFile(file string, hasOutput bool) {
 	// Assign the file a source index.
 	idx, err := c.addSource(file)
 	if err != nil {
 		c.addError(err)
-		return nil
+		return
 	}
 
 	if hasOutput {
+		c.outputsMu.Lock()
 		c.outputsByIndex[idx] = struct{}{}
+		c.outputsMu.Unlock()
 	}
 
-	c.astsByIndexMu.RLock()
-	if ss, ok := c.astsByIndex[idx]; ok {
-		c.astsByIndexMu.RUnlock()
-		return ss
+	// Claim the file so that it's only parsed once.
+	c.astsByIndexMu.Lock()
+	if _, ok := c.astsByIndex[idx]; ok {
+		c.astsByIndexMu.Unlock()
+		return
 	}
-	c.astsByIndexMu.RUnlock()
+	c.astsByIndex[idx] = nil
+	c.astsByIndexMu.Unlock()
 
-	c.sourcesByIndexMu.RLock()
-	source := c.sourcesByIndex[idx]
-	c.sourcesByIndexMu.RUnlock()
-	ss, err := parser.Parse(source)
-	if err != nil {
-		c.addError(err)
-		return nil
+	source := c.source(idx)
+	var ss *ast.Stylesheet
+	if c.cache != nil {
+		ss = c.cache.ast(source.Path)
+	}
+	if ss == nil {
+		ss, err = parser.Parse(source)
+		if errs, ok := err.(parser.Errors); ok {
+			// Parsing recovers from errors, so the rest of the stylesheet is still used.
+			for _, err := range errs {
+				c.addError(logging.WithCode(logging.CodeSyntax, err))
+			}
+		} else if c.cache != nil {
+			// Stylesheets with errors aren't cached, so that their errors are reported again.
+			c.cache.setAST(source.Path, ss)
+		}
 	}
 
 	// Immediately look at the imports from the file and feed those dependencies
-	// into parseFile as well. If we're set to inline imports, then we'll use
-	// collect those dependency ASTs to let the transformer replace them.
-	var mu sync.Mutex
-	replacements := make(map[*ast.AtRule]*ast.Stylesheet)
+	// into parseFile as well.
+	imports := make([]resolvedImport, len(ss.Imports))
 	var wg errgroup.Group
-	for _, imp := range ss.Imports {
+	for i, imp := range ss.Imports {
+		i, imp := i, imp
 		wg.Go(func() error {
 			rel, err := c.resolver.Resolve(imp.Value, filepath.Dir(source.Path))
+			if errors.Is(err, ErrExternal) {
+				// The @import rule is kept in the output.
+				return nil
+			}
+			if err != nil {
+				if imp.AtRule != nil {
+					err = logging.LocationErrorf(source, imp.AtRule.Span, "%w", err)
+				}
+				c.addError(logging.WithCode(logging.CodeUnresolvedImport, err))
+				return nil
+			}
+
+			importedIdx, err := c.addSource(rel)
 			if err != nil {
 				c.addError(err)
 				return nil
 			}
+			imports[i] = resolvedImport{imp.AtRule, importedIdx}
 
 			// If import follow is on, then every referenced file makes it to the output.
-			imported := c.parseFile(rel, c.transforms.ImportRules == transforms.ImportRulesFollow)
-
-			mu.Lock()
-			defer mu.Unlock()
-			if imported != nil {
-				replacements[imp.AtRule] = imported
-			}
+			c.parsing.Go(func() error {
+				c.parseFile(rel, c.transforms.ImportRules == transforms.ImportRulesFollow)
+				return nil
+			})
 			return nil
 		})
 	}
 	wg.Wait()
 
+	// Other CSS Modules that the file uses are added to the compilation, but not to the output.
+	// Problems resolving them are reported where they are used.
+	if c.modules != nil {
+		for _, specifier := range transformer.ModuleDependencies(ss) {
+			rel, err := c.resolver.Resolve(specifier, filepath.Dir(source.Path))
+			if err != nil {
+				continue
+			}
+			c.parsing.Go(func() error {
+				c.parseFile(rel, false)
+				return nil
+			})
+		}
+	}
+
+	c.astsByIndexMu.Lock()
+	c.astsByIndex[idx] = ss
+	for _, imp := range imports {
+		if imp.atRule != nil {
+			c.importsByIndex[idx] = append(c.importsByIndex[idx], imp)
+		}
+	}
+	c.astsByIndexMu.Unlock()
+}
+
+// linkImports walks the import graph from each output and fills in c.imports. Imports
+// that would create a cycle are reported with the full cycle and removed.
+func (c *compilation) linkImports(outputs []int) {
+	visited := make(map[int]bool)
+	var chain []int
+
+	var visit func(idx int)
+	visit = func(idx int) {
+		chain = append(chain, idx)
+		for _, imp := range c.importsByIndex[idx] {
+			if i := indexOf(chain, imp.index); i != -1 {
+				cycle := make([]string, 0, len(chain)-i+1)
+				for _, j := range append(chain[i:len(chain):len(chain)], imp.index) {
+					cycle = append(cycle, displayPath(c.source(j).Path))
+				}
+
+				// Browsers handle cycles in @import rules that are left for them, so a cycle
+				// is only an error when imports are inlined. The import is removed then, since
+				// its path is relative to a file that it would be inlined into.
+				switch c.transforms.ImportRules {
+				case transforms.ImportRulesInline:
+					c.addError(logging.WithCode(logging.CodeImportCycle, logging.LocationErrorf(c.source(idx), imp.atRule.Span, "import cycle: %s", strings.Join(cycle, " -> "))))
+					c.imports[imp.atRule] = nil
+
+				case transforms.ImportRulesFollow:
+					c.addError(logging.WithCode(logging.CodeImportCycle, logging.LocationWarnf(c.source(idx), imp.atRule.Span, "import cycle: %s", strings.Join(cycle, " -> "))))
+				}
+				continue
+			}
+
+			imported := c.astsByIndex[imp.index]
+			if imported == nil {
+				// The imported file could not be parsed.
+				continue
+			}
+			c.imports[imp.atRule] = imported
+			c.importSources[imported] = c.source(imp.index)
+
+			if !visited[imp.index] {
+				visit(imp.index)
+			}
+		}
+		chain = chain[:len(chain)-1]
+		visited[idx] = true
+	}
+
+	for _, idx := range outputs {
+		if !visited[idx] {
+			visit(idx)
+		}
+	}
+}
+
+func indexOf(indices []int, idx int) int {
+	for i, v := range indices {
+		if v == idx {
+			return i
+		}
+	}
+	return -1
+}
+
+// source returns the source for a source index.
+func (c *compilation) source(idx int) *sources.Source {
+	c.sourcesByIndexMu.RLock()
+	defer c.sourcesByIndexMu.RUnlock()
+	return c.sourcesByIndex[idx]
+}
+
+// displayPath returns path relative to the working directory, if possible.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
+
+// transformFile runs the transforms on a parsed file. When imports are inlined,
+// the imported files are transformed along with it, and nodeSources is the source
+// of the inlined nodes. If the file is compiled as a CSS Module, exports is the
+// generated name of each local name.
+func (c *compilation) transformFile(idx int) (ss *ast.Stylesheet, nodeSources map[ast.Node]*sources.Source, exports map[string]string) {
+	source, ss := c.source(idx), c.astsByIndex[idx]
+	if source == nil || ss == nil {
+		// Skip attempting to transform if there was a problem reading/parsing this file
+		// in the first place.
+		return nil, nil, nil
+	}
+
 	opts := transformer.Options{
 		Options:        c.transforms,
 		OriginalSource: source,
-		Reporter:       c.reporter,
+		Minify:         c.minify,
+		Optimize:       c.optimize,
+		Reporter: reporterFunc(func(err error) {
+			c.addError(logging.WithCode(logging.CodeTransform, err))
+		}),
 	}
 
 	if c.transforms.ImportRules == transforms.ImportRulesInline {
-		opts.ImportReplacements = replacements
+		opts.ImportReplacements = c.imports
+		opts.ImportSources = c.importSources
+		opts.NodeSources = make(map[ast.Node]*sources.Source)
 	}
 
-	ss = transformer.Transform(ss, opts)
-	c.astsByIndexMu.Lock()
-	c.astsByIndex[idx] = ss
-	c.astsByIndexMu.Unlock()
-	return ss
+	if c.modules != nil {
+		opts.Modules = c.transformerModules(idx)
+		exports = opts.Modules.Exports
+
+		// Mark the file as pending so that modules that depend on it can't be used from it.
+		c.modulesPending[idx] = true
+		defer delete(c.modulesPending, idx)
+	}
+
+	return transformer.Transform(ss, opts), opts.NodeSources, exports
+}
+
+// addStdin registers the stdin entry as a source and returns its path.
+func (c *compilation) addStdin(stdin *StdinOptions) (string, error) {
+	name := stdin.Sourcefile
+	if name == "" {
+		name = "<stdin>"
+	}
+
+	path, err := filepath.Abs(filepath.Join(stdin.ResolveDir, name))
+	if err != nil {
+		return "", oops.Wrapf(err, "failed to make path absolute: %s", name)
+	}
+
+	c.registerSource(&sources.Source{
+		Content: stdin.Contents,
+		Path:    path,
+	})
+	return path, nil
 }
 
 // Compile runs a compilation with the specified Options.
 func Compile(opts Options) *Result {
-	c := newCompilation(opts)
+	return compile(opts, nil)
+}
 
-	var wg errgroup.Group
+// compile runs a compilation, reusing files from cache if it is non-nil.
+func compile(opts Options, cache *buildCache) *Result {
+	c := newCompilation(opts, cache)
+	defer func() {
+		sortDiagnostics(c.result.Errors)
+		sortDiagnostics(c.result.Warnings)
+	}()
+
+	entries := opts.Entry
+	var stdinPath string
+	if opts.Stdin != nil {
+		path, err := c.addStdin(opts.Stdin)
+		if err != nil {
+			c.addError(logging.WithCode(logging.CodeRead, err))
+			return c.result
+		}
+		stdinPath = path
+		entries = append(entries[:len(entries):len(entries)], path)
+	}
 
-	for _, e := range opts.Entry {
-		wg.Go(func() error {
+	paths, err := newOutputPaths(opts, entries)
+	if err != nil {
+		c.addError(logging.WithCode(logging.CodeOptions, err))
+		return c.result
+	}
+
+	if opts.Transforms.Targets != "" {
+		if _, err := browsers.Parse(opts.Transforms.Targets); err != nil {
+			c.addError(logging.WithCode(logging.CodeOptions, err))
+			return c.result
+		}
+	}
+
+	if opts.Modules != nil {
+		if err := opts.Modules.validate(); err != nil {
+			c.addError(logging.WithCode(logging.CodeOptions, err))
+			return c.result
+		}
+
+		c.modulesRoot, err = opts.Modules.root(opts, entries)
+		if err != nil {
+			c.addError(logging.WithCode(logging.CodeOptions, err))
+			return c.result
+		}
+	}
+
+	for _, e := range entries {
+		e := e
+		c.parsing.Go(func() error {
 			c.parseFile(e, true)
 			return nil
 		})
 	}
-	wg.Wait()
+	c.parsing.Wait()
 
-	wg = errgroup.Group{}
-	for i := range c.outputsByIndex {
+	for path := range c.sources {
+		if path != stdinPath {
+			c.result.Inputs = append(c.result.Inputs, path)
+		}
+	}
+	sort.Strings(c.result.Inputs)
+
+	outputs := make([]int, 0, len(c.outputsByIndex))
+	for idx := range c.outputsByIndex {
+		outputs = append(outputs, idx)
+	}
+	sort.Ints(outputs)
+	c.linkImports(outputs)
+
+	if paths.outfile != "" && len(outputs) > 1 {
+		c.addError(logging.WithCode(logging.CodeOptions, oops.Errorf("Outfile can only be used with a single output, but there are %d outputs", len(outputs))))
+		return c.result
+	}
+
+	// CSS Modules share the exports of the modules that they depend on, so transforms run
+	// one at a time in a stable order.
+	asts := make(map[int]*ast.Stylesheet, len(outputs))
+	nodeSources := make(map[int]map[ast.Node]*sources.Source, len(outputs))
+	exports := make(map[int]map[string]string, len(outputs))
+	for _, idx := range outputs {
+		asts[idx], nodeSources[idx], exports[idx] = c.transformFile(idx)
+	}
+
+	var wg errgroup.Group
+	for _, i := range outputs {
 		idx := i
 		wg.Go(func() error {
-			// XXX: this is the wrong file name
-			source := c.sourcesByIndex[idx]
-			ast := c.astsByIndex[idx]
-			if source == nil || ast == nil {
-				// Skip attempting to print if there was a problem reading/parsing this file
-				// in the first place.
+			source := c.source(idx)
+			ast := asts[idx]
+			if ast == nil {
 				return nil
 			}
 
-			out, err := printer.Print(ast, printer.Options{
-				OriginalSource: source,
-			})
+			path, err := paths.path(source.Path)
 			if err != nil {
-				c.addError(err)
+				c.addError(logging.WithCode(logging.CodeOutput, err))
+				return nil
+			}
+
+			printerOpts := printer.Options{
+				OutputPath:            path,
+				ExcludeSourcesContent: opts.SourcesContent == SourcesContentExclude,
+				NodeSources:           nodeSources[idx],
+			}
+			if opts.SourceMap != SourceMapNone {
+				printerOpts.OriginalSource = source
+			}
+
+			out, sourceMap, err := printer.PrintWithSourceMap(ast, printerOpts)
+			if err != nil {
+				c.addError(logging.WithCode(logging.CodeOutput, err))
 				return nil
 			}
 
+			files := map[string]string{path: out}
+			switch opts.SourceMap {
+			case SourceMapInline:
+				files[path] = out + printer.SourceMappingURLComment(printer.InlineSourceMapURL(sourceMap))
+
+			case SourceMapExternal:
+				files[path] = out + printer.SourceMappingURLComment(filepath.Base(path)+".map")
+				files[path+".map"] = sourceMap
+
+			case SourceMapHidden:
+				files[path+".map"] = sourceMap
+			}
+
+			var moduleFiles map[string]string
+			if exports[idx] != nil {
+				moduleFiles, err = opts.Modules.outputs(path, exports[idx])
+				if err != nil {
+					c.addError(logging.WithCode(logging.CodeOutput, err))
+				}
+			}
+
 			c.result.mu.Lock()
 			defer c.result.mu.Unlock()
-			c.result.Files[source.Path] = out
+			for path, content := range files {
+				c.result.Files[path] = content
+			}
+			if exports[idx] != nil {
+				c.result.Exports[path] = exports[idx]
+			}
+			for path, content := range moduleFiles {
+				c.result.Files[path] = content
+			}
 			return nil
 		})
 	}
@@ -264,10 +734,19 @@ func Compile(opts Options) *Result {
 	return c.result
 }
 
-// Reporter is an error and warning reporter.
+// Reporter is an error and warning reporter. Every error passed to AddError
+// is a *Diagnostic, so warnings can be told apart from errors by Severity.
 //
 // Note that it is the same type as logging.Reporter, which is
 // an internal-only interface.
 type Reporter interface {
 	AddError(err error)
 }
+
+// WarningReporter is a Reporter that receives warnings separately from errors.
+// If Options.Reporter implements it, warnings are passed to AddWarning instead
+// of AddError.
+type WarningReporter interface {
+	Reporter
+	AddWarning(err error)
+}
@@ -1,11 +1,14 @@
 package cssc_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stephen/cssc"
 	"github.com/stephen/cssc/transforms"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type TestReporter []error
@@ -40,6 +43,29 @@ func TestApi_Simple(t *testing.T) {
 	assert.Len(t, errors, 0)
 }
 
+func TestApi_Inputs(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry: []string{
+			"testdata/imports/index.css",
+		},
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+		Reporter: &errors,
+	})
+
+	var inputs []string
+	for _, name := range []string{"another.css", "index.css", "other.css"} {
+		path, err := filepath.Abs(filepath.Join("testdata/imports", name))
+		require.NoError(t, err)
+		inputs = append(inputs, path)
+	}
+
+	assert.Len(t, errors, 0)
+	assert.Equal(t, inputs, result.Inputs)
+}
+
 func TestApi_BrokenImport(t *testing.T) {
 	var errors TestReporter
 	result := cssc.Compile(cssc.Options{
@@ -71,3 +97,238 @@ func TestApi_Crlf(t *testing.T) {
 	assert.Len(t, result.Files, 1)
 	assert.Len(t, errors, 0)
 }
+
+func TestApi_Modules(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/modules/button.module.css"},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{Pattern: "[name]_[local]"},
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+	assert.Len(t, errors, 0)
+
+	path, err := filepath.Abs("testdata/modules/button.module.css")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		path: {"base": "button_base", "button": "button_button", "pulse": "button_pulse"},
+	}, result.Exports)
+	assert.Equal(t, `.button_base{color:red}.button_button{animation:button_pulse 1s}.disabled .button_button{opacity:0.5}@keyframes button_pulse{to{opacity:0}}`, result.Files[path])
+}
+
+func TestApi_ModulesSharedImport(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/modules/button.module.css", "testdata/modules/card.module.css"},
+		Outdir:   "dist",
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{Pattern: "[name]_[local]"},
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+	assert.Len(t, errors, 0)
+
+	// base.css is scoped separately for each module that imports it.
+	button, err := filepath.Abs("dist/button.module.css")
+	require.NoError(t, err)
+	card, err := filepath.Abs("dist/card.module.css")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		button: {"base": "button_base", "button": "button_button", "pulse": "button_pulse"},
+		card:   {"base": "card_base", "card": "card_card"},
+	}, result.Exports)
+	assert.Equal(t, `.card_base{color:red}.card_card{color:blue}`, result.Files[card])
+}
+
+func TestApi_ModulesHash(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Stdin:    &cssc.StdinOptions{Contents: `.a { color: red } .b { color: blue }`, Sourcefile: "input.module.css"},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{},
+	})
+	assert.Len(t, errors, 0)
+	require.Len(t, result.Exports, 1)
+
+	for _, exports := range result.Exports {
+		assert.Regexp(t, `^input_a_[0-9a-f]{8}$`, exports["a"])
+		assert.Regexp(t, `^input_b_[0-9a-f]{8}$`, exports["b"])
+		assert.NotEqual(t, exports["a"][len("input_a_"):], exports["b"][len("input_b_"):])
+	}
+}
+
+func TestApi_ModulesPattern(t *testing.T) {
+	var errors TestReporter
+	cssc.Compile(cssc.Options{
+		Stdin:    &cssc.StdinOptions{Contents: `.a { color: red }`},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{Pattern: "[name]"},
+	})
+	require.Len(t, errors, 1)
+	assert.Contains(t, errors[0].Error(), "Modules.Pattern must contain [local] or [hash]")
+}
+
+func TestApi_ModulesComposes(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/composes/button.module.css"},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{Pattern: "[name]_[local]"},
+	})
+	assert.Len(t, errors, 0)
+
+	path, err := filepath.Abs("testdata/composes/button.module.css")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		path: {"button": "button_button typography_heading"},
+	}, result.Exports)
+	assert.Equal(t, `.button_button{color:red}`, result.Files[path])
+
+	// The composed module is not an output unless it is an entry.
+	assert.Len(t, result.Files, 1)
+}
+
+func TestApi_ModulesComposesEntryOrder(t *testing.T) {
+	button, err := filepath.Abs("testdata/composes/button.module.css")
+	require.NoError(t, err)
+	typography, err := filepath.Abs("testdata/composes/typography.module.css")
+	require.NoError(t, err)
+
+	// Exports of a dependency are the same whether or not it was already compiled as an entry.
+	for _, entry := range [][]string{
+		{"testdata/composes/button.module.css", "testdata/composes/typography.module.css"},
+		{"testdata/composes/typography.module.css", "testdata/composes/button.module.css"},
+	} {
+		var errors TestReporter
+		result := cssc.Compile(cssc.Options{
+			Entry:    entry,
+			Reporter: &errors,
+			Modules:  &cssc.ModulesOptions{Pattern: "[name]_[local]"},
+		})
+		assert.Len(t, errors, 0)
+		assert.Equal(t, map[string]map[string]string{
+			button:     {"button": "button_button typography_heading"},
+			typography: {"heading": "typography_heading", "brand": "#0066ff"},
+		}, result.Exports)
+	}
+}
+
+func TestApi_ModulesExport(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/composes/typography.module.css"},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{Pattern: "[name]_[local]"},
+	})
+	assert.Len(t, errors, 0)
+
+	path, err := filepath.Abs("testdata/composes/typography.module.css")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"heading": "typography_heading", "brand": "#0066ff"}, result.Exports[path])
+	assert.Equal(t, `.typography_heading{font-weight:bold}`, result.Files[path])
+}
+
+func TestApi_ModulesComposesErrors(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/composes/missing.module.css"},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{},
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "missing is not exported by ./typography.module.css", result.Errors[0].Message)
+	assert.Equal(t, 2, result.Errors[0].Line)
+	assert.Equal(t, 3, result.Errors[0].Column)
+
+	result = cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/composes/cycle-a.module.css", "testdata/composes/cycle-b.module.css"},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{},
+	})
+	require.NotEmpty(t, result.Errors)
+	assert.Contains(t, result.Errors[0].Message, "depends on itself through composes or :import")
+}
+
+func TestApi_ModulesTypeScript(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/composes/typography.module.css"},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{Pattern: "[name]_[local]", TypeScript: true, GoPackage: "styles"},
+	})
+	assert.Len(t, errors, 0)
+
+	path, err := filepath.Abs("testdata/composes/typography.module.css")
+	require.NoError(t, err)
+	assert.Len(t, result.Files, 3)
+	assert.Equal(t, `declare const styles: {
+  readonly "brand": string;
+  readonly "heading": string;
+};
+export default styles;
+`, result.Files[path+".d.ts"])
+	assert.Equal(t, `// Code generated by cssc. DO NOT EDIT.
+
+package styles
+
+// Exports of typography.module.css.
+const (
+	TypographyBrand   = "#0066ff"
+	TypographyHeading = "typography_heading"
+)
+`, result.Files[path+".go"])
+}
+
+func TestApi_ModulesGoPackage(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Stdin:    &cssc.StdinOptions{Contents: `.primary-button { color: red } .primary_button { color: blue }`, Sourcefile: "button.module.css"},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{GoPackage: "styles"},
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0].Message, "primary-button and primary_button in button.module.css both have the Go name ButtonPrimaryButton")
+
+	result = cssc.Compile(cssc.Options{
+		Stdin:    &cssc.StdinOptions{Contents: `.a { color: red }`},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{GoPackage: "my-styles"},
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0].Message, `Modules.GoPackage must be a Go identifier, but it is "my-styles"`)
+}
+
+func TestApi_ModulesHashWorkingDirectory(t *testing.T) {
+	entry, err := filepath.Abs("testdata/modules/button.module.css")
+	require.NoError(t, err)
+
+	compile := func(dir string, modules *cssc.ModulesOptions) map[string]string {
+		wd, err := os.Getwd()
+		require.NoError(t, err)
+		require.NoError(t, os.Chdir(dir))
+		defer os.Chdir(wd)
+
+		var errors TestReporter
+		result := cssc.Compile(cssc.Options{
+			Entry:    []string{entry},
+			Reporter: &errors,
+			Modules:  modules,
+		})
+		require.Len(t, errors, 0)
+		return result.Exports[entry]
+	}
+
+	// Generated names don't depend on where the compilation is run from.
+	exports := compile(".", &cssc.ModulesOptions{})
+	assert.Regexp(t, `^button_button_[0-9a-f]{8}$`, exports["button"])
+	assert.Equal(t, exports, compile("testdata", &cssc.ModulesOptions{}))
+	assert.Equal(t, exports, compile(os.TempDir(), &cssc.ModulesOptions{}))
+
+	// With a different root, the hashed path is different.
+	rooted := compile(".", &cssc.ModulesOptions{Root: "testdata"})
+	assert.NotEqual(t, exports, rooted)
+	assert.Equal(t, rooted, compile("testdata", &cssc.ModulesOptions{Root: filepath.Join("..", "testdata")}))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// enumFlag is a flag.Value for one of the enum types in cssc's options. Values
+// are set by name.
+type enumFlag struct {
+	// target is a pointer to the option.
+	target reflect.Value
+
+	// values is a map of names to option values.
+	values reflect.Value
+}
+
+// newEnumFlag creates a flag that sets *target by name from values, which must be a map
+// from string to the type of *target.
+func newEnumFlag(target interface{}, values interface{}) *enumFlag {
+	return &enumFlag{reflect.ValueOf(target), reflect.ValueOf(values)}
+}
+
+// names returns the sorted names of all values.
+func (f *enumFlag) names() []string {
+	var names []string
+	for _, k := range f.values.MapKeys() {
+		names = append(names, k.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String implements flag.Value.
+func (f *enumFlag) String() string {
+	// flag may call String on a zero value to check for defaults.
+	if !f.target.IsValid() {
+		return ""
+	}
+
+	current := f.target.Elem().Interface()
+	for _, k := range f.values.MapKeys() {
+		if f.values.MapIndex(k).Interface() == current {
+			return k.String()
+		}
+	}
+	return ""
+}
+
+// Set implements flag.Value.
+func (f *enumFlag) Set(s string) error {
+	v := f.values.MapIndex(reflect.ValueOf(s))
+	if !v.IsValid() {
+		return fmt.Errorf("must be one of: %s", strings.Join(f.names(), ", "))
+	}
+	f.target.Elem().Set(v)
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// expandGlob returns the files matching pattern. In addition to the syntax
+// supported by filepath.Match, ** matches any number of directories. Patterns
+// without any glob syntax are returned as-is, so that missing files are reported
+// by the compiler.
+func expandGlob(pattern string) ([]string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil
+	}
+
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", pattern)
+		}
+		return matches, nil
+	}
+
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	re, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %v", pattern, err)
+	}
+
+	// Walk from the longest directory prefix without any glob syntax.
+	root := "."
+	if i := strings.LastIndex(pattern[:strings.IndexAny(pattern, "*?[")], "/"); i >= 0 {
+		root = pattern[:i+1]
+	}
+
+	var matches []string
+	if err := filepath.Walk(filepath.FromSlash(root), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && re.MatchString(filepath.ToSlash(path)) {
+			matches = append(matches, path)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to expand %q: %v", pattern, err)
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files match %q", pattern)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// globToRegexp converts a slash-separated glob into a regular expression.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	// Walked paths are cleaned, so the pattern should not start with ./ either.
+	pattern = strings.TrimPrefix(pattern, "./")
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**/") {
+				b.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pattern[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [")
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "^") {
+				class = "^/" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
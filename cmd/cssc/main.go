@@ -0,0 +1,236 @@
+// Command cssc compiles css files.
+//
+// Usage:
+//
+//	cssc [flags] [entry ...]
+//
+// Entries can be globs, including ** to match any number of directories. If no
+// entries are given, input is read from stdin. Outputs are written to stdout
+// unless --outdir or --outfile is given.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/internal/logging"
+	"github.com/stephen/cssc/transforms"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with args, returning the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var opts cssc.Options
+	sourcesContent := true
+
+	flags := flag.NewFlagSet("cssc", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: cssc [flags] [entry ...]")
+		fmt.Fprintln(stderr, "\nEntries can be globs. If no entries are given, input is read from stdin.")
+		fmt.Fprintln(stderr, "\nFlags:")
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&opts.Outdir, "outdir", "", "directory to write outputs to")
+	flags.StringVar(&opts.Outbase, "outbase", "", "directory that output paths are relative to when using --outdir (default: common ancestor of all entries)")
+	flags.StringVar(&opts.Outfile, "outfile", "", "file to write a single output to")
+	stdinName := flags.String("sourcefile", "", "name of the stdin entry in errors, source maps, and output paths")
+	flags.Var(newEnumFlag(&opts.SourceMap, map[string]cssc.SourceMap{
+		"none":     cssc.SourceMapNone,
+		"inline":   cssc.SourceMapInline,
+		"external": cssc.SourceMapExternal,
+		"hidden":   cssc.SourceMapHidden,
+	}), "sourcemap", "source map mode")
+	flags.BoolVar(&sourcesContent, "sources-content", true, "include original sources in source maps")
+	flags.BoolVar(&opts.Minify, "minify", false, "minify the output")
+	flags.BoolVar(&opts.Optimize, "optimize", false, "merge duplicate rules and declarations")
+	modules := flags.Bool("modules", false, "compile files as CSS Modules")
+	modulesPattern := flags.String("modules-pattern", "", "pattern for generated names when using --modules (default: [name]_[local]_[hash])")
+	modulesTypeScript := flags.Bool("modules-typescript", false, "write a .d.ts file for each CSS Module when using --modules")
+	modulesGoPackage := flags.String("modules-go-package", "", "write a Go file with constants for each CSS Module in this package when using --modules")
+	modulesRoot := flags.String("modules-root", "", "directory that file paths are hashed relative to when using --modules (default: --outbase)")
+	watch := flags.Bool("watch", false, "recompile when input files change")
+
+	flags.Var(newEnumFlag(&opts.Transforms.ImportRules, map[string]transforms.ImportRules{
+		"passthrough": transforms.ImportRulesPassthrough,
+		"follow":      transforms.ImportRulesFollow,
+		"inline":      transforms.ImportRulesInline,
+	}), "import-rules", "@import transform")
+	flags.Var(newEnumFlag(&opts.Transforms.MediaFeatureRanges, map[string]transforms.MediaFeatureRanges{
+		"auto":        transforms.MediaFeatureRangesAuto,
+		"passthrough": transforms.MediaFeatureRangesPassthrough,
+		"transform":   transforms.MediaFeatureRangesTransform,
+	}), "media-feature-ranges", "media feature range transform")
+	flags.Var(newEnumFlag(&opts.Transforms.AnyLink, map[string]transforms.AnyLink{
+		"auto":        transforms.AnyLinkAuto,
+		"passthrough": transforms.AnyLinkPassthrough,
+		"transform":   transforms.AnyLinkTransform,
+	}), "any-link", ":any-link transform")
+	flags.Var(newEnumFlag(&opts.Transforms.CustomProperties, map[string]transforms.CustomProperties{
+		"auto":               transforms.CustomPropertiesAuto,
+		"passthrough":        transforms.CustomPropertiesPassthrough,
+		"transform-root":     transforms.CustomPropertiesTransformRoot,
+		"transform":          transforms.CustomPropertiesTransform,
+		"transform-preserve": transforms.CustomPropertiesTransformPreserve,
+	}), "custom-properties", "custom property transform")
+	flags.Var(newEnumFlag(&opts.Transforms.CustomMediaQueries, map[string]transforms.CustomMediaQueries{
+		"auto":        transforms.CustomMediaQueriesAuto,
+		"passthrough": transforms.CustomMediaQueriesPassthrough,
+		"transform":   transforms.CustomMediaQueriesTransform,
+	}), "custom-media-queries", "@custom-media transform")
+	flags.Var(newEnumFlag(&opts.Transforms.CalcReduction, map[string]transforms.CalcReduction{
+		"auto":        transforms.CalcReductionAuto,
+		"passthrough": transforms.CalcReductionPassthrough,
+		"reduce":      transforms.CalcReductionReduce,
+	}), "calc-reduction", "math function transform")
+	flags.Var(newEnumFlag(&opts.Transforms.ColorFunctions, map[string]transforms.ColorFunctions{
+		"auto":        transforms.ColorFunctionsAuto,
+		"passthrough": transforms.ColorFunctionsPassthrough,
+		"transform":   transforms.ColorFunctionsTransform,
+	}), "color-functions", "color function transform")
+	flags.Var(newEnumFlag(&opts.Transforms.Prefixer, map[string]transforms.Prefixer{
+		"auto":        transforms.PrefixerAuto,
+		"passthrough": transforms.PrefixerPassthrough,
+		"transform":   transforms.PrefixerTransform,
+	}), "prefixer", "vendor prefix transform, using --targets")
+	flags.StringVar(&opts.Transforms.Targets, "targets", "", `browserslist-style query for the browsers to support, e.g. "chrome 80, safari >= 13"`)
+	flags.Var(newEnumFlag(&opts.Transforms.Nesting, map[string]transforms.Nesting{
+		"auto":        transforms.NestingAuto,
+		"passthrough": transforms.NestingPassthrough,
+		"transform":   transforms.NestingTransform,
+	}), "nesting", "nesting transform")
+	flags.Var(newEnumFlag(&opts.Transforms.CascadeLayers, map[string]transforms.CascadeLayers{
+		"auto":        transforms.CascadeLayersAuto,
+		"passthrough": transforms.CascadeLayersPassthrough,
+		"transform":   transforms.CascadeLayersTransform,
+	}), "cascade-layers", "@layer transform")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if !sourcesContent {
+		opts.SourcesContent = cssc.SourcesContentExclude
+	}
+
+	if (*modulesPattern != "" || *modulesTypeScript || *modulesGoPackage != "" || *modulesRoot != "") && !*modules {
+		fmt.Fprintln(stderr, "--modules-pattern, --modules-typescript, --modules-go-package, and --modules-root require --modules")
+		return 1
+	}
+	if *modules {
+		opts.Modules = &cssc.ModulesOptions{
+			Pattern:    *modulesPattern,
+			TypeScript: *modulesTypeScript,
+			GoPackage:  *modulesGoPackage,
+			Root:       *modulesRoot,
+		}
+	}
+
+	for _, pattern := range flags.Args() {
+		matches, err := expandGlob(pattern)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		opts.Entry = append(opts.Entry, matches...)
+	}
+
+	if len(opts.Entry) == 0 {
+		if *watch {
+			fmt.Fprintln(stderr, "--watch requires at least one entry")
+			return 1
+		}
+		if opts.Outdir != "" && *stdinName == "" {
+			fmt.Fprintln(stderr, "--outdir requires --sourcefile when reading from stdin")
+			return 1
+		}
+
+		in, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "failed to read stdin:", err)
+			return 1
+		}
+		opts.Stdin = &cssc.StdinOptions{
+			Contents:   string(in),
+			Sourcefile: *stdinName,
+		}
+	}
+
+	toStdout := opts.Outdir == "" && opts.Outfile == ""
+	if toStdout && (opts.SourceMap == cssc.SourceMapExternal || opts.SourceMap == cssc.SourceMapHidden) {
+		fmt.Fprintln(stderr, "--sourcemap=external and --sourcemap=hidden require --outdir or --outfile")
+		return 1
+	}
+	if toStdout && (*modulesTypeScript || *modulesGoPackage != "") {
+		fmt.Fprintln(stderr, "--modules-typescript and --modules-go-package require --outdir or --outfile")
+		return 1
+	}
+
+	opts.Reporter = logging.WriterReporter{Writer: stderr}
+
+	if *watch {
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+		stop := cssc.Watch(opts, func(result *cssc.Result) {
+			write(result, toStdout, stdout, stderr)
+		})
+		<-interrupt
+		stop()
+		return 0
+	}
+
+	result := cssc.Compile(opts)
+	if code := write(result, toStdout, stdout, stderr); code != 0 {
+		return code
+	}
+
+	if len(result.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// write writes the outputs in result to stdout or to disk, returning a non-zero
+// exit code if they could not be written.
+func write(result *cssc.Result, toStdout bool, stdout, stderr io.Writer) int {
+	if toStdout {
+		var paths []string
+		for path := range result.Files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		if len(paths) > 1 {
+			fmt.Fprintf(stderr, "cannot write %d outputs to stdout; use --outdir\n", len(paths))
+			return 1
+		}
+		for _, path := range paths {
+			out := result.Files[path]
+			if !strings.HasSuffix(out, "\n") {
+				out += "\n"
+			}
+			io.WriteString(stdout, out)
+		}
+	} else if err := result.WriteToDisk(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
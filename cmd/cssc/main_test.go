@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes files into a new temporary directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "cssc")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func runCLI(stdin string, args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = run(args, strings.NewReader(stdin), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestRun_Stdin(t *testing.T) {
+	code, stdout, stderr := runCLI(`.a:any-link { color: red }`, "--any-link=transform")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, ".a:visited,.a:link{color:red}\n", stdout)
+}
+
+func TestRun_Targets(t *testing.T) {
+	code, stdout, stderr := runCLI(`.a { position: sticky }`, "--prefixer=transform", "--targets=safari 12, chrome 80")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, ".a{position:-webkit-sticky;position:sticky}\n", stdout)
+}
+
+func TestRun_Minify(t *testing.T) {
+	code, stdout, stderr := runCLI(`.a { color: #FFFFFF; opacity: 0.50 } .b {}`, "--minify")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, ".a{color:#fff;opacity:.5}\n", stdout)
+}
+
+func TestRun_Optimize(t *testing.T) {
+	code, stdout, stderr := runCLI(`.a { color: red } .b { color: red }`, "--minify", "--optimize")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, ".a,.b{color:red}\n", stdout)
+}
+
+func TestRun_Modules(t *testing.T) {
+	code, stdout, stderr := runCLI(`.a { color: red } :global(.b) { color: blue }`, "--modules", "--modules-pattern=[local]_x", "--minify")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, ".a_x{color:red}.b{color:blue}\n", stdout)
+
+	code, _, stderr = runCLI(`.a { color: red }`, "--modules-pattern=[local]_x")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "--modules-pattern, --modules-typescript, --modules-go-package, and --modules-root require --modules")
+}
+
+func TestRun_ModulesTypeScript(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"src/button.module.css": `.primary { color: red }`})
+	out := filepath.Join(dir, "dist")
+
+	code, _, stderr := runCLI("", "--modules", "--modules-pattern=[local]_x", "--modules-typescript", "--outdir", out, filepath.Join(dir, "src", "button.module.css"))
+	assert.Equal(t, 0, code, stderr)
+
+	dts, err := ioutil.ReadFile(filepath.Join(out, "button.module.css.d.ts"))
+	require.NoError(t, err)
+	assert.Contains(t, string(dts), `readonly "primary": string;`)
+
+	code, _, stderr = runCLI(`.a { color: red }`, "--modules", "--modules-typescript")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "--modules-typescript and --modules-go-package require --outdir or --outfile")
+}
+
+func TestRun_StdinImports(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"base.css": `.base { color: red }`})
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	code, stdout, stderr := runCLI(`@import "./base.css"; .a { color: blue }`, "--import-rules=inline")
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, ".base{color:red}.a{color:blue}\n", stdout)
+}
+
+func TestRun_Outdir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"src/a.css":         `.a { color: red }`,
+		"src/nested/b.css":  `.b { color: blue }`,
+		"src/nested/c.scss": `.c { color: green }`,
+	})
+	out := filepath.Join(dir, "dist")
+
+	code, stdout, stderr := runCLI("", "--outdir", out, "--sourcemap=external", filepath.Join(dir, "src/**/*.css"))
+	assert.Equal(t, 0, code, stderr)
+	assert.Empty(t, stdout)
+
+	a, err := ioutil.ReadFile(filepath.Join(out, "a.css"))
+	require.NoError(t, err)
+	assert.Equal(t, ".a{color:red}\n/*# sourceMappingURL=a.css.map */\n", string(a))
+	assert.FileExists(t, filepath.Join(out, "a.css.map"))
+	assert.FileExists(t, filepath.Join(out, "nested/b.css"))
+	assert.NoFileExists(t, filepath.Join(out, "nested/c.css"))
+}
+
+func TestRun_StdinOutdir(t *testing.T) {
+	dir := writeFiles(t, nil)
+	out := filepath.Join(dir, "dist")
+
+	code, _, stderr := runCLI(`.a { color: red }`, "--outdir", out)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "--outdir requires --sourcefile")
+	assert.NoDirExists(t, out)
+
+	code, stdout, stderr := runCLI(`.a { color: red }`, "--outdir", out, "--sourcefile", "a.css")
+	assert.Equal(t, 0, code, stderr)
+	assert.Empty(t, stdout)
+
+	a, err := ioutil.ReadFile(filepath.Join(out, "a.css"))
+	require.NoError(t, err)
+	assert.Equal(t, ".a{color:red}", string(a))
+}
+
+func TestRun_Outfile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.css": `.a { color: red }`})
+	out := filepath.Join(dir, "out.css")
+
+	code, _, stderr := runCLI("", "--outfile", out, filepath.Join(dir, "a.css"))
+	assert.Equal(t, 0, code, stderr)
+
+	a, err := ioutil.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, ".a{color:red}", string(a))
+}
+
+func TestRun_Errors(t *testing.T) {
+	code, _, stderr := runCLI(`.a { color: red`)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "<stdin>:1:")
+
+	code, _, stderr = runCLI("", "--nesting=maybe")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "must be one of: auto, passthrough, transform")
+
+	dir := writeFiles(t, map[string]string{"a.css": `.a{}`, "b.css": `.b{}`})
+	code, _, stderr = runCLI("", filepath.Join(dir, "*.css"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "use --outdir")
+
+	code, _, stderr = runCLI("", filepath.Join(dir, "*.less"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "no files match")
+}
+
+func TestRun_Warnings(t *testing.T) {
+	// Warnings are reported, but don't fail the command.
+	code, stdout, stderr := runCLI(`@layer a { .a { color: red !important } }`, "--cascade-layers=transform")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, ".a{color:red!important}\n", stdout)
+	assert.NotEmpty(t, stderr)
+}
@@ -0,0 +1,135 @@
+package cssc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/samsarahq/go/oops"
+	"github.com/stephen/cssc/internal/logging"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity int
+
+const (
+	// SeverityError is an error. The output for the file may be missing or incomplete.
+	SeverityError Severity = iota
+	// SeverityWarning is a warning. The output is still generated, but may not
+	// behave as expected.
+	SeverityWarning
+)
+
+// String implements fmt.Stringer.
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Codes for the kinds of diagnostics.
+const (
+	// CodeSyntax is an error from parsing a file.
+	CodeSyntax = logging.CodeSyntax
+	// CodeRead is an error from reading a file.
+	CodeRead = logging.CodeRead
+	// CodeUnresolvedImport is an @import that could not be resolved.
+	CodeUnresolvedImport = logging.CodeUnresolvedImport
+	// CodeImportCycle is an @import that would create a cycle.
+	CodeImportCycle = logging.CodeImportCycle
+	// CodeTransform is an error or warning from a transform.
+	CodeTransform = logging.CodeTransform
+	// CodeOptions is an invalid set of Options.
+	CodeOptions = logging.CodeOptions
+	// CodeOutput is an error from generating an output.
+	CodeOutput = logging.CodeOutput
+)
+
+// Diagnostic is an error or warning from a compilation.
+type Diagnostic struct {
+	Severity Severity
+
+	// Code is the kind of diagnostic, e.g. CodeSyntax.
+	Code string
+
+	// Message describes the problem.
+	Message string
+
+	// File is the absolute path of the file with the problem. It is empty if the
+	// problem is not in a specific file, e.g. for invalid Options.
+	File string
+
+	// Line and Column are the 1-indexed position of the problem in File.
+	Line, Column int
+
+	// Start and End are the byte offsets of the problem in File. End is exclusive.
+	Start, End int
+
+	// Excerpt is the line from File with the problem, with the problem underlined.
+	Excerpt string
+
+	// err is the underlying error.
+	err error
+}
+
+// newDiagnostic creates a Diagnostic from an error reported during compilation.
+func newDiagnostic(err error) *Diagnostic {
+	d := &Diagnostic{
+		Code:    logging.Code(err),
+		Message: errorMessage(err),
+		err:     err,
+	}
+
+	if logging.IsWarning(err) {
+		d.Severity = SeverityWarning
+	}
+
+	if source, span, message, ok := logging.LocationOf(err); ok {
+		line, col := source.LineAndCol(span)
+		d.Message = message
+		d.File = source.Path
+		d.Line, d.Column = int(line), int(col)
+		d.Start, d.End = span.Start, span.End
+		d.Excerpt = logging.AnnotateSourceSpan(source, span)
+	}
+
+	return d
+}
+
+// errorMessage returns the message for err without the stack trace that oops
+// errors include in Error().
+func errorMessage(err error) string {
+	var reasoner interface{ Reason() string }
+	if !errors.As(err, &reasoner) {
+		return err.Error()
+	}
+
+	message := oops.Cause(reasoner.(error)).Error()
+	if reason := reasoner.Reason(); reason != "" {
+		message = reason + ": " + message
+	}
+	return message
+}
+
+// Error implements error. The output looks like:
+// file.css:1:4
+// warning: there's a problem here:
+//
+//	(contents) or (other thing)
+//	 ~~~~~~~~
+func (d *Diagnostic) Error() string {
+	message := d.Message
+	if d.Severity == SeverityWarning {
+		message = "warning: " + message
+	}
+
+	if d.File == "" {
+		return message
+	}
+	return fmt.Sprintf("%s:%d:%d\n%s:\n%s", d.File, d.Line, d.Column, message, d.Excerpt)
+}
+
+// Unwrap returns the underlying error, e.g. an error from the Resolver.
+func (d *Diagnostic) Unwrap() error {
+	return d.err
+}
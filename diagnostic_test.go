@@ -0,0 +1,210 @@
+package cssc_test
+
+import (
+	stderrors "errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/resolver"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostics_Errors(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry: []string{"testdata/brokenimports/index.css"},
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+		Reporter: &errors,
+	})
+
+	require.Len(t, result.Errors, 1)
+	assert.Empty(t, result.Warnings)
+
+	d := result.Errors[0]
+	abs, err := filepath.Abs("testdata/brokenimports/index.css")
+	require.NoError(t, err)
+	assert.Equal(t, cssc.SeverityError, d.Severity)
+	assert.Equal(t, cssc.CodeUnresolvedImport, d.Code)
+	assert.Equal(t, abs, d.File)
+	assert.Equal(t, 1, d.Line)
+	assert.Equal(t, 1, d.Column)
+	assert.Equal(t, 0, d.Start)
+	assert.Equal(t, "\t@import \"./nonsense.css\";\n\t~~~~~~~~~~~~~~~~~~~~~~~~", d.Excerpt)
+
+	// The reporter sees the same diagnostics.
+	require.Len(t, errors, 1)
+	assert.Same(t, d, errors[0])
+}
+
+func TestDiagnostics_Warnings(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Stdin: &cssc.StdinOptions{
+			Contents:   "@layer a {\n  .a { color: red !important }\n}",
+			Sourcefile: "index.css",
+		},
+		Transforms: transforms.Options{
+			CascadeLayers: transforms.CascadeLayersTransform,
+		},
+		Reporter: &errors,
+	})
+
+	assert.Empty(t, result.Errors)
+	require.Len(t, result.Warnings, 1)
+
+	d := result.Warnings[0]
+	assert.Equal(t, cssc.SeverityWarning, d.Severity)
+	assert.Equal(t, cssc.CodeTransform, d.Code)
+	assert.Equal(t, 2, d.Line)
+	assert.Equal(t, "!important declarations in @layer cannot be flattened without changing their precedence", d.Message)
+	assert.Contains(t, d.Error(), "index.css:2:8\nwarning: !important declarations")
+}
+
+// warningReporter collects warnings separately from errors.
+type warningReporter struct {
+	errors, warnings []error
+}
+
+func (r *warningReporter) AddError(err error) {
+	r.errors = append(r.errors, err)
+}
+
+func (r *warningReporter) AddWarning(err error) {
+	r.warnings = append(r.warnings, err)
+}
+
+func TestDiagnostics_WarningReporter(t *testing.T) {
+	var reporter warningReporter
+	result := cssc.Compile(cssc.Options{
+		Stdin: &cssc.StdinOptions{
+			Contents:   "@layer a {\n  .a { color: red !important }\n}\n.b { color: red",
+			Sourcefile: "index.css",
+		},
+		Transforms: transforms.Options{
+			CascadeLayers: transforms.CascadeLayersTransform,
+		},
+		Reporter: &reporter,
+	})
+
+	require.Len(t, result.Errors, 1)
+	require.Len(t, reporter.errors, 1)
+	assert.Same(t, result.Errors[0], reporter.errors[0])
+
+	require.Len(t, result.Warnings, 1)
+	require.Len(t, reporter.warnings, 1)
+	assert.Same(t, result.Warnings[0], reporter.warnings[0])
+}
+
+func TestDiagnostics_Codes(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/nonexistent/index.css"},
+		Stdin:    &cssc.StdinOptions{Contents: ".a { color: red"},
+		Reporter: &errors,
+	})
+
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, cssc.CodeRead, result.Errors[0].Code)
+	assert.Empty(t, result.Errors[0].File)
+	assert.Equal(t, cssc.CodeSyntax, result.Errors[1].Code)
+	assert.Contains(t, result.Errors[1].File, "<stdin>")
+
+	result = cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/simple/index.css"},
+		Outdir:   "out",
+		Outfile:  "out.css",
+		Reporter: &errors,
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, cssc.CodeOptions, result.Errors[0].Code)
+
+	result = cssc.Compile(cssc.Options{
+		Entry:      []string{"testdata/simple/index.css"},
+		Transforms: transforms.Options{Targets: "netscape 4"},
+		Reporter:   &errors,
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, cssc.CodeOptions, result.Errors[0].Code)
+}
+
+func TestDiagnostics_Unwrap(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/brokenimports/index.css"},
+		Reporter: &errors,
+	})
+
+	require.Len(t, result.Errors, 1)
+	assert.True(t, stderrors.Is(result.Errors[0], resolver.ErrNotFound))
+}
+
+func TestDiagnostics_SyntaxErrors(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Stdin: &cssc.StdinOptions{
+			Contents:   ".a { color: red }\n@media (1px) { .b { color: blue } }\n@import 12;\n.c { color: green }",
+			Sourcefile: "index.css",
+		},
+		Reporter: &errors,
+	})
+
+	// Every error is reported, and the valid rules are still compiled.
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, 2, result.Errors[0].Line)
+	assert.Equal(t, 3, result.Errors[1].Line)
+	for _, d := range result.Errors {
+		assert.Equal(t, cssc.CodeSyntax, d.Code)
+	}
+
+	for _, out := range result.Files {
+		assert.Equal(t, ".a{color:red}.c{color:green}", out)
+	}
+	assert.Len(t, result.Files, 1)
+}
+
+func TestDiagnostics_Messages(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/simple/index.css"},
+		Outdir:   "out",
+		Outfile:  "out.css",
+		Reporter: &errors,
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "Outfile and Outdir cannot both be set", result.Errors[0].Message)
+	assert.Equal(t, "Outfile and Outdir cannot both be set", result.Errors[0].Error())
+
+	result = cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/simple/index.css", "testdata/crlf/monaco.css"},
+		Outfile:  "out.css",
+		Reporter: &errors,
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "Outfile can only be used with a single output, but there are 2 outputs", result.Errors[0].Message)
+
+	result = cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/simple/index.css"},
+		Outdir:   "out",
+		Outbase:  "testdata/nested",
+		Reporter: &errors,
+	})
+	require.Len(t, result.Errors, 1)
+	abs, err := filepath.Abs("testdata/simple/index.css")
+	require.NoError(t, err)
+	base, err := filepath.Abs("testdata/nested")
+	require.NoError(t, err)
+	assert.Equal(t, abs+" is outside of the output base directory "+base, result.Errors[0].Message)
+
+	result = cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/nonexistent/index.css"},
+		Reporter: &errors,
+	})
+	require.Len(t, result.Errors, 1)
+	assert.NotContains(t, result.Errors[0].Message, "\n")
+	assert.Contains(t, result.Errors[0].Message, "failed to read file")
+}
@@ -2,53 +2,53 @@ package esbuildplugin
 
 import (
 	"context"
+	"errors"
+	"os"
 	"runtime/pprof"
+	"strings"
 
 	"github.com/evanw/esbuild/pkg/api"
 	"github.com/samsarahq/go/oops"
 	"github.com/stephen/cssc"
-	"github.com/stephen/cssc/internal/ast"
-	"github.com/stephen/cssc/internal/sources"
+	"github.com/stephen/cssc/internal/logging"
 	"github.com/stephen/cssc/transforms"
 )
 
-type csscReporter []error
+// discardReporter drops diagnostics as they are reported. They are read from the result
+// instead, which has them sorted and split into errors and warnings.
+type discardReporter struct{}
 
-func (r *csscReporter) AddError(err error) {
-	*r = append(*r, err)
-}
-
-type locationError interface {
-	Location() (*sources.Source, ast.Span)
-}
+func (discardReporter) AddError(error) {}
 
-func (r *csscReporter) toEsbuild() []api.Message {
-	var errs []api.Message
-	for _, err := range *r {
+// toEsbuild converts cssc diagnostics to esbuild messages.
+func toEsbuild(diagnostics []*cssc.Diagnostic) []api.Message {
+	msgs := make([]api.Message, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		msg := api.Message{
+			Text:   d.Message,
+			Detail: d,
+		}
 
-		if lErr, ok := err.(locationError); ok {
-			source, span := lErr.Location()
-			_, colNumber := source.LineAndCol(span)
+		if source, span, _, ok := logging.LocationOf(d); ok {
 			lineSpan := source.FullLine(span)
-
-			errs = append(errs, api.Message{
-				Text: err.Error(),
-				Location: &api.Location{
-					File:     source.Path,
-					Column:   int(colNumber),
-					Line:     lineSpan.Start,
-					Length:   lineSpan.End - lineSpan.Start,
-					LineText: source.Content[lineSpan.Start:lineSpan.End],
-				},
-			})
+			length := span.End - span.Start
+			if max := lineSpan.End - span.Start; length > max {
+				length = max
+			}
+
+			// esbuild lines are 1-based, like cssc's, but its columns are 0-based.
+			msg.Location = &api.Location{
+				File:     source.Path,
+				Line:     d.Line,
+				Column:   d.Column - 1,
+				Length:   length,
+				LineText: source.Content[lineSpan.Start:lineSpan.End],
+			}
 		}
 
-		errs = append(errs, api.Message{
-			Text: err.Error(),
-		})
+		msgs = append(msgs, msg)
 	}
-
-	return errs
+	return msgs
 }
 
 // Option is an optional argument to the plugin.
@@ -70,30 +70,111 @@ func WithResolver(resolver cssc.Resolver) Option {
 	}
 }
 
-// Plugin is an esbuild plugin for importing .css files.
+// resolving is the PluginData of resolutions made by Resolver, so that the plugin's own
+// OnResolve hook doesn't handle them again.
+type resolving struct{}
+
+// Resolver returns a cssc.Resolver that resolves imports with esbuild, so that CSS imports
+// follow the same aliases, tsconfig paths, and external settings as the rest of the build.
+// Imports that esbuild marks as external are left as-is.
+func Resolver(build api.PluginBuild) cssc.Resolver {
+	return esbuildResolver{build}
+}
+
+type esbuildResolver struct {
+	build api.PluginBuild
+}
+
+// Resolve implements cssc.Resolver.
+func (r esbuildResolver) Resolve(spec, fromDir string) (string, error) {
+	res := r.build.Resolve(spec, api.ResolveOptions{
+		PluginName: "cssc",
+		ResolveDir: fromDir,
+		Kind:       api.ResolveCSSImportRule,
+		PluginData: resolving{},
+	})
+	if len(res.Errors) > 0 {
+		texts := make([]string, len(res.Errors))
+		for i, msg := range res.Errors {
+			texts[i] = msg.Text
+		}
+		return "", oops.Errorf("could not resolve %s relative to %s: %s", spec, fromDir, strings.Join(texts, "; "))
+	}
+
+	if res.External {
+		return "", cssc.ErrExternal
+	}
+	if res.Namespace != "file" {
+		return "", oops.Errorf("%s resolved to %s in the %s namespace, but only files can be imported", spec, res.Path, res.Namespace)
+	}
+	return res.Path, nil
+}
+
+// Plugin is an esbuild plugin for importing .css files. Imports are resolved with esbuild
+// unless WithResolver is used.
 func Plugin(opts ...Option) api.Plugin {
 	return api.Plugin{
 		Name: "cssc",
 		Setup: func(build api.PluginBuild) {
+			var defaults cssc.Options
+			for _, opt := range opts {
+				defaults = opt(defaults)
+			}
+			if defaults.Resolver == nil {
+				defaults.Resolver = Resolver(build)
+			}
+			if defaults.Modules != nil && defaults.Modules.Root == "" && defaults.Outbase == "" {
+				// Each file is compiled on its own, so names are hashed relative to the
+				// build's working directory instead of the file's own directory.
+				modules := *defaults.Modules
+				modules.Root = build.InitialOptions.AbsWorkingDir
+				if modules.Root == "" {
+					modules.Root, _ = os.Getwd()
+				}
+				defaults.Modules = &modules
+			}
+
+			// @import rules that are left for esbuild, e.g. with ImportRulesPassthrough, are
+			// resolved the same way as the ones that cssc follows.
+			build.OnResolve(
+				api.OnResolveOptions{Filter: `.*`},
+				func(args api.OnResolveArgs) (res api.OnResolveResult, err error) {
+					if _, ok := args.PluginData.(resolving); ok || args.Kind != api.ResolveCSSImportRule {
+						return
+					}
+
+					path, err := defaults.Resolver.Resolve(args.Path, args.ResolveDir)
+					if errors.Is(err, cssc.ErrExternal) {
+						res.External = true
+						res.Path = args.Path
+						return res, nil
+					}
+					if err != nil {
+						return res, err
+					}
+
+					res.Path = path
+					return res, nil
+				},
+			)
+
 			build.OnLoad(
 				api.OnLoadOptions{Filter: `\.css$`},
 				func(args api.OnLoadArgs) (res api.OnLoadResult, err error) {
 					res.Loader = api.LoaderCSS
 
-					var errors csscReporter
-					options := cssc.Options{
-						Entry:    []string{args.Path},
-						Reporter: &errors,
-					}
-					for _, opt := range opts {
-						options = opt(options)
-					}
+					options := defaults
+					options.Entry = []string{args.Path}
+					options.Reporter = discardReporter{}
 
 					pprof.SetGoroutineLabels(pprof.WithLabels(context.TODO(), pprof.Labels("cssc-path", args.Path)))
 					result := cssc.Compile(options)
 
-					if len(errors) > 0 {
-						res.Errors = errors.toEsbuild()
+					// Rebuild when the file or anything it imports changes.
+					res.WatchFiles = result.Inputs
+					res.Warnings = toEsbuild(result.Warnings)
+					if len(result.Errors) > 0 {
+						res.Errors = toEsbuild(result.Errors)
 						return
 					}
 
@@ -0,0 +1,99 @@
+package esbuildplugin_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/stephen/cssc/esbuildplugin"
+	"github.com/stephen/cssc/resolver"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlugin_Resolve(t *testing.T) {
+	dir, err := filepath.Abs("testdata")
+	require.NoError(t, err)
+
+	for _, rules := range []transforms.ImportRules{
+		transforms.ImportRulesInline,
+		transforms.ImportRulesPassthrough,
+	} {
+		result := api.Build(api.BuildOptions{
+			EntryPoints:   []string{"src/index.css"},
+			AbsWorkingDir: dir,
+			Bundle:        true,
+			Outdir:        "dist",
+			Alias:         map[string]string{"@theme": "./theme"},
+			External:      []string{"/static/*"},
+			LogLevel:      api.LogLevelSilent,
+			Plugins: []api.Plugin{esbuildplugin.Plugin(esbuildplugin.WithTransforms(transforms.Options{
+				ImportRules: rules,
+			}))},
+		})
+
+		require.Empty(t, result.Errors)
+		require.Len(t, result.OutputFiles, 1)
+		out := string(result.OutputFiles[0].Contents)
+		// The external import comes first even though it follows an inlined one.
+		assert.True(t, strings.HasPrefix(out, `@import "/static/reset.css";`), out)
+		assert.Contains(t, out, ".brand {")
+		assert.Contains(t, out, ".index {")
+	}
+}
+
+// Load runs the plugin's OnLoad callback for path.
+func Load(t testing.TB, path string, opts ...esbuildplugin.Option) api.OnLoadResult {
+	var onLoad func(api.OnLoadArgs) (api.OnLoadResult, error)
+	esbuildplugin.Plugin(opts...).Setup(api.PluginBuild{
+		OnResolve: func(api.OnResolveOptions, func(api.OnResolveArgs) (api.OnResolveResult, error)) {},
+		OnLoad: func(_ api.OnLoadOptions, callback func(api.OnLoadArgs) (api.OnLoadResult, error)) {
+			onLoad = callback
+		},
+	})
+
+	abs, err := filepath.Abs(path)
+	require.NoError(t, err)
+	res, err := onLoad(api.OnLoadArgs{Path: abs, Namespace: "file"})
+	require.NoError(t, err)
+	return res
+}
+
+func TestPlugin_Warnings(t *testing.T) {
+	res := Load(t, "testdata/diagnostics/index.css",
+		esbuildplugin.WithResolver(&resolver.NodeResolver{}),
+		esbuildplugin.WithTransforms(transforms.Options{
+			ImportRules:   transforms.ImportRulesInline,
+			CascadeLayers: transforms.CascadeLayersTransform,
+		}))
+
+	require.NotNil(t, res.Contents)
+	assert.Empty(t, res.Errors)
+	require.Len(t, res.Warnings, 1)
+	loc := res.Warnings[0].Location
+	require.NotNil(t, loc)
+	assert.True(t, strings.HasSuffix(loc.File, "index.css"), loc.File)
+	assert.Equal(t, 5, loc.Line)
+	assert.Equal(t, 4, loc.Column)
+	assert.Equal(t, "    color: red !important;", loc.LineText)
+
+	var watched []string
+	for _, path := range res.WatchFiles {
+		watched = append(watched, filepath.Base(path))
+	}
+	assert.Equal(t, []string{"index.css", "partial.css"}, watched)
+}
+
+func TestPlugin_Errors(t *testing.T) {
+	res := Load(t, "testdata/diagnostics/broken.css", esbuildplugin.WithResolver(&resolver.NodeResolver{}))
+
+	assert.Nil(t, res.Contents)
+	require.Len(t, res.Errors, 1)
+	loc := res.Errors[0].Location
+	require.NotNil(t, loc)
+	assert.Equal(t, 4, loc.Line)
+	assert.Equal(t, 19, loc.Column)
+	assert.Equal(t, ".b { color: red; } }", loc.LineText)
+}
@@ -4,7 +4,7 @@ go 1.14
 
 require (
 	github.com/davecgh/go-spew v1.1.1
-	github.com/evanw/esbuild v0.8.6
+	github.com/evanw/esbuild v0.17.19
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/samsarahq/go v0.0.0-20191220233105-8077c9fbaed5
 	github.com/stretchr/testify v1.6.1
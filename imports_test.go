@@ -1,11 +1,15 @@
 package cssc_test
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/resolver"
 	"github.com/stephen/cssc/transforms"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestImports(t *testing.T) {
@@ -57,3 +61,188 @@ func TestImports(t *testing.T) {
 		assert.Len(t, errors, 0)
 	})
 }
+
+func TestImports_Layers(t *testing.T) {
+	t.Run("passthrough", func(t *testing.T) {
+		var errors TestReporter
+		result := cssc.Compile(cssc.Options{
+			Entry: []string{
+				"testdata/layers/index.css",
+			},
+			Reporter: &errors,
+			Transforms: transforms.Options{
+				ImportRules: transforms.ImportRulesInline,
+			},
+		})
+
+		assert.Len(t, errors, 0)
+		require.Len(t, result.Files, 1)
+		for _, out := range result.Files {
+			assert.True(t, strings.HasPrefix(out, `@layer reset,base;@layer base{.title{color:red}}@layer reset{*{margin:0}}.title{color:blue}`), out)
+		}
+	})
+
+	t.Run("transform", func(t *testing.T) {
+		var errors TestReporter
+		result := cssc.Compile(cssc.Options{
+			Entry: []string{
+				"testdata/layers/index.css",
+			},
+			Reporter: &errors,
+			Transforms: transforms.Options{
+				ImportRules:   transforms.ImportRulesInline,
+				CascadeLayers: transforms.CascadeLayersTransform,
+			},
+		})
+
+		assert.Len(t, errors, 0)
+		require.Len(t, result.Files, 1)
+		for _, out := range result.Files {
+			assert.True(t, strings.HasPrefix(out, `*{margin:0}.title{color:red}.title{color:blue}`), out)
+		}
+	})
+}
+
+func TestImports_Cycle(t *testing.T) {
+	compile := func(rules transforms.ImportRules) (*cssc.Result, TestReporter) {
+		var errors TestReporter
+		result := cssc.Compile(cssc.Options{
+			Entry: []string{
+				"testdata/importcycle/a.css",
+			},
+			Reporter: &errors,
+			Transforms: transforms.Options{
+				ImportRules: rules,
+			},
+		})
+		return result, errors
+	}
+
+	a, err := filepath.Abs("testdata/importcycle/a.css")
+	require.NoError(t, err)
+
+	// The import that closes the cycle is removed from the inlined output.
+	result, errors := compile(transforms.ImportRulesInline)
+	assert.Equal(t, `.c{color:green}.b{color:blue}.a{color:red}`, result.Files[a])
+	require.Len(t, errors, 1)
+	assert.Contains(t, errors[0].Error(), "testdata/importcycle/c.css:1:1")
+	assert.Contains(t, errors[0].Error(), "import cycle: testdata/importcycle/a.css -> testdata/importcycle/b.css -> testdata/importcycle/c.css -> testdata/importcycle/a.css")
+	require.Len(t, result.Errors, 1)
+
+	// Browsers handle cycles in imports that are left for them.
+	result, errors = compile(transforms.ImportRulesFollow)
+	assert.Len(t, result.Files, 3)
+	assert.Empty(t, result.Errors)
+	require.Len(t, result.Warnings, 1)
+	assert.Contains(t, result.Warnings[0].Message, "import cycle: testdata/importcycle/a.css -> testdata/importcycle/b.css -> testdata/importcycle/c.css -> testdata/importcycle/a.css")
+	assert.Len(t, errors, 1)
+
+	result, errors = compile(transforms.ImportRulesPassthrough)
+	assert.Equal(t, `@import "./b.css";.a{color:red}`, result.Files[a])
+	assert.Empty(t, result.Errors)
+	assert.Empty(t, result.Warnings)
+	assert.Empty(t, errors)
+}
+
+func TestImports_Duplicates(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry: []string{
+			"testdata/duplicateimports/index.css",
+		},
+		Reporter: &errors,
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+
+	assert.Len(t, errors, 0)
+	require.Len(t, result.Files, 1)
+	for _, out := range result.Files {
+		assert.True(t, strings.HasPrefix(out, `*{margin:0}.base{color:green}.theme{color:blue}@media print{*{margin:0}.base{color:green}}.index{color:red}`), out)
+	}
+}
+
+func TestImports_Shared(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry: []string{
+			"testdata/sharedimports/index.module.css",
+			"testdata/sharedimports/other.module.css",
+		},
+		Reporter: &errors,
+		Modules:  &cssc.ModulesOptions{Pattern: "[name]_[local]"},
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+			Nesting:     transforms.NestingTransform,
+		},
+	})
+
+	// base.css is transformed separately for each import and each output.
+	assert.Len(t, errors, 0)
+	index, err := filepath.Abs("testdata/sharedimports/index.module.css")
+	require.NoError(t, err)
+	other, err := filepath.Abs("testdata/sharedimports/other.module.css")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		index: `@media print{.index_a{color:red}.index_a .index_b{color:blue}}.index_a{color:red}.index_a .index_b{color:blue}`,
+		other: `.other_a{color:red}.other_a .other_b{color:blue}`,
+	}, result.Files)
+}
+
+// externalResolver resolves imports with the default resolver, except for External, which
+// is left as-is.
+type externalResolver struct {
+	External string
+}
+
+func (r externalResolver) Resolve(spec, fromDir string) (string, error) {
+	if spec == r.External {
+		return "", cssc.ErrExternal
+	}
+	return (&resolver.NodeResolver{}).Resolve(spec, fromDir)
+}
+
+func TestImports_External(t *testing.T) {
+	for _, rules := range []transforms.ImportRules{
+		transforms.ImportRulesInline,
+		transforms.ImportRulesFollow,
+	} {
+		var errors TestReporter
+		result := cssc.Compile(cssc.Options{
+			Entry: []string{
+				"testdata/imports/index.css",
+			},
+			Resolver: externalResolver{External: "./other.css"},
+			Reporter: &errors,
+			Transforms: transforms.Options{
+				ImportRules: rules,
+			},
+		})
+
+		assert.Len(t, errors, 0)
+		require.Len(t, result.Files, 1)
+		for _, out := range result.Files {
+			assert.True(t, strings.HasPrefix(out, `@import "./other.css";div{`), out)
+		}
+	}
+
+	// External imports are hoisted above inlined rules, which would make browsers ignore them.
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry: []string{
+			"testdata/imports/index.css",
+		},
+		Resolver: externalResolver{External: "./another.css"},
+		Reporter: &errors,
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+
+	assert.Len(t, errors, 0)
+	require.Len(t, result.Files, 1)
+	for _, out := range result.Files {
+		assert.True(t, strings.HasPrefix(out, `@import "./another.css";.other{padding:10rem}div{`), out)
+	}
+}
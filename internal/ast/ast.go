@@ -56,7 +56,9 @@ type DeclarationBlock struct {
 	Declarations []Declarationish
 }
 
-// Declarationish is a Declaration or a Raw value.
+// Declarationish is a Declaration or a Raw value. With CSS Nesting, it can
+// also be a nested QualifiedRule or AtRule.
+// See https://www.w3.org/TR/css-nesting-1/.
 type Declarationish interface {
 	Node
 	isDeclaration()
@@ -66,7 +68,9 @@ type Declarationish interface {
 type QualifiedRuleBlock struct {
 	Span
 
-	Rules []*QualifiedRule
+	// Rules is the set of rules in the block. Rules are usually QualifiedRules,
+	// but blocks like @supports or @media can also contain AtRules.
+	Rules []Node
 }
 
 func (DeclarationBlock) isBlock()   {}
@@ -89,8 +93,12 @@ type Declaration struct {
 	Important bool
 }
 
-func (Declaration) isDeclaration() {}
-func (Raw) isDeclaration()         {}
+func (Declaration) isDeclaration()   {}
+func (Raw) isDeclaration()           {}
+func (QualifiedRule) isDeclaration() {}
+func (AtRule) isDeclaration()        {}
 
 var _ Declarationish = Declaration{}
 var _ Declarationish = Raw{}
+var _ Declarationish = QualifiedRule{}
+var _ Declarationish = AtRule{}
@@ -14,8 +14,13 @@ type AtRule struct {
 func (String) isAtPrelude()     {}
 func (Identifier) isAtPrelude() {}
 
+// isAtPrelude implements AtPrelude for functional @import conditions,
+// e.g. layer(base).
+func (Function) isAtPrelude() {}
+
 var _ AtPrelude = String{}
 var _ AtPrelude = Identifier{}
+var _ AtPrelude = Function{}
 
 // AtPrelude is the set of arguments for an at-rule.
 // The interface is only used for type discrimination.
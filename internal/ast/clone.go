@@ -0,0 +1,79 @@
+package ast
+
+import "reflect"
+
+// Cloner makes deep copies of nodes. Pointers that are shared between nodes,
+// e.g. an @import rule and its ImportSpecifier, are also shared between the copies.
+type Cloner struct {
+	copies map[interface{}]reflect.Value
+}
+
+// NewCloner creates a new Cloner.
+func NewCloner() *Cloner {
+	return &Cloner{copies: make(map[interface{}]reflect.Value)}
+}
+
+// Stylesheet returns a deep copy of s.
+func (c *Cloner) Stylesheet(s *Stylesheet) *Stylesheet {
+	return c.clone(reflect.ValueOf(s)).Interface().(*Stylesheet)
+}
+
+// Node returns a deep copy of n.
+func (c *Cloner) Node(n Node) Node {
+	return c.clone(reflect.ValueOf(&n).Elem()).Interface().(Node)
+}
+
+// Copy returns the copy of ptr, which must be a pointer to a node that has already
+// been copied. If ptr has not been copied, nil is returned.
+func (c *Cloner) Copy(ptr interface{}) interface{} {
+	v, ok := c.copies[ptr]
+	if !ok {
+		return nil
+	}
+	return v.Interface()
+}
+
+func (c *Cloner) clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		if copied, ok := c.copies[v.Interface()]; ok {
+			return copied
+		}
+		copied := reflect.New(v.Type().Elem())
+		c.copies[v.Interface()] = copied
+		copied.Elem().Set(c.clone(v.Elem()))
+		return copied
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(c.clone(v.Elem()))
+		return copied
+
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			copied.Field(i).Set(c.clone(v.Field(i)))
+		}
+		return copied
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(c.clone(v.Index(i)))
+		}
+		return copied
+
+	default:
+		// Everything else in the AST is an immutable value, e.g. strings.
+		return v
+	}
+}
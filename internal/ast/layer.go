@@ -0,0 +1,15 @@
+package ast
+
+// LayerList is the prelude for @layer rules. It's a comma-separated list
+// of layer names, e.g. @layer base, components;. Each name is an Identifier
+// and may reference a sublayer with dots, e.g. framework.base.
+// See: https://www.w3.org/TR/css-cascade-5/#layering.
+type LayerList struct {
+	Span
+
+	Names []*Identifier
+}
+
+func (LayerList) isAtPrelude() {}
+
+var _ AtPrelude = LayerList{}
@@ -35,11 +35,13 @@ func (Identifier) isMediaQueryPart()        {}
 func (MediaFeaturePlain) isMediaQueryPart() {}
 func (MediaFeatureRange) isMediaQueryPart() {}
 func (MediaInParens) isMediaQueryPart()     {}
+func (GeneralEnclosed) isMediaQueryPart()   {}
 
 var _ MediaQueryPart = Identifier{}
 var _ MediaQueryPart = MediaFeaturePlain{}
 var _ MediaQueryPart = MediaFeatureRange{}
 var _ MediaQueryPart = MediaInParens{}
+var _ MediaQueryPart = GeneralEnclosed{}
 
 // MediaInParens is a media expression in parenthesis. It is
 // different from MediaQuery in that it implements MediaQueryPart.
@@ -98,6 +98,12 @@ type ANPlusB struct {
 
 func (ANPlusB) isPseudoClassArguments() {}
 
+// isPseudoClassArguments implements PseudoClassArguments so that the path of an
+// ICSS :import() can be represented.
+func (String) isPseudoClassArguments() {}
+
+var _ PseudoClassArguments = String{}
+
 var _ PseudoClassArguments = ANPlusB{}
 
 // PseudoElementSelector selects a pseudo element, e.g. ::before or ::after.
@@ -107,6 +113,13 @@ type PseudoElementSelector struct {
 	Inner *PseudoClassSelector
 }
 
+// NestingSelector is the & selector, which represents the parent rule's
+// selector list in a nested rule.
+// See: https://www.w3.org/TR/css-nesting-1/#nest-selector.
+type NestingSelector struct {
+	Span
+}
+
 // Whitespace represents any whitespace sequence. Whitespace is
 // only kept in the AST when necessary for disambiguating syntax,
 // e.g. in selectors.
@@ -140,6 +153,7 @@ var _ SelectorPart = IDSelector{}
 var _ SelectorPart = CombinatorSelector{}
 var _ SelectorPart = PseudoClassSelector{}
 var _ SelectorPart = PseudoElementSelector{}
+var _ SelectorPart = NestingSelector{}
 var _ SelectorPart = Whitespace{}
 var _ SelectorPart = AttributeSelector{}
 
@@ -149,5 +163,6 @@ func (IDSelector) isSelector()            {}
 func (CombinatorSelector) isSelector()    {}
 func (PseudoClassSelector) isSelector()   {}
 func (PseudoElementSelector) isSelector() {}
+func (NestingSelector) isSelector()       {}
 func (Whitespace) isSelector()            {}
 func (AttributeSelector) isSelector()     {}
@@ -0,0 +1,77 @@
+package ast
+
+// SupportsCondition is the prelude for @supports rules. It is a
+// set of feature tests joined by a single boolean operator.
+// See: https://www.w3.org/TR/css-conditional-3/#at-supports.
+type SupportsCondition struct {
+	Span
+
+	// Operator is not, and, or or. If Operator is empty, then Parts
+	// contains exactly one part, e.g. @supports (display: grid).
+	Operator string
+
+	// Parts is the set of operands for Operator. If Operator is not,
+	// then there is exactly one part.
+	Parts []SupportsConditionPart
+}
+
+func (SupportsCondition) isAtPrelude() {}
+
+var _ AtPrelude = SupportsCondition{}
+
+// SupportsConditionPart is an operand of a SupportsCondition. A nested
+// SupportsCondition as a part is always parenthesized.
+type SupportsConditionPart interface {
+	Node
+
+	isSupportsConditionPart()
+}
+
+// SupportsDeclaration tests whether or not a declaration is supported,
+// e.g. (display: grid).
+type SupportsDeclaration struct {
+	Span
+
+	Declaration *Declaration
+}
+
+// SupportsSelector tests whether or not a selector is supported,
+// e.g. selector(:focus-visible).
+type SupportsSelector struct {
+	Span
+
+	Selector *Selector
+}
+
+// GeneralEnclosed is a function or parenthesized test that is not otherwise known, e.g.
+// font-tech(color-COLRv1). It is kept as-is.
+// See: https://www.w3.org/TR/mediaqueries-4/#typedef-general-enclosed.
+type GeneralEnclosed struct {
+	Span
+
+	// Value is the source text of the test, including the parenthesis.
+	Value string
+}
+
+func (SupportsCondition) isSupportsConditionPart()   {}
+func (SupportsDeclaration) isSupportsConditionPart() {}
+func (SupportsSelector) isSupportsConditionPart()    {}
+func (GeneralEnclosed) isSupportsConditionPart()     {}
+
+var _ SupportsConditionPart = SupportsCondition{}
+var _ SupportsConditionPart = SupportsDeclaration{}
+var _ SupportsConditionPart = SupportsSelector{}
+var _ SupportsConditionPart = GeneralEnclosed{}
+
+// ImportSupports is the supports() condition of an @import rule,
+// e.g. @import "grid.css" supports(display: grid).
+// See: https://www.w3.org/TR/css-cascade-5/#conditional-import.
+type ImportSupports struct {
+	Span
+
+	Condition *SupportsCondition
+}
+
+func (ImportSupports) isAtPrelude() {}
+
+var _ AtPrelude = ImportSupports{}
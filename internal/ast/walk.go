@@ -48,6 +48,11 @@ func Walk(start Node, visit func(n Node)) {
 			Walk(part, visit)
 		}
 
+	case *MediaInParens:
+		for _, part := range s.Parts {
+			Walk(part, visit)
+		}
+
 	case *MediaFeaturePlain:
 		Walk(s.Property, visit)
 		Walk(s.Value, visit)
@@ -81,6 +86,25 @@ func Walk(start Node, visit func(n Node)) {
 		Walk(s.Left, visit)
 		Walk(s.Right, visit)
 
+	case *SupportsCondition:
+		for _, part := range s.Parts {
+			Walk(part, visit)
+		}
+
+	case *SupportsDeclaration:
+		Walk(s.Declaration, visit)
+
+	case *SupportsSelector:
+		Walk(s.Selector, visit)
+
+	case *ImportSupports:
+		Walk(s.Condition, visit)
+
+	case *LayerList:
+		for _, name := range s.Names {
+			Walk(name, visit)
+		}
+
 	case *KeyframeSelectorList:
 		for _, k := range s.Selectors {
 			Walk(k, visit)
@@ -91,6 +115,11 @@ func Walk(start Node, visit func(n Node)) {
 			Walk(arg, visit)
 		}
 
+	case *Brackets:
+		for _, v := range s.Values {
+			Walk(v, visit)
+		}
+
 	case *PseudoElementSelector:
 		Walk(s.Inner, visit)
 
@@ -102,12 +131,15 @@ func Walk(start Node, visit func(n Node)) {
 	case *CombinatorSelector:
 	case *PseudoClassSelector:
 	case *ANPlusB:
+	case *NestingSelector:
 	case *HexColor:
 	case *Percentage:
 	case *Dimension:
 	case *Whitespace:
 	case *Identifier:
+	case *MediaType:
 	case *Raw:
+	case *GeneralEnclosed:
 
 	default:
 		panic(fmt.Errorf("unknown node type: %s", reflect.TypeOf(s).String()))
@@ -0,0 +1,251 @@
+// Package browsers resolves browserslist-style target queries against an embedded table
+// of browser releases and feature support.
+package browsers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Browser is a browser that can be targeted.
+type Browser int
+
+const (
+	Chrome Browser = iota
+	Edge
+	Firefox
+	Safari
+	IOSSafari
+	Opera
+	Samsung
+	IE
+)
+
+// Browsers is the set of all browsers, in order.
+var Browsers = []Browser{Chrome, Edge, Firefox, Safari, IOSSafari, Opera, Samsung, IE}
+
+var browserNames = [...]string{
+	Chrome:    "chrome",
+	Edge:      "edge",
+	Firefox:   "firefox",
+	Safari:    "safari",
+	IOSSafari: "ios_saf",
+	Opera:     "opera",
+	Samsung:   "samsung",
+	IE:        "ie",
+}
+
+func (b Browser) String() string {
+	return browserNames[b]
+}
+
+// browserAliases is the set of names that can be used for browsers in queries.
+var browserAliases = map[string]Browser{
+	"chrome":   Chrome,
+	"and_chr":  Chrome,
+	"edge":     Edge,
+	"firefox":  Firefox,
+	"ff":       Firefox,
+	"and_ff":   Firefox,
+	"safari":   Safari,
+	"ios_saf":  IOSSafari,
+	"ios":      IOSSafari,
+	"opera":    Opera,
+	"samsung":  Samsung,
+	"ie":       IE,
+	"explorer": IE,
+}
+
+// Version is a browser version, e.g. 13.1.
+type Version struct {
+	Major, Minor int
+}
+
+// Less returns whether or not v is an earlier version than o.
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	return v.Minor < o.Minor
+}
+
+func (v Version) String() string {
+	if v.Minor == 0 {
+		return strconv.Itoa(v.Major)
+	}
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// ParseVersion parses a version like 80 or 13.1. A range like 13.4-13.7 is parsed
+// as its first version.
+func ParseVersion(s string) (Version, error) {
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s = s[:i]
+	}
+
+	major, minor := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		major, minor = s[:i], s[i+1:]
+	}
+
+	var v Version
+	var err error
+	if v.Major, err = strconv.Atoi(major); err != nil || v.Major < 0 {
+		return Version{}, fmt.Errorf("invalid version: %s", s)
+	}
+	if minor != "" {
+		if v.Minor, err = strconv.Atoi(minor); err != nil || v.Minor < 0 {
+			return Version{}, fmt.Errorf("invalid version: %s", s)
+		}
+	}
+	return v, nil
+}
+
+// Targets is the oldest version of each browser to support. Browsers that aren't
+// in Targets don't need to be supported.
+type Targets map[Browser]Version
+
+// Before returns whether or not any targeted version of b is earlier than v.
+func (t Targets) Before(b Browser, v Version) bool {
+	min, ok := t[b]
+	return ok && min.Less(v)
+}
+
+func (t Targets) add(b Browser, v Version) {
+	if min, ok := t[b]; !ok || v.Less(min) {
+		t[b] = v
+	}
+}
+
+func (t Targets) String() string {
+	parts := make([]string, 0, len(t))
+	for _, b := range Browsers {
+		if v, ok := t[b]; ok {
+			parts = append(parts, fmt.Sprintf("%s %s", b, v))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Parse resolves a browserslist-style query, e.g. "chrome 80, safari >= 13" or "last 2 versions".
+// Queries are separated by commas or "or". The supported queries are:
+//
+//	<browser> <version>              e.g. chrome 80 or ios_saf 13.4-13.7
+//	<browser> >=, >, <=, or < <version>
+//	last <n> versions                the last n releases of every browser that is still maintained
+//	last <n> <browser> versions
+//
+// Queries that depend on usage statistics, like > 1%, are not supported.
+func Parse(query string) (Targets, error) {
+	targets := make(Targets)
+	for _, q := range splitQuery(query) {
+		if err := targets.parseQuery(q); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no browsers matched targets: %q", query)
+	}
+	return targets, nil
+}
+
+func splitQuery(query string) []string {
+	var rv []string
+	for _, q := range strings.Split(query, ",") {
+		for _, q := range strings.Split(q, " or ") {
+			if q = strings.TrimSpace(q); q != "" {
+				rv = append(rv, q)
+			}
+		}
+	}
+	return rv
+}
+
+func (t Targets) parseQuery(query string) error {
+	fields := strings.Fields(strings.ToLower(query))
+
+	if fields[0] == "last" {
+		return t.parseLastQuery(query, fields)
+	}
+
+	if len(fields) < 2 {
+		return fmt.Errorf("unknown target query: %s", query)
+	}
+
+	b, ok := browserAliases[fields[0]]
+	if !ok {
+		return fmt.Errorf("unknown browser %q in target query: %s", fields[0], query)
+	}
+
+	op, version := "", fields[1]
+	switch {
+	case len(fields) == 3:
+		op, version = fields[1], fields[2]
+	case len(fields) == 2 && strings.ContainsAny(fields[1][:1], "<>"):
+		op = strings.TrimRight(fields[1], "0123456789.")
+		version = fields[1][len(op):]
+	case len(fields) != 2:
+		return fmt.Errorf("unknown target query: %s", query)
+	}
+
+	v, err := ParseVersion(version)
+	if err != nil {
+		return fmt.Errorf("%s in target query: %s", err, query)
+	}
+
+	releases := releases[b]
+	switch op {
+	case "", ">=":
+		t.add(b, v)
+
+	case ">":
+		i := sort.Search(len(releases), func(i int) bool { return v.Less(releases[i]) })
+		if i == len(releases) {
+			return fmt.Errorf("no %s releases after %s in target query: %s", b, v, query)
+		}
+		t.add(b, releases[i])
+
+	case "<", "<=":
+		if op == "<" && !releases[0].Less(v) {
+			return fmt.Errorf("no %s releases before %s in target query: %s", b, v, query)
+		}
+		t.add(b, releases[0])
+
+	default:
+		return fmt.Errorf("unknown operator %q in target query: %s", op, query)
+	}
+	return nil
+}
+
+func (t Targets) parseLastQuery(query string, fields []string) error {
+	if len(fields) != 3 && len(fields) != 4 || fields[len(fields)-1] != "versions" && fields[len(fields)-1] != "version" {
+		return fmt.Errorf("unknown target query: %s", query)
+	}
+
+	n, err := strconv.Atoi(fields[1])
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid number of versions in target query: %s", query)
+	}
+
+	browsers := maintained
+	if len(fields) == 4 {
+		b, ok := browserAliases[fields[2]]
+		if !ok {
+			return fmt.Errorf("unknown browser %q in target query: %s", fields[2], query)
+		}
+		browsers = []Browser{b}
+	}
+
+	for _, b := range browsers {
+		releases := releases[b]
+		if n > len(releases) {
+			t.add(b, releases[0])
+			continue
+		}
+		t.add(b, releases[len(releases)-n])
+	}
+	return nil
+}
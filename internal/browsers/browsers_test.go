@@ -0,0 +1,72 @@
+package browsers_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/browsers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	targets, err := browsers.Parse("chrome 80, Safari >= 13.1, ios_saf 13.4-13.7 or firefox > 90")
+	require.NoError(t, err)
+	assert.Equal(t, browsers.Targets{
+		browsers.Chrome:    {Major: 80},
+		browsers.Safari:    {Major: 13, Minor: 1},
+		browsers.IOSSafari: {Major: 13, Minor: 4},
+		browsers.Firefox:   {Major: 91},
+	}, targets)
+	assert.Equal(t, "chrome 80, firefox 91, safari 13.1, ios_saf 13.4", targets.String())
+
+	// The oldest version of each browser wins.
+	targets, err = browsers.Parse("chrome 90, chrome 80, ie < 9")
+	require.NoError(t, err)
+	assert.Equal(t, browsers.Targets{browsers.Chrome: {Major: 80}, browsers.IE: {Major: 5}}, targets)
+}
+
+func TestParse_Last(t *testing.T) {
+	targets, err := browsers.Parse("last 2 versions")
+	require.NoError(t, err)
+	assert.Len(t, targets, 7)
+	assert.Equal(t, browsers.Version{Major: 130}, targets[browsers.Chrome])
+	assert.Equal(t, browsers.Version{Major: 18}, targets[browsers.Safari])
+	assert.NotContains(t, targets, browsers.IE)
+
+	targets, err = browsers.Parse("last 1 firefox version")
+	require.NoError(t, err)
+	assert.Equal(t, browsers.Targets{browsers.Firefox: {Major: 132}}, targets)
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, query := range []string{"", "chrome", "netscape 4", "chrome ~80", "firefox>90", "chrome 8a", "> 1%", "last two versions", "safari > 99", "ie < 5"} {
+		_, err := browsers.Parse(query)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	p := browsers.Properties["user-select"][0]
+	assert.Equal(t, "-webkit-user-select", p.Name)
+	assert.Equal(t, "-webkit-", p.Vendor())
+	assert.True(t, p.Needed(browsers.Targets{browsers.Chrome: {Major: 53}}))
+	assert.False(t, p.Needed(browsers.Targets{browsers.Chrome: {Major: 54}}))
+	assert.True(t, p.Needed(browsers.Targets{browsers.Safari: {Major: 18}}))
+	assert.False(t, p.Needed(browsers.Targets{browsers.Firefox: {Major: 2}}))
+
+	feature, prefix, ok := browsers.Properties.Unprefixed("-moz-user-select")
+	assert.True(t, ok)
+	assert.Equal(t, "user-select", feature)
+	assert.Equal(t, "-moz-", prefix.Vendor())
+
+	_, _, ok = browsers.Properties.Unprefixed("user-select")
+	assert.False(t, ok)
+	assert.Equal(t, "", browsers.Vendor("--webkit-var"))
+}
+
+func TestFeature(t *testing.T) {
+	assert.True(t, browsers.AnyLink.Supported(browsers.Targets{browsers.Chrome: {Major: 65}, browsers.Safari: {Major: 9}}))
+	assert.False(t, browsers.AnyLink.Supported(browsers.Targets{browsers.Chrome: {Major: 64}, browsers.Safari: {Major: 9}}))
+	assert.False(t, browsers.AnyLink.Supported(browsers.Targets{browsers.IE: {Major: 11}}))
+	assert.False(t, browsers.CustomMediaQueries.Supported(browsers.Targets{browsers.Chrome: {Major: 131}}))
+}
@@ -0,0 +1,44 @@
+package browsers
+
+// Feature is the first version of each browser that supports a feature. Browsers that
+// aren't listed don't support it.
+type Feature map[Browser]Version
+
+// Supported returns whether or not every browser in targets supports f.
+func (f Feature) Supported(targets Targets) bool {
+	for b, min := range targets {
+		v, ok := f[b]
+		if !ok || min.Less(v) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	// AnyLink is the :any-link pseudo class.
+	AnyLink = Feature{Chrome: {65, 0}, Edge: {79, 0}, Firefox: {50, 0}, Safari: {9, 0}, IOSSafari: {9, 0}, Opera: {52, 0}, Samsung: {9, 2}}
+
+	// MediaFeatureRanges is the range syntax for media features, e.g. (width >= 600px).
+	MediaFeatureRanges = Feature{Chrome: {104, 0}, Edge: {104, 0}, Firefox: {63, 0}, Safari: {16, 4}, IOSSafari: {16, 4}, Opera: {91, 0}, Samsung: {20, 0}}
+
+	// CustomProperties is custom properties and var().
+	CustomProperties = Feature{Chrome: {49, 0}, Edge: {15, 0}, Firefox: {31, 0}, Safari: {9, 1}, IOSSafari: {9, 3}, Opera: {36, 0}, Samsung: {5, 0}}
+
+	// CustomMediaQueries is @custom-media, which no browser supports yet.
+	CustomMediaQueries = Feature{}
+
+	// MathFunctions is calc(), min(), max(), and clamp(). Browsers supported calc() long
+	// before the others, so support is based on clamp().
+	MathFunctions = Feature{Chrome: {79, 0}, Edge: {79, 0}, Firefox: {75, 0}, Safari: {13, 1}, IOSSafari: {13, 4}, Opera: {66, 0}, Samsung: {12, 0}}
+
+	// Nesting is nested style rules, including nested rules that start with an identifier.
+	Nesting = Feature{Chrome: {120, 0}, Edge: {120, 0}, Firefox: {117, 0}, Safari: {17, 2}, IOSSafari: {17, 2}, Opera: {106, 0}, Samsung: {25, 0}}
+
+	// CascadeLayers is @layer and layer() in @import.
+	CascadeLayers = Feature{Chrome: {99, 0}, Edge: {99, 0}, Firefox: {97, 0}, Safari: {15, 4}, IOSSafari: {15, 4}, Opera: {85, 0}, Samsung: {18, 0}}
+
+	// ColorFunctions is the color syntax from CSS Color 4 and 5. Support is based on
+	// color-mix(), which was the last to be supported.
+	ColorFunctions = Feature{Chrome: {111, 0}, Edge: {111, 0}, Firefox: {113, 0}, Safari: {16, 2}, IOSSafari: {16, 2}, Opera: {97, 0}, Samsung: {22, 0}}
+)
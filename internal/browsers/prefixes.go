@@ -0,0 +1,244 @@
+package browsers
+
+import (
+	"math"
+	"strings"
+)
+
+// Never is the version for browsers that still need a prefix in every release.
+var Never = Version{Major: math.MaxInt32}
+
+// Prefix is a vendor prefixed form of a feature.
+type Prefix struct {
+	// Name is the prefixed name, e.g. -webkit-user-select for user-select, or
+	// -webkit-fill-available for stretch.
+	Name string
+
+	// Unprefixed is the first version of each browser that supports the feature without
+	// the prefix. Browsers that aren't listed never needed the prefix.
+	Unprefixed map[Browser]Version
+}
+
+// Needed returns whether or not any browser in targets needs the prefix.
+func (p Prefix) Needed(targets Targets) bool {
+	for b, v := range p.Unprefixed {
+		if targets.Before(b, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Vendor returns the vendor prefix, e.g. -webkit-.
+func (p Prefix) Vendor() string {
+	return Vendor(p.Name)
+}
+
+// Vendor returns the vendor prefix of name, e.g. -webkit- for -webkit-user-select, or an
+// empty string if name is not prefixed.
+func Vendor(name string) string {
+	if !strings.HasPrefix(name, "-") || strings.HasPrefix(name, "--") {
+		return ""
+	}
+
+	i := strings.IndexByte(name[1:], '-')
+	if i < 0 {
+		return ""
+	}
+	return name[:i+2]
+}
+
+// Table is a set of features by name and their prefixed forms.
+type Table map[string][]Prefix
+
+// Unprefixed returns the feature name and Prefix for a prefixed name, e.g. user-select
+// for -webkit-user-select. ok is false if name is not a known prefixed name.
+func (t Table) Unprefixed(name string) (feature string, prefix Prefix, ok bool) {
+	if Vendor(name) == "" {
+		return "", Prefix{}, false
+	}
+
+	for feature, prefixes := range t {
+		for _, p := range prefixes {
+			if p.Name == name {
+				return feature, p, true
+			}
+		}
+	}
+	return "", Prefix{}, false
+}
+
+// prefixes returns the -webkit-, -moz-, and -ms- forms of name for the specified browsers.
+// Any set of browsers can be nil.
+func prefixes(name string, webkit, moz, ms map[Browser]Version) []Prefix {
+	var rv []Prefix
+	if webkit != nil {
+		rv = append(rv, Prefix{Name: "-webkit-" + name, Unprefixed: webkit})
+	}
+	if moz != nil {
+		rv = append(rv, Prefix{Name: "-moz-" + name, Unprefixed: moz})
+	}
+	if ms != nil {
+		rv = append(rv, Prefix{Name: "-ms-" + name, Unprefixed: ms})
+	}
+	return rv
+}
+
+// each returns a Table with the same prefixes for every name.
+func each(names []string, webkit, moz, ms map[Browser]Version) Table {
+	rv := make(Table, len(names))
+	for _, name := range names {
+		rv[name] = prefixes(name, webkit, moz, ms)
+	}
+	return rv
+}
+
+// merge combines tables into one.
+func merge(tables ...Table) Table {
+	rv := make(Table)
+	for _, t := range tables {
+		for name, prefixes := range t {
+			rv[name] = prefixes
+		}
+	}
+	return rv
+}
+
+var (
+	transformsWebkit = map[Browser]Version{Chrome: {36, 0}, Safari: {9, 0}, IOSSafari: {9, 0}, Opera: {23, 0}, Samsung: {4, 0}}
+	transformsMoz    = map[Browser]Version{Firefox: {16, 0}}
+
+	transitionsWebkit = map[Browser]Version{Chrome: {26, 0}, Safari: {6, 1}, IOSSafari: {7, 0}, Opera: {15, 0}}
+
+	animationsWebkit = map[Browser]Version{Chrome: {43, 0}, Safari: {9, 0}, IOSSafari: {9, 0}, Opera: {30, 0}, Samsung: {4, 0}}
+
+	flexboxWebkit = map[Browser]Version{Chrome: {29, 0}, Safari: {9, 0}, IOSSafari: {9, 0}, Opera: {16, 0}}
+
+	masksWebkit = map[Browser]Version{Chrome: {120, 0}, Edge: {120, 0}, Safari: {15, 4}, IOSSafari: {15, 4}, Opera: {106, 0}, Samsung: {25, 0}}
+
+	columnsWebkit = map[Browser]Version{Chrome: {50, 0}, Safari: {9, 0}, IOSSafari: {9, 0}, Opera: {37, 0}, Samsung: {5, 0}}
+	columnsMoz    = map[Browser]Version{Firefox: {52, 0}}
+
+	intrinsicSizesWebkit = map[Browser]Version{Chrome: {46, 0}, Safari: {11, 0}, IOSSafari: {11, 0}, Opera: {33, 0}, Samsung: {5, 0}}
+
+	// The -ms- grid is the older grid syntax, so only the parts that match the current
+	// syntax are prefixed.
+	gridMS = map[Browser]Version{Edge: {16, 0}, IE: Never}
+
+	borderRadiusWebkit = map[Browser]Version{Chrome: {5, 0}, Safari: {5, 0}, IOSSafari: {4, 2}}
+)
+
+// Properties is the set of properties that have prefixed forms.
+var Properties = merge(
+	Table{
+		"user-select":           prefixes("user-select", map[Browser]Version{Chrome: {54, 0}, Edge: {79, 0}, Safari: Never, IOSSafari: Never, Opera: {41, 0}, Samsung: {6, 2}}, map[Browser]Version{Firefox: {69, 0}}, map[Browser]Version{Edge: {79, 0}, IE: Never}),
+		"appearance":            prefixes("appearance", map[Browser]Version{Chrome: {84, 0}, Edge: {84, 0}, Safari: {15, 4}, IOSSafari: {15, 4}, Opera: {70, 0}, Samsung: {14, 0}}, map[Browser]Version{Firefox: {80, 0}}, nil),
+		"backdrop-filter":       prefixes("backdrop-filter", map[Browser]Version{Safari: {18, 0}, IOSSafari: {18, 0}}, nil, nil),
+		"text-size-adjust":      prefixes("text-size-adjust", map[Browser]Version{Safari: Never, IOSSafari: Never}, nil, nil),
+		"hyphens":               prefixes("hyphens", map[Browser]Version{Safari: {17, 0}, IOSSafari: {17, 0}}, map[Browser]Version{Firefox: {43, 0}}, map[Browser]Version{Edge: {79, 0}, IE: Never}),
+		"clip-path":             prefixes("clip-path", map[Browser]Version{Chrome: {55, 0}, Safari: {13, 1}, IOSSafari: {13, 4}, Opera: {42, 0}, Samsung: {6, 2}}, nil, nil),
+		"box-decoration-break":  prefixes("box-decoration-break", map[Browser]Version{Chrome: {130, 0}, Edge: {130, 0}, Safari: Never, IOSSafari: Never, Opera: Never, Samsung: Never}, nil, nil),
+		"print-color-adjust":    prefixes("print-color-adjust", map[Browser]Version{Chrome: Never, Edge: Never, Safari: {15, 4}, IOSSafari: {15, 4}, Opera: Never, Samsung: Never}, nil, nil),
+		"tab-size":              prefixes("tab-size", nil, map[Browser]Version{Firefox: {91, 0}}, nil),
+		"font-feature-settings": prefixes("font-feature-settings", map[Browser]Version{Chrome: {48, 0}, Safari: {9, 1}, IOSSafari: {9, 3}, Opera: {35, 0}, Samsung: {5, 0}}, map[Browser]Version{Firefox: {34, 0}}, nil),
+		"filter":                prefixes("filter", map[Browser]Version{Chrome: {53, 0}, Safari: {9, 1}, IOSSafari: {9, 3}, Opera: {40, 0}, Samsung: {6, 2}}, nil, nil),
+		"box-sizing":            prefixes("box-sizing", map[Browser]Version{Chrome: {10, 0}, Safari: {5, 1}, IOSSafari: {6, 0}}, map[Browser]Version{Firefox: {29, 0}}, nil),
+		"box-shadow":            prefixes("box-shadow", map[Browser]Version{Chrome: {10, 0}, Safari: {5, 1}, IOSSafari: {5, 0}}, map[Browser]Version{Firefox: {4, 0}}, nil),
+		"border-radius":         prefixes("border-radius", borderRadiusWebkit, map[Browser]Version{Firefox: {4, 0}}, nil),
+		"background-size":       prefixes("background-size", map[Browser]Version{Chrome: {4, 0}, Safari: {5, 0}, IOSSafari: {4, 2}}, map[Browser]Version{Firefox: {4, 0}}, nil),
+		"grid-template-columns": {{Name: "-ms-grid-columns", Unprefixed: gridMS}},
+		"grid-template-rows":    {{Name: "-ms-grid-rows", Unprefixed: gridMS}},
+	},
+	each([]string{"transform", "transform-origin"}, transformsWebkit, transformsMoz, map[Browser]Version{IE: {10, 0}}),
+	each([]string{"transform-style", "perspective", "perspective-origin", "backface-visibility"}, transformsWebkit, transformsMoz, nil),
+	each([]string{"border-top-left-radius", "border-top-right-radius", "border-bottom-right-radius", "border-bottom-left-radius"}, borderRadiusWebkit, nil, nil),
+	each([]string{"transition", "transition-property", "transition-duration", "transition-timing-function", "transition-delay"}, transitionsWebkit, transformsMoz, nil),
+	each([]string{
+		"animation", "animation-name", "animation-duration", "animation-timing-function", "animation-delay",
+		"animation-iteration-count", "animation-direction", "animation-fill-mode", "animation-play-state",
+	}, animationsWebkit, transformsMoz, nil),
+	each([]string{
+		"flex", "flex-direction", "flex-wrap", "flex-flow", "flex-grow", "flex-shrink", "flex-basis", "order",
+		"align-items", "align-self", "align-content", "justify-content",
+	}, flexboxWebkit, nil, nil),
+	each([]string{"mask", "mask-image", "mask-size", "mask-position", "mask-repeat", "mask-origin", "mask-clip"}, masksWebkit, nil, nil),
+	each([]string{"columns", "column-count", "column-gap", "column-rule", "column-width", "column-fill"}, columnsWebkit, columnsMoz, nil),
+)
+
+// sizingValues is the set of prefixed values for properties that take a size.
+var sizingValues = Table{
+	"min-content": prefixes("min-content", intrinsicSizesWebkit, map[Browser]Version{Firefox: {66, 0}}, nil),
+	"max-content": prefixes("max-content", intrinsicSizesWebkit, map[Browser]Version{Firefox: {66, 0}}, nil),
+	"fit-content": prefixes("fit-content", intrinsicSizesWebkit, map[Browser]Version{Firefox: {94, 0}}, nil),
+	"stretch": {
+		{Name: "-webkit-fill-available", Unprefixed: map[Browser]Version{Chrome: Never, Edge: Never, Safari: Never, IOSSafari: Never, Opera: Never, Samsung: Never}},
+		{Name: "-moz-available", Unprefixed: map[Browser]Version{Firefox: Never}},
+	},
+}
+
+// Values is the set of keyword values that have prefixed forms, by property.
+var Values = map[string]Table{
+	"position": {"sticky": prefixes("sticky", map[Browser]Version{Safari: {13, 0}, IOSSafari: {13, 0}}, nil, nil)},
+	"display": {
+		"flex":        prefixes("flex", flexboxWebkit, nil, nil),
+		"inline-flex": prefixes("inline-flex", flexboxWebkit, nil, nil),
+		"grid":        prefixes("grid", nil, nil, gridMS),
+		"inline-grid": prefixes("inline-grid", nil, nil, gridMS),
+	},
+	"width":           sizingValues,
+	"min-width":       sizingValues,
+	"max-width":       sizingValues,
+	"height":          sizingValues,
+	"min-height":      sizingValues,
+	"max-height":      sizingValues,
+	"inline-size":     sizingValues,
+	"min-inline-size": sizingValues,
+	"max-inline-size": sizingValues,
+	"block-size":      sizingValues,
+	"min-block-size":  sizingValues,
+	"max-block-size":  sizingValues,
+	"flex-basis":      sizingValues,
+}
+
+// KeywordProperties is the set of properties that only have prefixed forms for some
+// keywords, by property and then keyword, e.g. -webkit-background-clip for
+// background-clip: text.
+var KeywordProperties = map[string]Table{
+	"background-clip": {
+		"text": prefixes("background-clip", map[Browser]Version{Chrome: {120, 0}, Edge: {120, 0}, Safari: Never, IOSSafari: Never, Opera: {106, 0}, Samsung: {25, 0}}, nil, nil),
+	},
+}
+
+// Functions is the set of functions that have prefixed forms in any property.
+var Functions = Table{
+	"image-set": prefixes("image-set", map[Browser]Version{Chrome: {113, 0}, Edge: {113, 0}, Safari: {14, 0}, IOSSafari: {14, 0}, Opera: {99, 0}, Samsung: {23, 0}}, nil, nil),
+}
+
+// PseudoClasses is the set of pseudo classes that have prefixed forms.
+var PseudoClasses = Table{
+	"fullscreen": {
+		{Name: "-webkit-full-screen", Unprefixed: map[Browser]Version{Chrome: {71, 0}, Edge: {79, 0}, Safari: {16, 4}, Opera: {58, 0}, Samsung: {10, 1}}},
+		{Name: "-moz-full-screen", Unprefixed: map[Browser]Version{Firefox: {64, 0}}},
+	},
+	"autofill":   prefixes("autofill", map[Browser]Version{Chrome: {110, 0}, Edge: {110, 0}, Safari: {15, 0}, IOSSafari: {15, 0}, Opera: {96, 0}, Samsung: {21, 0}}, nil, nil),
+	"read-only":  prefixes("read-only", nil, map[Browser]Version{Firefox: {78, 0}}, nil),
+	"read-write": prefixes("read-write", nil, map[Browser]Version{Firefox: {78, 0}}, nil),
+}
+
+// PseudoElements is the set of pseudo elements that have prefixed forms.
+var PseudoElements = Table{
+	"selection": prefixes("selection", nil, map[Browser]Version{Firefox: {62, 0}}, nil),
+	"placeholder": {
+		{Name: "-webkit-input-placeholder", Unprefixed: map[Browser]Version{Chrome: {57, 0}, Edge: {79, 0}, Safari: {10, 1}, IOSSafari: {10, 3}, Opera: {44, 0}, Samsung: {7, 2}}},
+		{Name: "-moz-placeholder", Unprefixed: map[Browser]Version{Firefox: {51, 0}}},
+	},
+	"file-selector-button": {
+		{Name: "-webkit-file-upload-button", Unprefixed: map[Browser]Version{Chrome: {89, 0}, Edge: {89, 0}, Safari: {14, 1}, IOSSafari: {14, 5}, Opera: {75, 0}, Samsung: {15, 0}}},
+	},
+	"backdrop": prefixes("backdrop", map[Browser]Version{Safari: {15, 4}, IOSSafari: {15, 4}}, nil, nil),
+}
+
+// AtRules is the set of at-rules that have prefixed forms.
+var AtRules = Table{
+	"keyframes": prefixes("keyframes", animationsWebkit, transformsMoz, nil),
+}
@@ -0,0 +1,54 @@
+package browsers
+
+// DataVersion is the version of the embedded release and feature tables. It changes
+// whenever the tables are updated, which can change the output for the same targets.
+const DataVersion = "2024.11"
+
+// releases is every release of each browser, in order.
+var releases = map[Browser][]Version{
+	Chrome:  majors(4, 131),
+	Edge:    append(majors(12, 18), majors(79, 131)...),
+	Firefox: majors(2, 132),
+	Safari: versions(
+		"3.1", "3.2", "4", "5", "5.1", "6", "6.1", "7", "7.1", "8", "9", "9.1", "10", "10.1", "11", "11.1",
+		"12", "12.1", "13", "13.1", "14", "14.1", "15", "15.1", "15.2", "15.4", "15.5", "15.6",
+		"16.0", "16.1", "16.2", "16.3", "16.4", "16.5", "16.6", "17.0", "17.1", "17.2", "17.3", "17.4",
+		"17.5", "17.6", "18.0", "18.1",
+	),
+	IOSSafari: versions(
+		"3.2", "4.0", "4.2", "5.0", "6.0", "7.0", "8", "8.1", "9.0", "9.3", "10.0", "10.3", "11.0", "11.3",
+		"12.0", "12.2", "13.0", "13.2", "13.3", "13.4", "14.0", "14.5", "15.0", "15.2", "15.4", "15.5", "15.6",
+		"16.0", "16.1", "16.2", "16.3", "16.4", "16.5", "16.6", "17.0", "17.1", "17.2", "17.3", "17.4",
+		"17.5", "17.6", "18.0", "18.1",
+	),
+	Opera: append(versions("9", "9.5", "10.0", "10.1", "10.5", "10.6", "11", "11.1", "11.5", "11.6", "12", "12.1"), majors(15, 114)...),
+	Samsung: versions(
+		"4", "5.0", "6.2", "7.2", "8.2", "9.2", "10.1", "11.1", "12.0", "13.0", "14.0", "15.0", "16.0",
+		"17.0", "18.0", "19.0", "20", "21", "22", "23", "24", "25", "26",
+	),
+	IE: majors(5, 11),
+}
+
+// maintained is the set of browsers that still get releases. They are the browsers
+// used by "last n versions" queries.
+var maintained = []Browser{Chrome, Edge, Firefox, Safari, IOSSafari, Opera, Samsung}
+
+func majors(from, to int) []Version {
+	rv := make([]Version, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		rv = append(rv, Version{Major: i})
+	}
+	return rv
+}
+
+func versions(vs ...string) []Version {
+	rv := make([]Version, 0, len(vs))
+	for _, s := range vs {
+		v, err := ParseVersion(s)
+		if err != nil {
+			panic(err)
+		}
+		rv = append(rv, v)
+	}
+	return rv
+}
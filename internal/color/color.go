@@ -0,0 +1,573 @@
+// Package color converts colors between the color spaces defined by CSS Color 4.
+// See: https://www.w3.org/TR/css-color-4/.
+package color
+
+import (
+	"math"
+)
+
+// Space is a color space.
+type Space int
+
+const (
+	// SRGB is the sRGB color space. Components are from 0 to 1.
+	SRGB Space = iota
+	// SRGBLinear is the sRGB color space without gamma encoding.
+	SRGBLinear
+	// DisplayP3 is the Display P3 color space.
+	DisplayP3
+	// A98RGB is the Adobe 1998 RGB color space.
+	A98RGB
+	// ProPhotoRGB is the ProPhoto RGB color space.
+	ProPhotoRGB
+	// Rec2020 is the ITU-R BT.2020 color space.
+	Rec2020
+	// XYZD50 is the CIE XYZ color space with a D50 white point.
+	XYZD50
+	// XYZD65 is the CIE XYZ color space with a D65 white point.
+	XYZD65
+	// Lab is the CIE Lab color space. Lightness is from 0 to 100.
+	Lab
+	// LCH is the polar form of Lab. Hue is in degrees.
+	LCH
+	// OKLab is the Oklab color space. Lightness is from 0 to 1.
+	OKLab
+	// OKLCH is the polar form of OKLab. Hue is in degrees.
+	OKLCH
+	// HSL is the polar form of sRGB used by hsl(). Hue is in degrees, saturation and
+	// lightness are from 0 to 1.
+	HSL
+	// HWB is the polar form of sRGB used by hwb(). Hue is in degrees, whiteness and
+	// blackness are from 0 to 1.
+	HWB
+)
+
+// Spaces is the set of color spaces by their name in css, e.g. in color(display-p3 1 0 0).
+var Spaces = map[string]Space{
+	"srgb":         SRGB,
+	"srgb-linear":  SRGBLinear,
+	"display-p3":   DisplayP3,
+	"a98-rgb":      A98RGB,
+	"prophoto-rgb": ProPhotoRGB,
+	"rec2020":      Rec2020,
+	"xyz":          XYZD65,
+	"xyz-d50":      XYZD50,
+	"xyz-d65":      XYZD65,
+	"lab":          Lab,
+	"lch":          LCH,
+	"oklab":        OKLab,
+	"oklch":        OKLCH,
+	"hsl":          HSL,
+	"hwb":          HWB,
+}
+
+// IsRGB returns whether or not s is an RGB or XYZ space, i.e. one that can be used with color().
+func (s Space) IsRGB() bool {
+	return s <= XYZD65
+}
+
+// hue returns the index of the hue component in s, or -1 if s is not a polar space.
+func (s Space) hue() int {
+	switch s {
+	case LCH, OKLCH:
+		return 2
+	case HSL, HWB:
+		return 0
+	default:
+		return -1
+	}
+}
+
+// Color is a color in a color space.
+type Color struct {
+	Space Space
+
+	// Components are the components of the color in Space, e.g. r, g, and b for SRGB.
+	Components [3]float64
+
+	// Alpha is the alpha of the color, from 0 to 1.
+	Alpha float64
+
+	// Missing is set for components that are none. Missing[3] is the alpha. Missing
+	// components are treated as 0, except when mixing colors.
+	// See: https://www.w3.org/TR/css-color-4/#missing.
+	Missing [4]bool
+}
+
+// To converts c into space.
+func (c Color) To(space Space) Color {
+	if c.Space == space {
+		return c
+	}
+
+	components := c.Components
+	for i := range components {
+		if c.Missing[i] {
+			components[i] = 0
+		}
+	}
+
+	rv := Color{
+		Space:      space,
+		Components: fromXYZD65(toXYZD65(components, c.Space), space),
+		Alpha:      c.Alpha,
+	}
+	rv.Missing[3] = c.Missing[3]
+
+	// Missing components are carried over to analogous components in space.
+	// See: https://www.w3.org/TR/css-color-4/#interpolation-missing.
+	for i := 0; i < 3; i++ {
+		if !c.Missing[i] || analogous(c.Space, i) == "" {
+			continue
+		}
+
+		for j := 0; j < 3; j++ {
+			if analogous(space, j) == analogous(c.Space, i) {
+				rv.Missing[j] = true
+			}
+		}
+	}
+
+	// Hue is powerless for achromatic colors.
+	if h := space.hue(); h >= 0 && isAchromatic(rv) {
+		rv.Missing[h] = true
+	}
+
+	return rv
+}
+
+// analogous returns the kind of component i in space, if it has an analogous component in other spaces.
+func analogous(space Space, i int) string {
+	switch space {
+	case SRGB, SRGBLinear, DisplayP3, A98RGB, ProPhotoRGB, Rec2020, XYZD50, XYZD65:
+		return [...]string{"red", "green", "blue"}[i]
+	case Lab, OKLab:
+		return [...]string{"lightness", "", ""}[i]
+	case LCH, OKLCH:
+		return [...]string{"lightness", "colorfulness", "hue"}[i]
+	case HSL:
+		return [...]string{"hue", "colorfulness", "lightness"}[i]
+	case HWB:
+		return [...]string{"hue", "", ""}[i]
+	default:
+		return ""
+	}
+}
+
+// achromaticEpsilon is the chroma or saturation below which a color is treated as achromatic.
+const achromaticEpsilon = 1e-6
+
+func isAchromatic(c Color) bool {
+	switch c.Space {
+	case LCH:
+		return c.Components[1] < 1e-4
+	case OKLCH:
+		return c.Components[1] < achromaticEpsilon
+	case HSL:
+		return c.Components[1] < achromaticEpsilon || c.Components[2] < achromaticEpsilon || c.Components[2] > 1-achromaticEpsilon
+	case HWB:
+		return c.Components[1]+c.Components[2] >= 1-achromaticEpsilon
+	default:
+		return false
+	}
+}
+
+type matrix [3][3]float64
+
+func (m matrix) mul(v [3]float64) [3]float64 {
+	return [3]float64{
+		m[0][0]*v[0] + m[0][1]*v[1] + m[0][2]*v[2],
+		m[1][0]*v[0] + m[1][1]*v[1] + m[1][2]*v[2],
+		m[2][0]*v[0] + m[2][1]*v[1] + m[2][2]*v[2],
+	}
+}
+
+func (m matrix) inverse() matrix {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+
+	return matrix{
+		{
+			(m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det,
+			(m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det,
+			(m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det,
+		},
+		{
+			(m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det,
+			(m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det,
+			(m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det,
+		},
+		{
+			(m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det,
+			(m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det,
+			(m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det,
+		},
+	}
+}
+
+// Matrices for converting linear RGB spaces to XYZ. They, and the conversions below, follow
+// the sample code at https://www.w3.org/TR/css-color-4/#color-conversion-code.
+var (
+	linearSRGBToXYZ = matrix{
+		{506752.0 / 1228815, 87881.0 / 245763, 12673.0 / 70218},
+		{87098.0 / 409605, 175762.0 / 245763, 12673.0 / 175545},
+		{7918.0 / 409605, 87881.0 / 737289, 1001167.0 / 1053270},
+	}
+	linearP3ToXYZ = matrix{
+		{608311.0 / 1250200, 189793.0 / 714400, 198249.0 / 1000160},
+		{35783.0 / 156275, 247089.0 / 357200, 198249.0 / 2500400},
+		{0, 32229.0 / 714400, 5220557.0 / 5000800},
+	}
+	linearA98ToXYZ = matrix{
+		{573536.0 / 994567, 263643.0 / 1420810, 187206.0 / 994567},
+		{591459.0 / 1989134, 6239551.0 / 9945670, 374412.0 / 4972835},
+		{53769.0 / 1989134, 351524.0 / 4972835, 4929758.0 / 4972835},
+	}
+	// ProPhoto RGB uses a D50 white point.
+	linearProPhotoToXYZD50 = matrix{
+		{0.79776664490064230, 0.13518129740053308, 0.03134773412839220},
+		{0.28807482881940130, 0.71183523424187300, 0.00008993693872564},
+		{0, 0, 0.82510460251046020},
+	}
+	linearRec2020ToXYZ = matrix{
+		{63426534.0 / 99577255, 20160776.0 / 139408157, 47086771.0 / 278816314},
+		{26158966.0 / 99577255, 472592308.0 / 697040785, 8267143.0 / 139408157},
+		{0, 19567812.0 / 697040785, 295819943.0 / 278816314},
+	}
+
+	// d50ToD65 is the Bradford chromatic adaptation from D50 to D65.
+	d50ToD65 = matrix{
+		{0.9554734527042182, -0.023098536874261423, 0.0632593086610217},
+		{-0.028369706963208136, 1.0099954580058226, 0.021041398966943008},
+		{0.012314001688319899, -0.020507696433477912, 1.3303659366080753},
+	}
+
+	xyzToLMS = matrix{
+		{0.8190224379967030, 0.3619062600528904, -0.1288737815209879},
+		{0.0329836539323885, 0.9292868615863434, 0.0361446663506424},
+		{0.0481771893596242, 0.2642395317527308, 0.6335478284694309},
+	}
+	lmsToOKLab = matrix{
+		{0.2104542683093140, 0.7936177747023054, -0.0040720430116193},
+		{1.9779985324311684, -2.4285922420485799, 0.4505937096174110},
+		{0.0259040424655478, 0.7827717124575296, -0.8086757549230774},
+	}
+
+	xyzToLinearSRGB        = linearSRGBToXYZ.inverse()
+	xyzToLinearP3          = linearP3ToXYZ.inverse()
+	xyzToLinearA98         = linearA98ToXYZ.inverse()
+	xyzD50ToLinearProPhoto = linearProPhotoToXYZD50.inverse()
+	xyzToLinearRec2020     = linearRec2020ToXYZ.inverse()
+	d65ToD50               = d50ToD65.inverse()
+	lmsToXYZ               = xyzToLMS.inverse()
+	okLabToLMS             = lmsToOKLab.inverse()
+)
+
+// d50 is the D50 white point in XYZ.
+var d50 = [3]float64{0.3457 / 0.3585, 1, (1 - 0.3457 - 0.3585) / 0.3585}
+
+func toXYZD65(c [3]float64, space Space) [3]float64 {
+	switch space {
+	case SRGB:
+		return linearSRGBToXYZ.mul(transfer(c, srgbToLinear))
+	case SRGBLinear:
+		return linearSRGBToXYZ.mul(c)
+	case DisplayP3:
+		return linearP3ToXYZ.mul(transfer(c, srgbToLinear))
+	case A98RGB:
+		return linearA98ToXYZ.mul(transfer(c, a98ToLinear))
+	case ProPhotoRGB:
+		return d50ToD65.mul(linearProPhotoToXYZD50.mul(transfer(c, proPhotoToLinear)))
+	case Rec2020:
+		return linearRec2020ToXYZ.mul(transfer(c, rec2020ToLinear))
+	case XYZD50:
+		return d50ToD65.mul(c)
+	case XYZD65:
+		return c
+	case Lab:
+		return d50ToD65.mul(labToXYZD50(c))
+	case LCH:
+		return d50ToD65.mul(labToXYZD50(polarToRectangular(c)))
+	case OKLab:
+		return okLabToXYZ(c)
+	case OKLCH:
+		return okLabToXYZ(polarToRectangular(c))
+	case HSL:
+		return toXYZD65(hslToSRGB(c), SRGB)
+	case HWB:
+		return toXYZD65(hwbToSRGB(c), SRGB)
+	default:
+		panic("unknown color space")
+	}
+}
+
+func fromXYZD65(xyz [3]float64, space Space) [3]float64 {
+	switch space {
+	case SRGB:
+		return transfer(xyzToLinearSRGB.mul(xyz), linearToSRGB)
+	case SRGBLinear:
+		return xyzToLinearSRGB.mul(xyz)
+	case DisplayP3:
+		return transfer(xyzToLinearP3.mul(xyz), linearToSRGB)
+	case A98RGB:
+		return transfer(xyzToLinearA98.mul(xyz), linearToA98)
+	case ProPhotoRGB:
+		return transfer(xyzD50ToLinearProPhoto.mul(d65ToD50.mul(xyz)), linearToProPhoto)
+	case Rec2020:
+		return transfer(xyzToLinearRec2020.mul(xyz), linearToRec2020)
+	case XYZD50:
+		return d65ToD50.mul(xyz)
+	case XYZD65:
+		return xyz
+	case Lab:
+		return xyzD50ToLab(d65ToD50.mul(xyz))
+	case LCH:
+		return rectangularToPolar(xyzD50ToLab(d65ToD50.mul(xyz)))
+	case OKLab:
+		return xyzToOKLab(xyz)
+	case OKLCH:
+		return rectangularToPolar(xyzToOKLab(xyz))
+	case HSL:
+		return srgbToHSL(fromXYZD65(xyz, SRGB))
+	case HWB:
+		return srgbToHWB(fromXYZD65(xyz, SRGB))
+	default:
+		panic("unknown color space")
+	}
+}
+
+func transfer(c [3]float64, f func(float64) float64) [3]float64 {
+	return [3]float64{f(c[0]), f(c[1]), f(c[2])}
+}
+
+// signed applies f to the absolute value of v, keeping the sign of v. Transfer functions
+// are extended to negative values this way.
+func signed(v float64, f func(float64) float64) float64 {
+	if v < 0 {
+		return -f(-v)
+	}
+	return f(v)
+}
+
+func srgbToLinear(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	})
+}
+
+func linearToSRGB(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v <= 0.0031308 {
+			return v * 12.92
+		}
+		return 1.055*math.Pow(v, 1/2.4) - 0.055
+	})
+}
+
+func a98ToLinear(v float64) float64 {
+	return signed(v, func(v float64) float64 { return math.Pow(v, 563.0/256) })
+}
+
+func linearToA98(v float64) float64 {
+	return signed(v, func(v float64) float64 { return math.Pow(v, 256.0/563) })
+}
+
+func proPhotoToLinear(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v <= 16.0/512 {
+			return v / 16
+		}
+		return math.Pow(v, 1.8)
+	})
+}
+
+func linearToProPhoto(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v < 1.0/512 {
+			return v * 16
+		}
+		return math.Pow(v, 1/1.8)
+	})
+}
+
+const (
+	rec2020Alpha = 1.09929682680944
+	rec2020Beta  = 0.018053968510807
+)
+
+func rec2020ToLinear(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v < rec2020Beta*4.5 {
+			return v / 4.5
+		}
+		return math.Pow((v+rec2020Alpha-1)/rec2020Alpha, 1/0.45)
+	})
+}
+
+func linearToRec2020(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v < rec2020Beta {
+			return v * 4.5
+		}
+		return rec2020Alpha*math.Pow(v, 0.45) - (rec2020Alpha - 1)
+	})
+}
+
+const (
+	labKappa   = 24389.0 / 27
+	labEpsilon = 216.0 / 24389
+)
+
+func xyzD50ToLab(xyz [3]float64) [3]float64 {
+	var f [3]float64
+	for i, v := range xyz {
+		v /= d50[i]
+		if v > labEpsilon {
+			f[i] = math.Cbrt(v)
+		} else {
+			f[i] = (labKappa*v + 16) / 116
+		}
+	}
+
+	return [3]float64{116*f[1] - 16, 500 * (f[0] - f[1]), 200 * (f[1] - f[2])}
+}
+
+func labToXYZD50(lab [3]float64) [3]float64 {
+	f1 := (lab[0] + 16) / 116
+	f0 := lab[1]/500 + f1
+	f2 := f1 - lab[2]/200
+
+	var xyz [3]float64
+	if f0*f0*f0 > labEpsilon {
+		xyz[0] = f0 * f0 * f0
+	} else {
+		xyz[0] = (116*f0 - 16) / labKappa
+	}
+
+	if lab[0] > labKappa*labEpsilon {
+		xyz[1] = f1 * f1 * f1
+	} else {
+		xyz[1] = lab[0] / labKappa
+	}
+
+	if f2*f2*f2 > labEpsilon {
+		xyz[2] = f2 * f2 * f2
+	} else {
+		xyz[2] = (116*f2 - 16) / labKappa
+	}
+
+	for i := range xyz {
+		xyz[i] *= d50[i]
+	}
+	return xyz
+}
+
+func xyzToOKLab(xyz [3]float64) [3]float64 {
+	return lmsToOKLab.mul(transfer(xyzToLMS.mul(xyz), math.Cbrt))
+}
+
+func okLabToXYZ(lab [3]float64) [3]float64 {
+	return lmsToXYZ.mul(transfer(okLabToLMS.mul(lab), func(v float64) float64 { return v * v * v }))
+}
+
+func rectangularToPolar(lab [3]float64) [3]float64 {
+	return [3]float64{
+		lab[0],
+		math.Hypot(lab[1], lab[2]),
+		normalizeHue(math.Atan2(lab[2], lab[1]) * 180 / math.Pi),
+	}
+}
+
+func polarToRectangular(lch [3]float64) [3]float64 {
+	h := lch[2] * math.Pi / 180
+	return [3]float64{lch[0], lch[1] * math.Cos(h), lch[1] * math.Sin(h)}
+}
+
+// normalizeHue returns h in the range [0, 360).
+func normalizeHue(h float64) float64 {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// hslToSRGB converts hsl to sRGB.
+// See: https://www.w3.org/TR/css-color-4/#hsl-to-rgb.
+func hslToSRGB(hsl [3]float64) [3]float64 {
+	h, s, l := normalizeHue(hsl[0]), hsl[1], hsl[2]
+	a := s * math.Min(l, 1-l)
+	f := func(n float64) float64 {
+		k := math.Mod(n+h/30, 12)
+		return l - a*math.Max(-1, math.Min(math.Min(k-3, 9-k), 1))
+	}
+	return [3]float64{f(0), f(8), f(4)}
+}
+
+// srgbToHSL converts sRGB to hsl.
+// See: https://www.w3.org/TR/css-color-4/#rgb-to-hsl.
+func srgbToHSL(rgb [3]float64) [3]float64 {
+	r, g, b := rgb[0], rgb[1], rgb[2]
+	max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l := (min + max) / 2
+	d := max - min
+
+	var h, s float64
+	if d != 0 {
+		if l != 0 && l != 1 {
+			s = (max - l) / math.Min(l, 1-l)
+		}
+
+		switch max {
+		case r:
+			h = (g-b)/d + 0
+			if g < b {
+				h += 6
+			}
+		case g:
+			h = (b-r)/d + 2
+		case b:
+			h = (r-g)/d + 4
+		}
+		h *= 60
+	}
+
+	// Out of gamut colors can have negative saturation.
+	if s < 0 {
+		h += 180
+		s = -s
+	}
+
+	return [3]float64{normalizeHue(h), s, l}
+}
+
+// hwbToSRGB converts hwb to sRGB.
+// See: https://www.w3.org/TR/css-color-4/#hwb-to-rgb.
+func hwbToSRGB(hwb [3]float64) [3]float64 {
+	w, b := hwb[1], hwb[2]
+	if w+b >= 1 {
+		gray := w / (w + b)
+		return [3]float64{gray, gray, gray}
+	}
+
+	rgb := hslToSRGB([3]float64{hwb[0], 1, 0.5})
+	for i := range rgb {
+		rgb[i] = rgb[i]*(1-w-b) + w
+	}
+	return rgb
+}
+
+// srgbToHWB converts sRGB to hwb.
+// See: https://www.w3.org/TR/css-color-4/#rgb-to-hwb.
+func srgbToHWB(rgb [3]float64) [3]float64 {
+	hsl := srgbToHSL(rgb)
+	return [3]float64{
+		hsl[0],
+		math.Min(rgb[0], math.Min(rgb[1], rgb[2])),
+		1 - math.Max(rgb[0], math.Max(rgb[1], rgb[2])),
+	}
+}
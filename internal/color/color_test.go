@@ -0,0 +1,104 @@
+package color_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func assertComponents(t *testing.T, expected [3]float64, actual color.Color, delta float64) {
+	t.Helper()
+	for i := range expected {
+		assert.InDelta(t, expected[i], actual.Components[i], delta, "component %d of %v", i, actual.Components)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	red := color.Color{Space: color.SRGB, Components: [3]float64{1, 0, 0}, Alpha: 1}
+
+	assertComponents(t, [3]float64{54.29, 80.8, 69.89}, red.To(color.Lab), 0.01)
+	assertComponents(t, [3]float64{54.29, 106.84, 40.85}, red.To(color.LCH), 0.01)
+	assertComponents(t, [3]float64{0.628, 0.2249, 0.1258}, red.To(color.OKLab), 0.001)
+	assertComponents(t, [3]float64{0.628, 0.2577, 29.23}, red.To(color.OKLCH), 0.01)
+	assertComponents(t, [3]float64{0, 1, 0.5}, red.To(color.HSL), 1e-9)
+	assertComponents(t, [3]float64{0, 0, 0}, red.To(color.HWB), 1e-9)
+	assertComponents(t, [3]float64{0.4124, 0.2126, 0.0193}, red.To(color.XYZD65), 0.0001)
+
+	white := color.Color{Space: color.SRGB, Components: [3]float64{1, 1, 1}, Alpha: 1}
+	assertComponents(t, [3]float64{1, 0, 0}, white.To(color.OKLab), 1e-4)
+	assertComponents(t, [3]float64{100, 0, 0}, white.To(color.Lab), 1e-3)
+
+	green := color.Color{Space: color.HSL, Components: [3]float64{120, 1, 0.25}, Alpha: 1}
+	assertComponents(t, [3]float64{0, 0.5, 0}, green.To(color.SRGB), 1e-9)
+}
+
+func TestConvert_RoundTrip(t *testing.T) {
+	c := color.Color{Space: color.SRGB, Components: [3]float64{0.2, 0.4, 0.8}, Alpha: 1}
+	for _, space := range color.Spaces {
+		assertComponents(t, c.Components, c.To(space).To(color.SRGB), 1e-6)
+	}
+}
+
+func TestConvert_Powerless(t *testing.T) {
+	gray := color.Color{Space: color.SRGB, Components: [3]float64{0.5, 0.5, 0.5}, Alpha: 1}
+	assert.True(t, gray.To(color.OKLCH).Missing[2])
+	assert.True(t, gray.To(color.HSL).Missing[0])
+
+	missing := color.Color{Space: color.OKLCH, Components: [3]float64{0.5, 0.1, 0}, Alpha: 1, Missing: [4]bool{true}}
+	assert.Equal(t, [4]bool{true}, missing.To(color.Lab).Missing)
+}
+
+func TestToGamut(t *testing.T) {
+	red := color.Color{Space: color.SRGB, Components: [3]float64{1, 0, 0}, Alpha: 1}
+	assert.Equal(t, red.Components, red.ToGamut(color.SRGB).Components)
+
+	p3 := color.Color{Space: color.DisplayP3, Components: [3]float64{0, 1, 0}, Alpha: 1}
+	assert.False(t, p3.InGamut(color.SRGB))
+
+	mapped := p3.ToGamut(color.SRGB)
+	assert.True(t, mapped.InGamut(color.SRGB))
+	assert.Equal(t, color.SRGB, mapped.Space)
+
+	// Lightness is kept.
+	assert.InDelta(t, p3.To(color.OKLCH).Components[0], mapped.To(color.OKLCH).Components[0], 0.01)
+
+	tooLight := color.Color{Space: color.OKLCH, Components: [3]float64{1.2, 0.4, 100}, Alpha: 0.5}
+	assert.Equal(t, color.Color{Space: color.SRGB, Components: [3]float64{1, 1, 1}, Alpha: 0.5}, tooLight.ToGamut(color.SRGB))
+}
+
+func TestMix(t *testing.T) {
+	red, _ := color.Named("red")
+	blue, _ := color.Named("blue")
+
+	assertComponents(t, [3]float64{0.5, 0, 0.5}, color.Mix(red, blue, 0.5, color.SRGB, color.HueShorter), 1e-9)
+	assertComponents(t, [3]float64{0.25, 0, 0.75}, color.Mix(red, blue, 0.75, color.SRGB, color.HueShorter), 1e-9)
+
+	// Hues take the shorter arc by default: red is 0deg and blue is 240deg in hsl.
+	assert.InDelta(t, 300, color.Mix(red, blue, 0.5, color.HSL, color.HueShorter).Components[0], 1e-9)
+	assert.InDelta(t, 120, color.Mix(red, blue, 0.5, color.HSL, color.HueLonger).Components[0], 1e-9)
+	assert.InDelta(t, 120, color.Mix(red, blue, 0.5, color.HSL, color.HueIncreasing).Components[0], 1e-9)
+	assert.InDelta(t, 300, color.Mix(red, blue, 0.5, color.HSL, color.HueDecreasing).Components[0], 1e-9)
+
+	// Alpha is premultiplied.
+	transparent, _ := color.Named("transparent")
+	mixed := color.Mix(red, transparent, 0.5, color.SRGB, color.HueShorter)
+	assertComponents(t, [3]float64{1, 0, 0}, mixed, 1e-9)
+	assert.InDelta(t, 0.5, mixed.Alpha, 1e-9)
+
+	// Missing hues take the hue of the other color.
+	white, _ := color.Named("white")
+	assert.InDelta(t, blue.To(color.OKLCH).Components[2], color.Mix(white, blue, 0.5, color.OKLCH, color.HueShorter).Components[2], 1e-6)
+}
+
+func TestName(t *testing.T) {
+	name, ok := color.Name([3]uint8{255, 0, 0})
+	assert.True(t, ok)
+	assert.Equal(t, "red", name)
+
+	name, _ = color.Name([3]uint8{0, 255, 255})
+	assert.Equal(t, "aqua", name)
+
+	_, ok = color.Name([3]uint8{1, 2, 3})
+	assert.False(t, ok)
+}
@@ -0,0 +1,111 @@
+package color
+
+import "math"
+
+// gamutEpsilon is how far outside of [0, 1] a component can be and still be in gamut,
+// to allow for floating point error.
+const gamutEpsilon = 1e-6
+
+// InGamut returns whether or not c can be displayed in space. Only RGB spaces, HSL, and
+// HWB have gamut limits; HSL and HWB use the sRGB gamut.
+func (c Color) InGamut(space Space) bool {
+	if !hasGamut(space) {
+		return true
+	}
+
+	rgb := c.To(gamutSpace(space))
+	for _, v := range rgb.Components {
+		if v < -gamutEpsilon || v > 1+gamutEpsilon {
+			return false
+		}
+	}
+	return true
+}
+
+func hasGamut(space Space) bool {
+	return space <= Rec2020 || space == HSL || space == HWB
+}
+
+func gamutSpace(space Space) Space {
+	if space == HSL || space == HWB {
+		return SRGB
+	}
+	return space
+}
+
+// clip returns c in space with its components clamped to the gamut of space.
+func clip(c Color, space Space) Color {
+	rv := c.To(space)
+	for i, v := range rv.Components {
+		rv.Components[i] = math.Max(0, math.Min(1, v))
+	}
+	return rv
+}
+
+// deltaEOK returns the distance between two colors in OKLab.
+func deltaEOK(a, b Color) float64 {
+	x, y := a.To(OKLab).Components, b.To(OKLab).Components
+	return math.Sqrt((x[0]-y[0])*(x[0]-y[0]) + (x[1]-y[1])*(x[1]-y[1]) + (x[2]-y[2])*(x[2]-y[2]))
+}
+
+// ToGamut converts c into space, reducing its chroma in OKLCH until it can be displayed in
+// space. This is the css gamut mapping algorithm, which keeps the lightness and hue of the
+// color as much as possible.
+// See: https://www.w3.org/TR/css-color-4/#binsearch.
+func (c Color) ToGamut(space Space) Color {
+	if !hasGamut(space) {
+		return c.To(space)
+	}
+	destination := gamutSpace(space)
+
+	origin := c.To(OKLCH)
+	origin.Missing = [4]bool{}
+	if origin.Components[0] >= 1 {
+		return Color{Space: destination, Components: [3]float64{1, 1, 1}, Alpha: c.Alpha}.To(space)
+	}
+	if origin.Components[0] <= 0 {
+		return Color{Space: destination, Components: [3]float64{0, 0, 0}, Alpha: c.Alpha}.To(space)
+	}
+
+	if origin.InGamut(destination) {
+		return c.To(space)
+	}
+
+	const (
+		jnd     = 0.02
+		epsilon = 0.0001
+	)
+
+	current := origin
+	clipped := clip(current, destination)
+	if deltaEOK(clipped, current) < jnd {
+		return clipped.To(space)
+	}
+
+	min, max := 0.0, origin.Components[1]
+	minInGamut := true
+	for max-min > epsilon {
+		chroma := (min + max) / 2
+		current.Components[1] = chroma
+
+		if minInGamut && current.InGamut(destination) {
+			min = chroma
+			continue
+		}
+
+		clipped = clip(current, destination)
+		e := deltaEOK(clipped, current)
+		if e < jnd {
+			if jnd-e < epsilon {
+				break
+			}
+
+			minInGamut = false
+			min = chroma
+		} else {
+			max = chroma
+		}
+	}
+
+	return clipped.To(space)
+}
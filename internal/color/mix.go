@@ -0,0 +1,116 @@
+package color
+
+// HueInterpolation is how hues are interpolated in polar color spaces.
+// See: https://www.w3.org/TR/css-color-4/#hue-interpolation.
+type HueInterpolation int
+
+const (
+	// HueShorter interpolates along the shorter arc between hues. It is the default.
+	HueShorter HueInterpolation = iota
+	// HueLonger interpolates along the longer arc between hues.
+	HueLonger
+	// HueIncreasing interpolates with increasing hue.
+	HueIncreasing
+	// HueDecreasing interpolates with decreasing hue.
+	HueDecreasing
+)
+
+// HueInterpolations is the set of hue interpolation methods by their name in css, e.g. in
+// color-mix(in oklch longer hue, red, blue).
+var HueInterpolations = map[string]HueInterpolation{
+	"shorter":    HueShorter,
+	"longer":     HueLonger,
+	"increasing": HueIncreasing,
+	"decreasing": HueDecreasing,
+}
+
+// Mix interpolates between a and b in space with premultiplied alpha. p is the amount of b
+// in the result, from 0 to 1.
+// See: https://www.w3.org/TR/css-color-5/#color-mix.
+func Mix(a, b Color, p float64, space Space, hue HueInterpolation) Color {
+	a, b = a.To(space), b.To(space)
+
+	// A missing component takes the value from the other color.
+	for i := range a.Missing {
+		switch {
+		case a.Missing[i] && !b.Missing[i]:
+			a.set(i, b.get(i))
+			a.Missing[i] = false
+		case b.Missing[i] && !a.Missing[i]:
+			b.set(i, a.get(i))
+			b.Missing[i] = false
+		case a.Missing[i] && b.Missing[i]:
+			a.set(i, 0)
+			b.set(i, 0)
+		}
+	}
+
+	rv := Color{Space: space, Alpha: a.Alpha*(1-p) + b.Alpha*p}
+	rv.Missing[3] = a.Missing[3]
+
+	h := space.hue()
+	if h >= 0 {
+		a.Components[h], b.Components[h] = fixupHues(normalizeHue(a.Components[h]), normalizeHue(b.Components[h]), hue)
+		rv.Missing[h] = a.Missing[h]
+	}
+
+	for i := range rv.Components {
+		if i == h {
+			rv.Components[i] = normalizeHue(a.Components[i]*(1-p) + b.Components[i]*p)
+			continue
+		}
+
+		premultiplied := a.Components[i]*a.Alpha*(1-p) + b.Components[i]*b.Alpha*p
+		if rv.Alpha != 0 {
+			rv.Components[i] = premultiplied / rv.Alpha
+		}
+		rv.Missing[i] = a.Missing[i]
+	}
+
+	return rv
+}
+
+func (c *Color) get(i int) float64 {
+	if i == 3 {
+		return c.Alpha
+	}
+	return c.Components[i]
+}
+
+func (c *Color) set(i int, v float64) {
+	if i == 3 {
+		c.Alpha = v
+		return
+	}
+	c.Components[i] = v
+}
+
+// fixupHues adjusts two hues in [0, 360) so that interpolating between them follows method.
+func fixupHues(a, b float64, method HueInterpolation) (float64, float64) {
+	switch method {
+	case HueShorter:
+		if b-a > 180 {
+			a += 360
+		} else if b-a < -180 {
+			b += 360
+		}
+
+	case HueLonger:
+		if 0 < b-a && b-a < 180 {
+			a += 360
+		} else if -180 < b-a && b-a <= 0 {
+			b += 360
+		}
+
+	case HueIncreasing:
+		if b < a {
+			b += 360
+		}
+
+	case HueDecreasing:
+		if a < b {
+			a += 360
+		}
+	}
+	return a, b
+}
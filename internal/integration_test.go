@@ -4,9 +4,13 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/stephen/cssc/internal/ast"
 	"github.com/stephen/cssc/internal/parser"
 	"github.com/stephen/cssc/internal/printer"
 	"github.com/stephen/cssc/internal/sources"
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -35,3 +39,42 @@ func TestIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestClone(t *testing.T) {
+	by, err := ioutil.ReadFile("testdata/bootstrap.css")
+	require.NoError(t, err)
+	source := &sources.Source{
+		Path:    "testdata/bootstrap.css",
+		Content: "@import 'other.css';\n" + string(by) + "\n.a:any-link { color: red } @media (width >= 100px) { .a { color: blue } }",
+	}
+
+	ss, err := parser.Parse(source)
+	require.NoError(t, err)
+	print := func(ss *ast.Stylesheet) string {
+		out, err := printer.Print(ss, printer.Options{})
+		require.NoError(t, err)
+		return out
+	}
+	before := print(ss)
+
+	cloner := ast.NewCloner()
+	clone := cloner.Stylesheet(ss)
+	assert.Equal(t, before, print(clone))
+
+	// Shared pointers stay shared in the copy.
+	assert.Same(t, clone.Nodes[0], clone.Imports[0].AtRule)
+	assert.Same(t, clone.Imports[0].AtRule, cloner.Copy(ss.Imports[0].AtRule))
+
+	out := transformer.Transform(clone, transformer.Options{
+		Options: transforms.Options{
+			AnyLink:            transforms.AnyLinkTransform,
+			MediaFeatureRanges: transforms.MediaFeatureRangesTransform,
+		},
+		OriginalSource: source,
+	})
+	assert.NotEqual(t, before, print(out))
+
+	// Transform works on its own copy, so neither stylesheet changes.
+	assert.Equal(t, before, print(clone))
+	assert.Equal(t, before, print(ss))
+}
@@ -71,7 +71,7 @@ func NewLexer(source *sources.Source) *Lexer {
 // step consumes the next unicode rune and stores it.
 func (l *Lexer) step() {
 	if l.pos == 0 {
-		l.source.Lines = append(l.source.Lines, l.pos)
+		l.addLine(l.pos)
 	}
 
 	cp, size := utf8.DecodeRuneInString(l.source.Content[l.pos:])
@@ -86,7 +86,7 @@ func (l *Lexer) step() {
 	if cp == '\r' {
 		next, nextSize := utf8.DecodeRuneInString(l.source.Content[l.pos+size:])
 		if next == '\n' {
-			l.source.Lines = append(l.source.Lines, l.pos+size+nextSize)
+			l.addLine(l.pos + size + nextSize)
 		}
 
 		l.ch = next
@@ -96,7 +96,7 @@ func (l *Lexer) step() {
 	}
 
 	if cp == '\n' {
-		l.source.Lines = append(l.source.Lines, l.pos+size)
+		l.addLine(l.pos + size)
 	}
 
 	l.ch = cp
@@ -104,6 +104,17 @@ func (l *Lexer) step() {
 	l.pos += size
 }
 
+// addLine records the start of a new line at offset. Since callers may save
+// and restore lexer state to backtrack, lines that were already recorded
+// are skipped.
+func (l *Lexer) addLine(offset int) {
+	if len(l.source.Lines) > 0 && l.source.Lines[len(l.source.Lines)-1] >= offset {
+		return
+	}
+
+	l.source.Lines = append(l.source.Lines, offset)
+}
+
 // peek returns the next ith unconsumed rune but does not consume it.
 // i is 0-indexed (0 is one ahead, 1 is two ahead, etc.)
 func (l *Lexer) peek(i int) rune {
@@ -577,6 +588,38 @@ func (l *Lexer) nextEscaped() {
 	}
 }
 
+// Skip is like Next, except that it skips over any input that can't be lexed
+// instead of failing. It's used to move past invalid input when recovering
+// from an error.
+func (l *Lexer) Skip() {
+	for {
+		pos := l.pos
+		if l.tryNext() {
+			return
+		}
+
+		// Make sure that the lexer moves forward, even if it failed on the first rune.
+		if l.pos == pos {
+			l.step()
+		}
+	}
+}
+
+// tryNext runs Next and returns whether or not it succeeded.
+func (l *Lexer) tryNext() (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			if _, isErr := err.(*Error); !isErr {
+				panic(err)
+			}
+			ok = false
+		}
+	}()
+
+	l.Next()
+	return true
+}
+
 // LocationErrorf sends up a lexer panic with a custom location.
 func (l *Lexer) LocationErrorf(span ast.Span, f string, args ...interface{}) {
 	panic(&Error{logging.LocationErrorf(l.source, span, f, args...)})
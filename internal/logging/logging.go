@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -76,6 +77,68 @@ func (l *locationError) Location() (source *sources.Source, span ast.Span) {
 	return l.Source, l.Span
 }
 
+// LocationOf returns the source, span, and message of err if it happened at a
+// specific location.
+func LocationOf(err error) (source *sources.Source, span ast.Span, message string, ok bool) {
+	var l *locationError
+	if !errors.As(err, &l) {
+		return nil, ast.Span{}, "", false
+	}
+	return l.Source, l.Span, l.inner.Error(), true
+}
+
+// Codes for the kinds of errors and warnings.
+const (
+	// CodeSyntax is an error from parsing a file.
+	CodeSyntax = "syntax"
+	// CodeRead is an error from reading a file.
+	CodeRead = "read"
+	// CodeUnresolvedImport is an @import that could not be resolved.
+	CodeUnresolvedImport = "unresolved-import"
+	// CodeImportCycle is an @import that would create a cycle.
+	CodeImportCycle = "import-cycle"
+	// CodeTransform is an error or warning from a transform.
+	CodeTransform = "transform"
+	// CodeOptions is an invalid set of options.
+	CodeOptions = "options"
+	// CodeOutput is an error from generating an output.
+	CodeOutput = "output"
+)
+
+// WithCode sets the code for err. If err already has a code, it is returned as-is.
+func WithCode(code string, err error) error {
+	if Code(err) != "" {
+		return err
+	}
+	return &codedError{err, code}
+}
+
+// Code returns the code for err, or an empty string if it doesn't have one.
+func Code(err error) string {
+	var c *codedError
+	if !errors.As(err, &c) {
+		return ""
+	}
+	return c.code
+}
+
+// codedError is an error with a code.
+type codedError struct {
+	inner error
+
+	code string
+}
+
+// Unwrap satisfies errors.Unwrap.
+func (c *codedError) Unwrap() error {
+	return c.inner
+}
+
+// Error implements error.
+func (c *codedError) Error() string {
+	return c.inner.Error()
+}
+
 // AnnotateSourceSpan annotates a span from a single line in the source code.
 // The output looks like:
 //   (contents) or (other thing)
@@ -98,3 +161,9 @@ func AnnotateSourceSpan(source *sources.Source, span ast.Span) string {
 
 	return fmt.Sprintf("\t%s\n\t%s%s%s", withoutTabs, indent, underline, excessMarker)
 }
+
+// IsWarning returns whether or not err is a warning, as opposed to an error.
+func IsWarning(err error) bool {
+	var l *locationError
+	return errors.As(err, &l) && l.warning
+}
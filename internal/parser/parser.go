@@ -1,54 +1,81 @@
 package parser
 
 import (
+	"strings"
+
 	"github.com/stephen/cssc/internal/ast"
 	"github.com/stephen/cssc/internal/lexer"
 	"github.com/stephen/cssc/internal/logging"
 	"github.com/stephen/cssc/internal/sources"
 )
 
-// Parse parses an input stylesheet.
+// Parse parses an input stylesheet. Invalid rules and declarations are skipped
+// following the error handling in https://www.w3.org/TR/css-syntax-3/#error-handling,
+// so that every error in the stylesheet is found. If there were any errors, err is
+// an Errors and ss is the stylesheet without the invalid parts.
 func Parse(source *sources.Source) (ss *ast.Stylesheet, err error) {
-	p := newParser(source)
+	p := &parser{
+		source: source,
+		ss:     &ast.Stylesheet{},
+	}
 	defer func() {
 		if rErr := recover(); rErr != nil {
 			if errI, ok := rErr.(*lexer.Error); ok {
-				ss, err = nil, errI
+				// The first token could not be lexed.
+				ss, err = p.ss, Errors{errI}
 				return
 			}
 
-			if errI, ok := rErr.(error); ok {
+			if errI, ok := rErr.(error); ok && p.lexer != nil {
 				panic(logging.LocationErrorf(source, p.lexer.TokenSpan(), "%v", errI))
 			}
 
 			// Re-panic unknown issues.
-			panic(err)
+			panic(rErr)
 		}
 	}()
 
+	p.lexer = lexer.NewLexer(source)
 	p.parse()
+	if len(p.errors) > 0 {
+		return p.ss, p.errors
+	}
 	return p.ss, nil
 }
 
-func newParser(source *sources.Source) *parser {
-	return &parser{
-		source: source,
-		lexer:  lexer.NewLexer(source),
-		ss:     &ast.Stylesheet{},
+// Errors is the set of errors from parsing a stylesheet, in source order.
+type Errors []error
+
+// Error implements error.
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
 	}
+	return strings.Join(messages, "\n")
 }
 
 type parser struct {
 	source *sources.Source
 	lexer  *lexer.Lexer
 	ss     *ast.Stylesheet
+
+	// errors is every error that the parser has recovered from.
+	errors Errors
+
+	// nesting is the number of declaration blocks the parser is currently in.
+	// Conditional rules like @media have declaration block bodies when they
+	// are nested inside a style rule.
+	nesting int
 }
 
 func (p *parser) parse() {
 	for p.lexer.Current != lexer.EOF {
 		switch p.lexer.Current {
 		case lexer.At:
-			p.parseAtRule()
+			p.recoverFrom(true, func() {
+				p.ss.Nodes = append(p.ss.Nodes, p.parseAtRule())
+			})
 
 		case lexer.Semicolon:
 			p.lexer.Next()
@@ -59,12 +86,116 @@ func (p *parser) parse() {
 			p.lexer.Next()
 
 		default:
-			p.ss.Nodes = append(p.ss.Nodes, p.parseQualifiedRule(false))
+			p.recoverFrom(false, func() {
+				p.ss.Nodes = append(p.ss.Nodes, p.parseQualifiedRule(false))
+			})
 		}
 
 	}
 }
 
+// recoverFrom runs parse, which parses a single rule or declaration. If parse fails,
+// the error is recorded and the lexer skips past the invalid input, so that parsing
+// can continue after it. Statements, i.e. at-rules and declarations, end at a ;.
+// Qualified rules only end after their {} block.
+func (p *parser) recoverFrom(isStatement bool, parse func()) {
+	lexerState := *p.lexer
+	imports := len(p.ss.Imports)
+	nesting := p.nesting
+
+	defer func() {
+		err := recover()
+		if err == nil {
+			return
+		}
+
+		lexErr, ok := err.(*lexer.Error)
+		if !ok {
+			panic(err)
+		}
+
+		// An error at the end of a file is usually found again by every enclosing
+		// block, so only the first error at a position is kept.
+		if source, span, _, ok := logging.LocationOf(lexErr); !ok || len(p.errors) == 0 || !sameLocation(p.errors[len(p.errors)-1], source, span) {
+			p.errors = append(p.errors, lexErr)
+		}
+
+		// Drop anything that was parsed from the invalid input.
+		p.lexer = &lexerState
+		p.ss.Imports = p.ss.Imports[:imports]
+		p.nesting = nesting
+
+		start := p.lexer.TokenSpan().Start
+		p.skip(isStatement)
+		if p.lexer.TokenSpan().Start == start && p.lexer.Current != lexer.EOF {
+			// The input can't start a rule at all, e.g. a stray }.
+			p.lexer.Skip()
+		}
+	}()
+
+	parse()
+}
+
+// sameLocation returns whether or not err happened at span in source.
+func sameLocation(err error, source *sources.Source, span ast.Span) bool {
+	errSource, errSpan, _, ok := logging.LocationOf(err)
+	return ok && errSource == source && errSpan.Start == span.Start
+}
+
+// skip moves the lexer past invalid input. Nested blocks are skipped as a whole, and
+// the } that closes the enclosing block is left for the caller. If isStatement is set,
+// skip stops after the first ;. Otherwise, it stops after the first {} block.
+// See: https://www.w3.org/TR/css-syntax-3/#consume-qualified-rule.
+func (p *parser) skip(isStatement bool) {
+	var closers []lexer.Token
+	for {
+		switch p.lexer.Current {
+		case lexer.EOF:
+			return
+
+		case lexer.Semicolon:
+			if isStatement && len(closers) == 0 {
+				p.lexer.Skip()
+				return
+			}
+
+		case lexer.LCurly:
+			closers = append(closers, lexer.RCurly)
+
+		case lexer.LParen, lexer.FunctionStart:
+			closers = append(closers, lexer.RParen)
+
+		case lexer.LBracket:
+			closers = append(closers, lexer.RBracket)
+
+		case lexer.RCurly, lexer.RParen, lexer.RBracket:
+			if len(closers) == 0 {
+				if p.lexer.Current == lexer.RCurly {
+					return
+				}
+				break
+			}
+
+			// Close any unbalanced blocks inside of this one, e.g. an unclosed (.
+			i := len(closers) - 1
+			for i > 0 && closers[i] != p.lexer.Current {
+				i--
+			}
+			if closers[i] != p.lexer.Current {
+				break
+			}
+			closers = closers[:i]
+
+			if len(closers) == 0 && p.lexer.Current == lexer.RCurly {
+				p.lexer.Skip()
+				return
+			}
+		}
+
+		p.lexer.Skip()
+	}
+}
+
 func isImportantString(in string) bool {
 	return len(in) == 9 &&
 		(in[0] == 'i' || in[0] == 'I') &&
@@ -108,15 +239,61 @@ func (p *parser) parseQualifiedRule(isKeyframes bool) *ast.QualifiedRule {
 }
 
 // parseDeclarationBlock parses a {} block with declarations, e.g.
-// { width: 1px; }.
+// { width: 1px; }. The block may also contain nested rules, e.g.
+// { width: 1px; &:hover { width: 2px; } }.
 func (p *parser) parseDeclarationBlock() *ast.DeclarationBlock {
 	block := &ast.DeclarationBlock{
 		Span: p.lexer.TokenSpan(),
 	}
 	p.lexer.Next()
 
+	p.nesting++
+	defer func() {
+		p.nesting--
+	}()
+
 	for p.lexer.Current != lexer.RCurly {
-		block.Declarations = append(block.Declarations, p.parseDeclarationOrFallback())
+		switch p.lexer.Current {
+		case lexer.EOF:
+			p.lexer.Errorf("unexpected EOF")
+
+		case lexer.Semicolon:
+			p.lexer.Next()
+			continue
+
+		case lexer.At:
+			p.recoverFrom(true, func() {
+				block.Declarations = append(block.Declarations, p.parseAtRule())
+			})
+			continue
+
+		case lexer.Colon, lexer.Hash, lexer.LBracket:
+			p.recoverFrom(true, func() {
+				block.Declarations = append(block.Declarations, p.parseQualifiedRule(false))
+			})
+			continue
+
+		case lexer.Delim:
+			if p.lexer.CurrentString != "*" && isSelectorDelim(p.lexer.CurrentString) {
+				p.recoverFrom(true, func() {
+					block.Declarations = append(block.Declarations, p.parseQualifiedRule(false))
+				})
+				continue
+			}
+
+			p.recoverFrom(true, func() {
+				block.Declarations = append(block.Declarations, p.parseDeclarationOrFallback())
+			})
+
+		default:
+			// Declarations and nested rules can both start with an identifier, e.g.
+			// color: red vs. div:hover {}, so parseDeclarationOrFallback needs to
+			// look further ahead.
+			p.recoverFrom(true, func() {
+				block.Declarations = append(block.Declarations, p.parseDeclarationOrFallback())
+			})
+		}
+
 		if p.lexer.Current == lexer.Semicolon {
 			p.lexer.Next()
 		}
@@ -126,33 +303,107 @@ func (p *parser) parseDeclarationBlock() *ast.DeclarationBlock {
 	return block
 }
 
-func (p *parser) parseDeclarationOrFallback() (rv ast.Declarationish) {
+// isSelectorDelim returns whether or not the delimiter can start a selector.
+func isSelectorDelim(delim string) bool {
+	switch delim {
+	case "&", ".", "*", ">", "+", "~", "|":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseDeclarationOrFallback attempts to parse a declaration. If the input
+// is not a declaration, it tries to parse a nested rule instead. Custom
+// properties can hold almost any value, so they fall back to a raw value.
+// Otherwise, the declaration's error is reported.
+func (p *parser) parseDeclarationOrFallback() ast.Declarationish {
+	isCustomProperty := p.lexer.Current == lexer.Ident && strings.HasPrefix(p.lexer.CurrentString, "--")
+
+	var decl ast.Declarationish
+	declErr := p.tryParse(func() {
+		d := p.parseDeclaration()
+		if p.lexer.Current != lexer.Semicolon && p.lexer.Current != lexer.RCurly {
+			p.lexer.Errorf("expected ; or }, but got %s instead", p.lexer.Current.String())
+		}
+		decl = d
+	})
+	if declErr == nil {
+		return decl
+	}
+
+	var rule ast.Declarationish
+	if err := p.tryParse(func() { rule = p.parseQualifiedRule(false) }); err == nil {
+		return rule
+	}
+
+	if isCustomProperty {
+		return p.parseRaw()
+	}
+	panic(declErr)
+}
+
+// tryParse runs parse. If parse fails, the lexer is restored to its state from
+// before parse was run and the error is returned.
+func (p *parser) tryParse(parse func()) (err *lexer.Error) {
 	lexerState := *p.lexer
 	defer func() {
-		if err := recover(); err != nil {
-			// Restore the lexer and try again to read the value.
+		if rErr := recover(); rErr != nil {
+			lexErr, ok := rErr.(*lexer.Error)
+			if !ok {
+				panic(rErr)
+			}
+
 			p.lexer = &lexerState
-			rv = p.parseRaw()
+			err = lexErr
 		}
 	}()
 
-	return p.parseDeclaration()
+	parse()
+	return nil
 }
 
+// parseRaw parses the rest of a declaration as-is, up to but not including the
+// ; or the } that closes the enclosing block.
 func (p *parser) parseRaw() *ast.Raw {
 	raw := &ast.Raw{
 		Span: p.lexer.TokenSpan(),
 	}
 
-	for p.lexer.Current != lexer.Semicolon {
-		p.lexer.Next()
-	}
-	raw.End = p.lexer.TokenEnd()
-	p.lexer.Next()
+	var closers []lexer.Token
+	for {
+		switch p.lexer.Current {
+		case lexer.EOF:
+			p.lexer.Errorf("unexpected EOF")
+
+		case lexer.LCurly:
+			closers = append(closers, lexer.RCurly)
+
+		case lexer.LParen, lexer.FunctionStart:
+			closers = append(closers, lexer.RParen)
 
-	raw.Value = p.source.Content[raw.Start:raw.End]
+		case lexer.LBracket:
+			closers = append(closers, lexer.RBracket)
 
-	return raw
+		case lexer.RCurly, lexer.RParen, lexer.RBracket:
+			if len(closers) == 0 && p.lexer.Current == lexer.RCurly {
+				raw.Value = p.source.Content[raw.Start:raw.End]
+				return raw
+			}
+			if len(closers) > 0 && closers[len(closers)-1] == p.lexer.Current {
+				closers = closers[:len(closers)-1]
+			}
+
+		case lexer.Semicolon:
+			if len(closers) == 0 {
+				raw.Value = p.source.Content[raw.Start:raw.End]
+				return raw
+			}
+		}
+
+		raw.End = p.lexer.TokenEnd()
+		p.lexer.Next()
+	}
 }
 
 func (p *parser) parseDeclaration() *ast.Declaration {
@@ -440,6 +691,13 @@ func (p *parser) parseValue() ast.Value {
 					fn.Arguments = append(fn.Arguments, p.parseMathExpression())
 					continue
 				}
+
+				// Delimiters separate some arguments, e.g. the alpha in rgb(0 0 0 / 50%).
+				if p.lexer.Current == lexer.Delim {
+					fn.Arguments = append(fn.Arguments, &ast.Raw{Span: p.lexer.TokenSpan(), Value: p.lexer.CurrentString})
+					p.lexer.Next()
+					continue
+				}
 				val := p.parseValue()
 				if val == nil {
 					// XXX: there's probably some backtracking to do here?
@@ -456,28 +714,37 @@ func (p *parser) parseValue() ast.Value {
 	}
 }
 
-func (p *parser) parseAtRule() {
+func (p *parser) parseAtRule() *ast.AtRule {
 	switch p.lexer.CurrentString {
 	case "import":
-		p.parseImportAtRule()
+		return p.parseImportAtRule()
 
 	case "media":
-		p.parseMediaAtRule()
+		return p.parseMediaAtRule()
+
+	case "supports":
+		return p.parseSupportsAtRule()
+
+	case "layer":
+		return p.parseLayerAtRule()
+
+	case "container":
+		return p.parseContainerAtRule()
 
-	case "keyframes", "-webkit-keyframes", "-o-keyframes":
-		p.parseKeyframes()
+	case "keyframes", "-webkit-keyframes", "-moz-keyframes", "-o-keyframes":
+		return p.parseKeyframes()
 
 	case "custom-media":
-		p.parseCustomMediaAtRule()
+		return p.parseCustomMediaAtRule()
 
 	default:
-		p.parseGenericAtRule()
+		return p.parseGenericAtRule()
 	}
 }
 
 // parseImportAtRule parses an import at rule. It roughly implements
 // https://www.w3.org/TR/css-cascade-4/#at-import.
-func (p *parser) parseImportAtRule() {
+func (p *parser) parseImportAtRule() *ast.AtRule {
 	prelude := &ast.String{}
 
 	imp := &ast.AtRule{
@@ -526,19 +793,61 @@ func (p *parser) parseImportAtRule() {
 		p.lexer.Errorf("unexpected import specifier")
 	}
 
-	// XXX: also support @supports.
+	switch {
+	case p.lexer.Current == lexer.Ident && p.lexer.CurrentString == "layer":
+		imp.Preludes = append(imp.Preludes, &ast.Identifier{
+			Span:  p.lexer.TokenSpan(),
+			Value: p.lexer.CurrentString,
+		})
+		p.lexer.Next()
+
+	case p.lexer.Current == lexer.FunctionStart && p.lexer.CurrentString == "layer":
+		fn := &ast.Function{
+			Span: p.lexer.TokenSpan(),
+			Name: p.lexer.CurrentString,
+		}
+		p.lexer.Next()
+
+		fn.Arguments = []ast.Value{p.parseLayerName()}
+		fn.End = p.lexer.TokenEnd()
+		p.lexer.Expect(lexer.RParen)
+		imp.Preludes = append(imp.Preludes, fn)
+	}
+
+	if p.lexer.Current == lexer.FunctionStart && p.lexer.CurrentString == "supports" {
+		supports := &ast.ImportSupports{
+			Span: p.lexer.TokenSpan(),
+		}
+		p.lexer.Next()
+
+		// The condition may also be a bare declaration, e.g. supports(display: grid).
+		if p.lexer.Current == lexer.Ident && p.lexer.CurrentString != "not" {
+			decl := p.parseDeclaration()
+			supports.Condition = &ast.SupportsCondition{
+				Span:  decl.Span,
+				Parts: []ast.SupportsConditionPart{&ast.SupportsDeclaration{Span: decl.Span, Declaration: decl}},
+			}
+		} else {
+			supports.Condition = p.parseSupportsCondition()
+		}
+
+		supports.End = p.lexer.TokenEnd()
+		p.lexer.Expect(lexer.RParen)
+		imp.Preludes = append(imp.Preludes, supports)
+	}
+
 	mq := p.parseMediaQueryList()
 	if mq != nil {
 		imp.Preludes = append(imp.Preludes, mq)
 	}
 
 	imp.End = imp.Preludes[len(imp.Preludes)-1].Location().End
-	p.ss.Nodes = append(p.ss.Nodes, imp)
+	return imp
 }
 
 // parseKeyframes parses a keyframes at rule. It roughly implements
 // https://www.w3.org/TR/css-animations-1/#keyframes
-func (p *parser) parseKeyframes() {
+func (p *parser) parseKeyframes() *ast.AtRule {
 	r := &ast.AtRule{
 		Span: p.lexer.TokenSpan(),
 		Name: p.lexer.CurrentString,
@@ -574,21 +883,22 @@ func (p *parser) parseKeyframes() {
 			p.lexer.Errorf("unexpected EOF")
 
 		case lexer.RCurly:
-			p.ss.Nodes = append(p.ss.Nodes, r)
 			block.End = p.lexer.TokenEnd()
 			r.End = block.End
 			p.lexer.Next()
-			return
+			return r
 
 		default:
-			block.Rules = append(block.Rules, p.parseQualifiedRule(true))
+			p.recoverFrom(false, func() {
+				block.Rules = append(block.Rules, p.parseQualifiedRule(true))
+			})
 		}
 	}
 }
 
 // parseMediaAtRule parses a media at rule. It roughly implements
 // https://www.w3.org/TR/mediaqueries-4/#media.
-func (p *parser) parseMediaAtRule() {
+func (p *parser) parseMediaAtRule() *ast.AtRule {
 	r := &ast.AtRule{
 		Span: p.lexer.TokenSpan(),
 		Name: p.lexer.CurrentString,
@@ -597,10 +907,62 @@ func (p *parser) parseMediaAtRule() {
 
 	r.Preludes = []ast.AtPrelude{p.parseMediaQueryList()}
 
+	r.Block = p.parseConditionalBlock()
+	r.End = r.Block.Location().End
+	return r
+}
+
+// parseContainerAtRule parses a @container rule with an optional container name and
+// a container condition. The condition uses the same syntax as a media query. It roughly
+// implements https://www.w3.org/TR/css-contain-3/#container-rule.
+func (p *parser) parseContainerAtRule() *ast.AtRule {
+	r := &ast.AtRule{
+		Span: p.lexer.TokenSpan(),
+		Name: p.lexer.CurrentString,
+	}
+	p.lexer.Next()
+
+	if p.lexer.Current == lexer.Ident && p.lexer.CurrentString != "not" {
+		r.Preludes = append(r.Preludes, &ast.Identifier{
+			Span:  p.lexer.TokenSpan(),
+			Value: p.lexer.CurrentString,
+		})
+		p.lexer.Next()
+	}
+
+	// A container name without a condition matches any container with that name.
+	if query := p.parseMediaQuery(); query != nil {
+		r.Preludes = append(r.Preludes, query)
+	} else if len(r.Preludes) == 0 {
+		p.lexer.Errorf("expected container name or condition")
+	}
+
+	r.Block = p.parseConditionalBlock()
+	r.End = r.Block.Location().End
+	return r
+}
+
+// parseConditionalBlock parses the body of a conditional group rule, like
+// @media or @supports. At the top-level, the body is a block of rules. If the
+// rule is nested inside of a style rule, the body is a declaration block.
+// See: https://www.w3.org/TR/css-nesting-1/#conditionals.
+func (p *parser) parseConditionalBlock() ast.Block {
+	if p.nesting > 0 {
+		if p.lexer.Current != lexer.LCurly {
+			p.lexer.Errorf("expected %s, but got %s instead", lexer.LCurly.String(), p.lexer.Current.String())
+		}
+		return p.parseDeclarationBlock()
+	}
+
+	return p.parseQualifiedRuleBlock()
+}
+
+// parseQualifiedRuleBlock parses a {} block of rules, e.g. the body of a
+// @media or @supports rule. The block may also contain nested at-rules.
+func (p *parser) parseQualifiedRuleBlock() *ast.QualifiedRuleBlock {
 	block := &ast.QualifiedRuleBlock{
 		Span: p.lexer.TokenSpan(),
 	}
-	r.Block = block
 	p.lexer.Expect(lexer.LCurly)
 	for {
 		switch p.lexer.Current {
@@ -608,16 +970,229 @@ func (p *parser) parseMediaAtRule() {
 			p.lexer.Errorf("unexpected EOF")
 
 		case lexer.RCurly:
-			p.ss.Nodes = append(p.ss.Nodes, r)
 			block.End = p.lexer.TokenEnd()
-			r.End = block.End
 			p.lexer.Next()
-			return
+			return block
+
+		case lexer.Semicolon:
+			p.lexer.Next()
+
+		case lexer.At:
+			p.recoverFrom(true, func() {
+				block.Rules = append(block.Rules, p.parseAtRule())
+			})
 
 		default:
-			block.Rules = append(block.Rules, p.parseQualifiedRule(false))
+			p.recoverFrom(false, func() {
+				block.Rules = append(block.Rules, p.parseQualifiedRule(false))
+			})
+		}
+	}
+}
+
+// parseLayerAtRule parses a @layer rule, either as a statement that declares
+// layer order or as a block of layered rules. It roughly implements
+// https://www.w3.org/TR/css-cascade-5/#layering.
+func (p *parser) parseLayerAtRule() *ast.AtRule {
+	r := &ast.AtRule{
+		Span: p.lexer.TokenSpan(),
+		Name: p.lexer.CurrentString,
+	}
+	p.lexer.Next()
+
+	var names *ast.LayerList
+	if p.lexer.Current == lexer.Ident {
+		names = &ast.LayerList{
+			Span: p.lexer.TokenSpan(),
+		}
+		for {
+			names.Names = append(names.Names, p.parseLayerName())
+			if p.lexer.Current != lexer.Comma {
+				break
+			}
+			p.lexer.Next()
+		}
+		names.End = names.Names[len(names.Names)-1].End
+		r.Preludes = []ast.AtPrelude{names}
+		r.End = names.End
+	}
+
+	switch p.lexer.Current {
+	case lexer.LCurly:
+		if names != nil && len(names.Names) > 1 {
+			p.lexer.Errorf("@layer block must have at most one layer name")
+		}
+		r.Block = p.parseConditionalBlock()
+		r.End = r.Block.Location().End
+
+	case lexer.Semicolon, lexer.RCurly, lexer.EOF:
+		if names == nil {
+			p.lexer.Errorf("@layer statement must have at least one layer name")
+		}
+
+	default:
+		p.lexer.Errorf("unexpected token: %s, expected ; or { after @layer", p.lexer.Current.String())
+	}
+
+	return r
+}
+
+// parseLayerName parses a <layer-name>, which is a list of identifiers
+// separated by dots with no whitespace in between, e.g. framework.base.
+func (p *parser) parseLayerName() *ast.Identifier {
+	name := &ast.Identifier{
+		Span:  p.lexer.TokenSpan(),
+		Value: p.lexer.CurrentString,
+	}
+	p.lexer.Expect(lexer.Ident)
+
+	for p.lexer.Current == lexer.Delim && p.lexer.CurrentString == "." && p.lexer.TokenSpan().Start == name.End {
+		p.lexer.Next()
+		if p.lexer.Current != lexer.Ident || p.lexer.TokenSpan().Start != name.End+1 {
+			p.lexer.Errorf("expected identifier after . in layer name")
+		}
+		name.Value += "." + p.lexer.CurrentString
+		name.End = p.lexer.TokenEnd()
+		p.lexer.Next()
+	}
+
+	return name
+}
+
+// parseSupportsAtRule parses a @supports rule. It roughly implements
+// https://www.w3.org/TR/css-conditional-3/#at-supports.
+func (p *parser) parseSupportsAtRule() *ast.AtRule {
+	r := &ast.AtRule{
+		Span: p.lexer.TokenSpan(),
+		Name: p.lexer.CurrentString,
+	}
+	p.lexer.Next()
+
+	r.Preludes = []ast.AtPrelude{p.parseSupportsCondition()}
+
+	r.Block = p.parseConditionalBlock()
+	r.End = r.Block.Location().End
+	return r
+}
+
+// parseSupportsCondition parses a <supports-condition>, i.e. a single
+// feature test, a negated test, or a list of tests joined by and or or.
+func (p *parser) parseSupportsCondition() *ast.SupportsCondition {
+	c := &ast.SupportsCondition{
+		Span: p.lexer.TokenSpan(),
+	}
+
+	if p.lexer.Current == lexer.Ident && p.lexer.CurrentString == "not" {
+		c.Operator = p.lexer.CurrentString
+		p.lexer.Next()
+
+		c.Parts = append(c.Parts, p.parseSupportsInParens())
+		c.End = c.Parts[0].Location().End
+		return c
+	}
+
+	c.Parts = append(c.Parts, p.parseSupportsInParens())
+	for p.lexer.Current == lexer.Ident && (p.lexer.CurrentString == "and" || p.lexer.CurrentString == "or") {
+		if c.Operator != "" && c.Operator != p.lexer.CurrentString {
+			p.lexer.Errorf("cannot mix and and or in @supports condition without parenthesis")
+		}
+		c.Operator = p.lexer.CurrentString
+		p.lexer.Next()
+
+		c.Parts = append(c.Parts, p.parseSupportsInParens())
+	}
+
+	c.End = c.Parts[len(c.Parts)-1].Location().End
+	return c
+}
+
+// parseSupportsInParens parses a <supports-in-parens>, which is either a
+// parenthesized condition, a declaration test, or a selector() test.
+func (p *parser) parseSupportsInParens() ast.SupportsConditionPart {
+	switch p.lexer.Current {
+	case lexer.FunctionStart:
+		if p.lexer.CurrentString != "selector" {
+			return p.parseGeneralEnclosed()
+		}
+
+		s := &ast.SupportsSelector{
+			Span: p.lexer.TokenSpan(),
+		}
+		p.lexer.Next()
+
+		s.Selector = p.parseSelector()
+		s.End = p.lexer.TokenEnd()
+		p.lexer.Expect(lexer.RParen)
+		return s
+
+	case lexer.LParen:
+		var part ast.SupportsConditionPart
+		if err := p.tryParse(func() { part = p.parseSupportsTest() }); err != nil {
+			// Anything else in parenthesis is valid, but unknown, e.g. (foo bar).
+			return p.parseGeneralEnclosed()
+		}
+		return part
+
+	default:
+		p.lexer.Errorf("unexpected token: %s, expected ( or selector( in @supports condition", p.lexer.Current.String())
+		return nil
+	}
+}
+
+// parseSupportsTest parses a parenthesized declaration test or condition, e.g.
+// (display: grid) or (not (display: grid)).
+func (p *parser) parseSupportsTest() ast.SupportsConditionPart {
+	start := p.lexer.TokenSpan()
+	p.lexer.Expect(lexer.LParen)
+
+	// Anything that starts with an identifier other than not is a declaration, e.g.
+	// (display: grid). Otherwise, it's a nested condition.
+	if p.lexer.Current == lexer.Ident && p.lexer.CurrentString != "not" {
+		d := &ast.SupportsDeclaration{
+			Span:        start,
+			Declaration: p.parseDeclaration(),
+		}
+		d.End = p.lexer.TokenEnd()
+		p.lexer.Expect(lexer.RParen)
+		return d
+	}
+
+	c := p.parseSupportsCondition()
+	c.Start = start.Start
+	c.End = p.lexer.TokenEnd()
+	p.lexer.Expect(lexer.RParen)
+	return c
+}
+
+// parseGeneralEnclosed parses a function or a parenthesized block as-is, e.g. font-tech(color-COLRv1)
+// or (foo bar). See: https://www.w3.org/TR/mediaqueries-4/#typedef-general-enclosed.
+func (p *parser) parseGeneralEnclosed() *ast.GeneralEnclosed {
+	g := &ast.GeneralEnclosed{
+		Span: p.lexer.TokenSpan(),
+	}
+	if p.lexer.Current != lexer.FunctionStart && p.lexer.Current != lexer.LParen {
+		p.lexer.Errorf("expected ( or function, but got %s", p.lexer.Current.String())
+	}
+	p.lexer.Next()
+
+	depth := 1
+	for depth > 0 {
+		switch p.lexer.Current {
+		case lexer.EOF:
+			p.lexer.Errorf("unexpected EOF")
+
+		case lexer.LParen, lexer.FunctionStart:
+			depth++
+
+		case lexer.RParen:
+			depth--
 		}
+		g.End = p.lexer.TokenEnd()
+		p.lexer.Next()
 	}
+
+	g.Value = p.source.Content[g.Start:g.End]
+	return g
 }
 
 func (p *parser) parseMediaQueryList() *ast.MediaQueryList {
@@ -662,7 +1237,11 @@ func (p *parser) parseMediaQuery() *ast.MediaQuery {
 			p.lexer.Errorf("unexpected EOF")
 
 		case lexer.LParen:
-			q.Parts = append(q.Parts, p.parseMediaFeature())
+			q.Parts = append(q.Parts, p.parseMediaInParens())
+
+		case lexer.FunctionStart:
+			// Functions like style() in container queries are passed through as-is.
+			q.Parts = append(q.Parts, p.parseGeneralEnclosed())
 
 		case lexer.Ident:
 			q.Parts = append(q.Parts, p.parseValue().(*ast.Identifier))
@@ -678,10 +1257,38 @@ func (p *parser) parseMediaQuery() *ast.MediaQuery {
 	}
 }
 
-func (p *parser) parseMediaFeature() ast.MediaFeature {
+// parseMediaInParens parses a media feature or a nested condition in parenthesis, e.g.
+// (width > 400px) or ((width > 400px) and (orientation: portrait)).
+func (p *parser) parseMediaInParens() ast.MediaQueryPart {
 	startLoc := p.lexer.TokenSpan()
 	p.lexer.Expect(lexer.LParen)
 
+	var parts []ast.MediaQueryPart
+	if p.lexer.Current == lexer.Ident && p.lexer.CurrentString == "not" {
+		parts = append(parts, &ast.Identifier{Span: p.lexer.TokenSpan(), Value: p.lexer.CurrentString})
+		p.lexer.Next()
+		if p.lexer.Current != lexer.LParen && p.lexer.Current != lexer.FunctionStart {
+			p.lexer.Errorf("expected ( after not")
+		}
+	}
+
+	if p.lexer.Current != lexer.LParen && p.lexer.Current != lexer.FunctionStart {
+		return p.parseMediaFeature(startLoc)
+	}
+
+	inner := p.parseMediaQuery()
+	if inner != nil {
+		parts = append(parts, inner.Parts...)
+	}
+
+	r := &ast.MediaInParens{Span: startLoc, Parts: parts}
+	r.End = p.lexer.TokenEnd()
+	p.lexer.Expect(lexer.RParen)
+	return r
+}
+
+// parseMediaFeature parses a media feature, starting after its opening parenthesis at startLoc.
+func (p *parser) parseMediaFeature(startLoc ast.Span) ast.MediaFeature {
 	firstValue := p.parseValue()
 
 	switch p.lexer.Current {
@@ -808,7 +1415,7 @@ func (p *parser) parseMediaRangeOperator() string {
 
 // parseCustomMediaAtRule parses a @custom-media rule.
 // See: https://www.w3.org/TR/mediaqueries-5/#custom-mq.
-func (p *parser) parseCustomMediaAtRule() {
+func (p *parser) parseCustomMediaAtRule() *ast.AtRule {
 	r := &ast.AtRule{
 		Span: p.lexer.TokenSpan(),
 		Name: p.lexer.CurrentString,
@@ -824,17 +1431,17 @@ func (p *parser) parseCustomMediaAtRule() {
 
 	r.Preludes = append(r.Preludes, name)
 	queries := p.parseMediaQueryList()
-	if len(queries.Queries) != 1 {
+	if queries == nil || len(queries.Queries) != 1 {
 		p.lexer.Errorf("@custom-media rule requires a single media query argument")
 	}
 	r.Preludes = append(r.Preludes, queries.Queries[0])
 	r.End = queries.Queries[0].End
 
-	p.ss.Nodes = append(p.ss.Nodes, r)
+	return r
 }
 
 // parseGenericAtRule parses a generic atrule like @font-face.
-func (p *parser) parseGenericAtRule() {
+func (p *parser) parseGenericAtRule() *ast.AtRule {
 	r := &ast.AtRule{
 		Span: p.lexer.TokenSpan(),
 		Name: p.lexer.CurrentString,
@@ -844,5 +1451,5 @@ func (p *parser) parseGenericAtRule() {
 	r.Block = p.parseDeclarationBlock()
 	r.End = r.Block.Location().End
 
-	p.ss.Nodes = append(p.ss.Nodes, r)
+	return r
 }
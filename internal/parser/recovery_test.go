@@ -0,0 +1,132 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseWithErrors parses css and returns the printed partial stylesheet along with
+// the message of every error.
+func parseWithErrors(t testing.TB, css string) (string, []string) {
+	ss, err := parser.Parse(&sources.Source{
+		Path:    "main.css",
+		Content: css,
+	})
+	require.NotNil(t, ss)
+
+	var messages []string
+	if err != nil {
+		errs, ok := err.(parser.Errors)
+		require.True(t, ok, "expected parser.Errors, got %T", err)
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+	}
+
+	out, err := printer.Print(ss, printer.Options{})
+	require.NoError(t, err)
+	return out, messages
+}
+
+func TestRecovery_Rules(t *testing.T) {
+	out, errs := parseWithErrors(t, `
+.a { color: red }
+@media (1px) { .b { color: blue } }
+.c { color: green }
+@keyframes { from { opacity: 0 } }
+.d { color: black }`)
+	assert.Equal(t, `.a{color:red}.c{color:green}.d{color:black}`, out)
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs[0], "main.css:3:")
+	assert.Contains(t, errs[1], "main.css:5:")
+}
+
+func TestRecovery_QualifiedRuleEndsAtBlock(t *testing.T) {
+	// A qualified rule only ends after its block, so the invalid rule includes .b.
+	out, errs := parseWithErrors(t, `.a; .b { color: red } .c { color: blue }`)
+	assert.Equal(t, `.c{color:blue}`, out)
+	assert.Len(t, errs, 1)
+}
+
+func TestRecovery_Statements(t *testing.T) {
+	out, errs := parseWithErrors(t, `@import 12; @import "a.css"; .a { color: red }`)
+	assert.Equal(t, `@import "a.css";.a{color:red}`, out)
+	assert.Len(t, errs, 1)
+
+	// Invalid rules don't leave imports behind. The unclosed ( also includes the next @import.
+	ss, err := parser.Parse(&sources.Source{Path: "main.css", Content: `@import "a.css" layer(; @import "b.css";`})
+	assert.Error(t, err)
+	assert.Empty(t, ss.Nodes)
+	assert.Empty(t, ss.Imports)
+}
+
+func TestRecovery_CustomMedia(t *testing.T) {
+	out, errs := parseWithErrors(t, `@custom-media --foo; @custom-media --bar (min-width: 1px), print; .a { color: red }`)
+	assert.Equal(t, `.a{color:red}`, out)
+	require.Len(t, errs, 2)
+	assert.Contains(t, errs[0], "@custom-media rule requires a single media query argument")
+}
+
+func TestRecovery_NestedBlocks(t *testing.T) {
+	out, errs := parseWithErrors(t, `
+@media print {
+	.a { color: red }
+	@supports display { .b { color: blue } }
+	.c { color: green }
+}
+.d { color: black }`)
+	assert.Equal(t, `@media print{.a{color:red}.c{color:green}}.d{color:black}`, out)
+	assert.Len(t, errs, 1)
+
+	out, errs = parseWithErrors(t, `.a { color: red; @media (1px) { color: blue } margin: 0 }`)
+	assert.Equal(t, `.a{color:red;margin:0}`, out)
+	assert.Len(t, errs, 1)
+}
+
+func TestRecovery_Tokens(t *testing.T) {
+	out, errs := parseWithErrors(t, "} .a { content: \"unclosed\n; color: red }\n.b { color: blue }")
+	assert.Equal(t, `.a{color:red}.b{color:blue}`, out)
+	assert.Len(t, errs, 2)
+
+}
+
+func TestRecovery_EOF(t *testing.T) {
+	// Every enclosing block runs into the end of the file, but it's only reported once.
+	out, errs := parseWithErrors(t, `.a { color: red } @media print { .b { color: blue`)
+	assert.Equal(t, `.a{color:red}`, out)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "unexpected EOF")
+}
+
+func TestRecovery_Declarations(t *testing.T) {
+	out, errs := parseWithErrors(t, `.a { color red } .b { color: blue; }`)
+	assert.Equal(t, `.a{}.b{color:blue}`, out)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "main.css:1:")
+
+	out, errs = parseWithErrors(t, `.a { color: red !importnt; margin: 0 }`)
+	assert.Equal(t, `.a{margin:0}`, out)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "main.css:1:")
+
+	out, errs = parseWithErrors(t, `.a { color: red; width 1px; height: 2px }
+.b { color: blue }`)
+	assert.Equal(t, `.a{color:red;height:2px}.b{color:blue}`, out)
+	assert.Len(t, errs, 1)
+}
+
+func TestRecovery_CustomProperties(t *testing.T) {
+	// Custom properties keep values that aren't otherwise valid.
+	out, errs := parseWithErrors(t, `:root { --x: } .a { color: red }`)
+	assert.Equal(t, `:root{--x:}.a{color:red}`, out)
+	assert.Empty(t, errs)
+
+	out, errs = parseWithErrors(t, `:root { --x: { a: b }; --y: [}]; color: red }`)
+	assert.Equal(t, `:root{--x: { a: b };--y: [}];color:red}`, out)
+	assert.Empty(t, errs)
+}
@@ -95,6 +95,12 @@ func (p *parser) parseSelector() *ast.Selector {
 				})
 				p.lexer.Next()
 
+			case "&":
+				s.Parts = append(s.Parts, &ast.NestingSelector{
+					Span: p.lexer.TokenSpan(),
+				})
+				p.lexer.Next()
+
 			default:
 				p.lexer.Errorf("unexpected delimeter: %s", p.lexer.CurrentString)
 			}
@@ -126,7 +132,14 @@ func (p *parser) parseSelector() *ast.Selector {
 			case lexer.FunctionStart:
 				p.lexer.Next()
 
-				if pc.Name == "nth-child" || pc.Name == "nth-last-child" || pc.Name == "nth-of-type" || pc.Name == "nth-last-of-type" {
+				if pc.Name == "import" && p.lexer.Current == lexer.String {
+					// ICSS imports take a path, e.g. :import("./colors.css").
+					pc.Arguments = &ast.String{
+						Span:  p.lexer.TokenSpan(),
+						Value: p.lexer.CurrentString,
+					}
+					p.lexer.Next()
+				} else if pc.Name == "nth-child" || pc.Name == "nth-last-child" || pc.Name == "nth-of-type" || pc.Name == "nth-last-of-type" {
 					switch p.lexer.Current {
 					case lexer.Number, lexer.Dimension:
 						pc.Arguments = p.parseANPlusB()
@@ -67,6 +67,10 @@ func TestSpans(t *testing.T) {
 	border-radius: 2px;;
 	width: 200px;
 }
+
+@supports (display: grid) and (not selector(:has(a))) {
+	.grid { display: grid; }
+}
 `,
 	}
 
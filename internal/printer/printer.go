@@ -1,9 +1,9 @@
 package printer
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"strings"
 
@@ -18,26 +18,62 @@ type printer struct {
 	sourceMappings   strings.Builder
 	lastWritten      int
 	lastMappingState mappingState
+
+	// source is the source of the node currently being printed.
+	source *sources.Source
+
+	// sources is the list of sources in the source map, and sourceIndices
+	// is the index of each source in it.
+	sources       []*sources.Source
+	sourceIndices map[*sources.Source]int32
 }
 
 type mappingState struct {
 	generatedColumn int32
+	sourceIndex     int32
 	originalLine    int32
 	originalColumn  int32
 }
 
 // Options is a set of options for printing.
 type Options struct {
+	// OriginalSource is the source that the AST was parsed from. Source maps
+	// are only generated if it is set.
 	OriginalSource *sources.Source
+
+	// OutputPath is the path that the output will be written to. If set, sources
+	// in the source map are relative to it.
+	OutputPath string
+
+	// ExcludeSourcesContent leaves the content of the original source out of the
+	// source map.
+	ExcludeSourcesContent bool
+
+	// NodeSources is the source of nodes that did not come from OriginalSource,
+	// e.g. rules from an inlined import. Nodes without an entry have the same
+	// source as their parent.
+	NodeSources map[ast.Node]*sources.Source
 }
 
 // Print prints the input AST node into CSS. It should have deterministic
-// output.
+// output. If OriginalSource is set, an inline source map is appended to the output.
 func Print(in ast.Node, opts Options) (output string, err error) {
+	output, sourceMap, err := PrintWithSourceMap(in, opts)
+	if err != nil || sourceMap == "" {
+		return output, err
+	}
+
+	return output + SourceMappingURLComment(InlineSourceMapURL(sourceMap)), nil
+}
+
+// PrintWithSourceMap prints the input AST node into CSS like Print, but returns the
+// source map separately instead of appending it to the output. sourceMap is empty
+// if OriginalSource is not set.
+func PrintWithSourceMap(in ast.Node, opts Options) (output, sourceMap string, err error) {
 	defer func() {
 		if rErr := recover(); rErr != nil {
 			if errI, ok := rErr.(error); ok {
-				output, err = "", errI
+				output, sourceMap, err = "", "", errI
 				return
 			}
 
@@ -47,37 +83,69 @@ func Print(in ast.Node, opts Options) (output string, err error) {
 	}()
 
 	p := printer{
-		options: opts,
+		options:       opts,
+		source:        opts.OriginalSource,
+		sourceIndices: make(map[*sources.Source]int32),
+	}
+	if opts.OriginalSource != nil {
+		p.addSource(opts.OriginalSource)
 	}
 
 	p.print(in)
-	p.printMapping()
+	return p.s.String(), p.sourceMap(), nil
+}
 
-	return p.s.String(), nil
+// sourceMapV3 is the JSON format for source maps.
+// See: https://sourcemaps.info/spec.html.
+type sourceMapV3 struct {
+	Version        int      `json:"version"`
+	File           string   `json:"file"`
+	SourceRoot     string   `json:"sourceRoot"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent,omitempty"`
+	Names          []string `json:"names"`
+	Mappings       string   `json:"mappings"`
 }
 
-func (p *printer) printMapping() {
-	if p.options.OriginalSource == nil {
-		return
+// sourceMap returns the source map for everything printed so far.
+func (p *printer) sourceMap() string {
+	source := p.options.OriginalSource
+	if source == nil {
+		return ""
+	}
+
+	m := sourceMapV3{
+		Version:  3,
+		File:     source.Path,
+		Names:    []string{},
+		Mappings: p.sourceMappings.String(),
+	}
+
+	// Sources are relative to the output, or to the original source if
+	// the output path is not known.
+	dir := filepath.Dir(source.Path)
+	if p.options.OutputPath != "" {
+		m.File = filepath.Base(p.options.OutputPath)
+		dir = filepath.Dir(p.options.OutputPath)
+	}
+
+	for _, s := range p.sources {
+		path := s.Path
+		if rel, err := filepath.Rel(dir, s.Path); err == nil {
+			path = filepath.ToSlash(rel)
+		}
+		m.Sources = append(m.Sources, path)
+
+		if !p.options.ExcludeSourcesContent {
+			m.SourcesContent = append(m.SourcesContent, s.Content)
+		}
 	}
 
-	b := strings.Builder{}
-	b.WriteString(`{"version": 3,"file":"`)
-	b.WriteString(p.options.OriginalSource.Path)
-	// XXX: sources content and names.
-	b.WriteString(`","sourceRoot":"", "sources": ["source.css"], "sourcesContent":[`)
-	out, err := json.Marshal(p.options.OriginalSource.Content)
+	out, err := json.Marshal(m)
 	if err != nil {
 		panic(err)
 	}
-	b.Write(out)
-	b.WriteString(`],"names":[],"mappings":"`)
-	b.WriteString(p.sourceMappings.String())
-	b.WriteString(`"}`)
-	p.s.WriteString("\n/*# sourceMappingURL=data:application/json;base64,")
-	// XXX: allocation.
-	p.s.WriteString(base64.StdEncoding.EncodeToString([]byte(b.String())))
-	p.s.WriteString(" */\n")
+	return string(out)
 }
 
 // addMapping should be called from the printer
@@ -89,7 +157,8 @@ func (p *printer) addMapping(loc ast.Span) {
 
 	newState := p.lastMappingState
 
-	line, col := p.options.OriginalSource.LineAndCol(loc)
+	line, col := p.source.LineAndCol(loc)
+	newState.sourceIndex = p.addSource(p.source)
 	newState.originalLine, newState.originalColumn = line-1, col-1
 
 	// Note that String() here does not reallocate the string.
@@ -104,7 +173,7 @@ func (p *printer) addMapping(loc ast.Span) {
 		newState.generatedColumn++
 	}
 
-	if p.s.Len() > 0 {
+	if p.sourceMappings.Len() > 0 {
 		lastByte := p.sourceMappings.String()[p.sourceMappings.Len()-1]
 		if lastByte != ';' {
 			p.sourceMappings.WriteRune(',')
@@ -112,8 +181,7 @@ func (p *printer) addMapping(loc ast.Span) {
 	}
 
 	p.sourceMappings.Write(VLQEncode(newState.generatedColumn - p.lastMappingState.generatedColumn))
-	// XXX: figure out what to do for multiple sources.
-	p.sourceMappings.Write(VLQEncode(0))
+	p.sourceMappings.Write(VLQEncode(newState.sourceIndex - p.lastMappingState.sourceIndex))
 
 	p.sourceMappings.Write(VLQEncode(newState.originalLine - p.lastMappingState.originalLine))
 	p.sourceMappings.Write(VLQEncode(newState.originalColumn - p.lastMappingState.originalColumn))
@@ -123,8 +191,26 @@ func (p *printer) addMapping(loc ast.Span) {
 	p.lastWritten = p.s.Len()
 }
 
+// addSource returns the index of source in the source map, adding it if needed.
+func (p *printer) addSource(source *sources.Source) int32 {
+	if i, ok := p.sourceIndices[source]; ok {
+		return i
+	}
+
+	i := int32(len(p.sources))
+	p.sources = append(p.sources, source)
+	p.sourceIndices[source] = i
+	return i
+}
+
 // print prints the current ast node to the printer output.
 func (p *printer) print(in ast.Node) {
+	if source, ok := p.options.NodeSources[in]; ok && source != p.source {
+		parent := p.source
+		p.source = source
+		defer func() { p.source = parent }()
+	}
+
 	switch node := in.(type) {
 	case *ast.Stylesheet:
 		for _, n := range node.Nodes {
@@ -185,7 +271,14 @@ func (p *printer) print(in ast.Node) {
 		for i, d := range node.Declarations {
 			p.print(d)
 
-			if i+1 < len(node.Declarations) {
+			if i+1 >= len(node.Declarations) {
+				continue
+			}
+
+			switch d.(type) {
+			case *ast.QualifiedRule, *ast.AtRule:
+				// Nested rules are already terminated by their block.
+			default:
 				p.s.WriteRune(';')
 			}
 		}
@@ -193,19 +286,7 @@ func (p *printer) print(in ast.Node) {
 	case *ast.Declaration:
 		p.s.WriteString(node.Property)
 		p.s.WriteRune(':')
-		for i, val := range node.Values {
-			p.print(val)
-
-			// Print space if we're not the last value and the previous or current
-			// value was not a comma.
-			if i+1 < len(node.Values) {
-				if _, nextIsComma := node.Values[i+1].(*ast.Comma); !nextIsComma {
-					if _, isComma := val.(*ast.Comma); !isComma {
-						p.s.WriteRune(' ')
-					}
-				}
-			}
-		}
+		p.printValues(node.Values)
 
 		if node.Important {
 			p.s.WriteString("!important")
@@ -233,9 +314,7 @@ func (p *printer) print(in ast.Node) {
 	case *ast.Function:
 		p.s.WriteString(node.Name)
 		p.s.WriteRune('(')
-		for _, arg := range node.Arguments {
-			p.print(arg)
-		}
+		p.printValues(node.Arguments)
 		p.s.WriteRune(')')
 
 	case *ast.Brackets:
@@ -256,7 +335,14 @@ func (p *printer) print(in ast.Node) {
 
 	case *ast.MathExpression:
 		p.print(node.Left)
-		p.s.WriteString(node.Operator)
+		// + and - must be surrounded by whitespace, otherwise they're parsed as part of a number.
+		if node.Operator == "+" || node.Operator == "-" {
+			p.s.WriteRune(' ')
+			p.s.WriteString(node.Operator)
+			p.s.WriteRune(' ')
+		} else {
+			p.s.WriteString(node.Operator)
+		}
 		p.print(node.Right)
 
 	case *ast.Whitespace:
@@ -294,6 +380,9 @@ func (p *printer) print(in ast.Node) {
 	case *ast.CombinatorSelector:
 		p.s.WriteString(node.Operator)
 
+	case *ast.NestingSelector:
+		p.s.WriteRune('&')
+
 	case *ast.PseudoElementSelector:
 		p.s.WriteRune(':')
 		p.print(node.Inner)
@@ -343,6 +432,15 @@ func (p *printer) print(in ast.Node) {
 			}
 		}
 
+	case *ast.LayerList:
+		for i, name := range node.Names {
+			p.print(name)
+
+			if i+1 < len(node.Names) {
+				p.s.WriteRune(',')
+			}
+		}
+
 	case *ast.MediaQuery:
 		for i, part := range node.Parts {
 			p.print(part)
@@ -352,6 +450,17 @@ func (p *printer) print(in ast.Node) {
 			}
 		}
 
+	case *ast.MediaInParens:
+		p.s.WriteRune('(')
+		for i, part := range node.Parts {
+			p.print(part)
+
+			if i+1 < len(node.Parts) {
+				p.s.WriteRune(' ')
+			}
+		}
+		p.s.WriteRune(')')
+
 	case *ast.MediaFeaturePlain:
 		p.s.WriteRune('(')
 		p.print(node.Property)
@@ -374,6 +483,54 @@ func (p *printer) print(in ast.Node) {
 		}
 		p.s.WriteRune(')')
 
+	case *ast.SupportsCondition:
+		if node.Operator == "not" {
+			p.s.WriteString("not ")
+		}
+
+		for i, part := range node.Parts {
+			if i > 0 {
+				// Whitespace is significant here, since and( would be a function token.
+				p.s.WriteRune(' ')
+				p.s.WriteString(node.Operator)
+				p.s.WriteRune(' ')
+			}
+
+			if _, isCondition := part.(*ast.SupportsCondition); isCondition {
+				p.s.WriteRune('(')
+				p.print(part)
+				p.s.WriteRune(')')
+				continue
+			}
+
+			p.print(part)
+		}
+
+	case *ast.ImportSupports:
+		p.s.WriteString("supports(")
+		if len(node.Condition.Parts) == 1 && node.Condition.Operator == "" {
+			if decl, ok := node.Condition.Parts[0].(*ast.SupportsDeclaration); ok {
+				p.print(decl.Declaration)
+				p.s.WriteRune(')')
+				break
+			}
+		}
+		p.print(node.Condition)
+		p.s.WriteRune(')')
+
+	case *ast.SupportsDeclaration:
+		p.s.WriteRune('(')
+		p.print(node.Declaration)
+		p.s.WriteRune(')')
+
+	case *ast.SupportsSelector:
+		p.s.WriteString("selector(")
+		p.print(node.Selector)
+		p.s.WriteRune(')')
+
+	case *ast.GeneralEnclosed:
+		p.s.WriteString(node.Value)
+
 	case *ast.Raw:
 		p.s.WriteString(node.Value)
 
@@ -382,3 +539,21 @@ func (p *printer) print(in ast.Node) {
 	}
 
 }
+
+// printValues prints space-separated values, e.g. the values of a declaration or the
+// arguments of a function.
+func (p *printer) printValues(values []ast.Value) {
+	for i, val := range values {
+		p.print(val)
+
+		// Print space if we're not the last value and the previous or current
+		// value was not a comma.
+		if i+1 < len(values) {
+			if _, nextIsComma := values[i+1].(*ast.Comma); !nextIsComma {
+				if _, isComma := val.(*ast.Comma); !isComma {
+					p.s.WriteRune(' ')
+				}
+			}
+		}
+	}
+}
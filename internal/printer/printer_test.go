@@ -46,6 +46,8 @@ func TestClass_ComplexSelector(t *testing.T) {
 func TestMediaQueryRanges(t *testing.T) {
 	assert.Equal(t, `@media (200px<width<600px),(200px<width),(width<600px){}`,
 		Print(t, `@media (200px < width < 600px), (200px < width), (width < 600px) {}`))
+	assert.Equal(t, `@media ((width>200px) and (not (width>600px))){}`,
+		Print(t, `@media ((width > 200px) and (not (width > 600px))) {}`))
 }
 
 func TestKeyframes(t *testing.T) {
@@ -101,6 +103,153 @@ func TestURLValues(t *testing.T) {
 	assert.Equal(t, `svg{filter:url(#example)}`, Print(t, `svg { filter: url(#example); }`))
 }
 
+func TestFunctionArguments(t *testing.T) {
+	assert.Equal(t, `a{color:rgb(0 0 0 / 50%)}`, Print(t, `a { color: rgb(0 0 0 / 50%); }`))
+	assert.Equal(t, `a{transform:translate(1px,2px) rotate(3deg)}`, Print(t, `a { transform: translate(1px, 2px) rotate(3deg); }`))
+}
+
 func TestDeclarationHacks(t *testing.T) {
 	assert.Equal(t, `a{*letter-spacing:2rem}`, Print(t, `a { *letter-spacing: 2rem; }`))
 }
+
+func TestSupports(t *testing.T) {
+	assert.Equal(t, `@supports (display:grid){.a{display:grid}}`, Print(t, `@supports (display: grid) { .a { display: grid; } }`))
+	assert.Equal(t, `@supports not (display:grid){.a{float:left}}`, Print(t, `@supports not (display: grid) { .a { float: left; } }`))
+	assert.Equal(t, `@supports (display:grid) and (not (display:inline-grid)){}`, Print(t, `@supports (display: grid) and (not (display: inline-grid)) {}`))
+	assert.Equal(t, `@supports (display:flex) or (display:-webkit-box) or (display:-ms-flexbox){}`, Print(t, `@supports (display: flex) or (display: -webkit-box) or (display: -ms-flexbox) {}`))
+	assert.Equal(t, `@supports ((a:b) or (c:d)) and (e:f){}`, Print(t, `@supports ((a: b) or (c: d)) and (e: f) {}`))
+	assert.Equal(t, `@supports selector(:focus-visible){a:focus-visible{outline:none}}`, Print(t, `@supports selector(:focus-visible) { a:focus-visible { outline: none } }`))
+	assert.Equal(t, `@supports selector(a > b){}`, Print(t, `@supports selector(a > b) {}`))
+	assert.Equal(t, `@supports (--custom:value){}`, Print(t, `@supports (--custom: value) {}`))
+	assert.Equal(t, `@supports font-tech(color-COLRv1){.a{color:red}}`, Print(t, `@supports font-tech(color-COLRv1) { .a { color: red } }`))
+	assert.Equal(t, `@supports (not foo(bar)) or (foo bar){}`, Print(t, `@supports (not foo(bar)) or (foo bar) {}`))
+	assert.Equal(t, `@supports (display:grid) and (foo (bar: baz)){}`, Print(t, `@supports (display: grid) and (foo (bar: baz)) {}`))
+}
+
+func TestSupports_NestedAtRules(t *testing.T) {
+	assert.Equal(t, `@supports (display:grid){@media (min-width:30em){.a{display:grid}}.b{color:red}}`,
+		Print(t, `@supports (display: grid) {
+			@media (min-width: 30em) {
+				.a { display: grid; }
+			}
+			.b { color: red; }
+		}`))
+
+	assert.Equal(t, `@media print{@supports (display:grid){.a{display:grid}}}`,
+		Print(t, `@media print { @supports (display: grid) { .a { display: grid; } } }`))
+}
+
+func TestSupports_Errors(t *testing.T) {
+	for _, c := range []string{
+		`@supports (a: b) and (c: d) or (e: f) {}`,
+		`@supports display: grid {}`,
+		`@supports unknown(a {}`,
+		`@supports (display: grid) {`,
+	} {
+		_, err := parser.Parse(&sources.Source{
+			Path:    "main.css",
+			Content: c,
+		})
+		assert.Error(t, err, c)
+	}
+}
+
+func TestNesting(t *testing.T) {
+	assert.Equal(t, `.card{color:red;& .title{font-weight:bold}&:hover{color:blue}}`,
+		Print(t, `.card {
+			color: red;
+			& .title { font-weight: bold; }
+			&:hover { color: blue; }
+		}`))
+
+	assert.Equal(t, `.card{.title{color:red}> .icon{color:blue}+ .card{margin:0}}`,
+		Print(t, `.card { .title { color: red } > .icon { color: blue } + .card { margin: 0 } }`))
+
+	assert.Equal(t, `.card{color:red;div:hover{color:blue}margin:0}`,
+		Print(t, `.card { color: red; div:hover { color: blue } margin: 0 }`))
+
+	assert.Equal(t, `.card{@media (min-width:30em){padding:1rem;& .title{color:red}}}`,
+		Print(t, `.card { @media (min-width: 30em) { padding: 1rem; & .title { color: red } } }`))
+
+	assert.Equal(t, `.card{@supports (display:grid){display:grid}}`,
+		Print(t, `.card { @supports (display: grid) { display: grid } }`))
+
+	assert.Equal(t, `.a{.b &{color:red}:not(&){color:blue}}`,
+		Print(t, `.a { .b & { color: red } :not(&) { color: blue } }`))
+}
+
+func TestLayer(t *testing.T) {
+	assert.Equal(t, `@layer base,components;`, Print(t, `@layer base, components;`))
+	assert.Equal(t, `@layer framework.base;`, Print(t, `@layer framework.base;`))
+	assert.Equal(t, `@layer base{.a{color:red}}`, Print(t, `@layer base { .a { color: red; } }`))
+	assert.Equal(t, `@layer{.a{color:red}}`, Print(t, `@layer { .a { color: red; } }`))
+	assert.Equal(t, `@layer a{@layer b{.a{color:red}}@media print{.b{color:blue}}}`,
+		Print(t, `@layer a { @layer b { .a { color: red } } @media print { .b { color: blue } } }`))
+	assert.Equal(t, `.card{@layer base{color:red}}`, Print(t, `.card { @layer base { color: red } }`))
+
+	assert.Equal(t, `@import "a.css" layer;`, Print(t, `@import "a.css" layer;`))
+	assert.Equal(t, `@import "a.css" layer(framework.base) print;`, Print(t, `@import "a.css" layer(framework.base) print;`))
+}
+
+func TestLayer_Errors(t *testing.T) {
+	for _, c := range []string{
+		`@layer;`,
+		`@layer a, b { .a { color: red } }`,
+		`@layer a. b;`,
+		`@layer a .b;`,
+		`@layer a b;`,
+		`@import "a.css" layer();`,
+	} {
+		_, err := parser.Parse(&sources.Source{
+			Path:    "main.css",
+			Content: c,
+		})
+		assert.Error(t, err, c)
+	}
+}
+
+func TestContainer(t *testing.T) {
+	assert.Equal(t, `@container card (inline-size>400px){.title{font-size:2rem}}`,
+		Print(t, `@container card (inline-size > 400px) { .title { font-size: 2rem; } }`))
+	assert.Equal(t, `@container (min-width:400px){}`, Print(t, `@container (min-width: 400px) {}`))
+	assert.Equal(t, `@container not (width<400px){}`, Print(t, `@container not (width < 400px) {}`))
+	assert.Equal(t, `@container sidebar (400px<width<800px) and (orientation:landscape){}`,
+		Print(t, `@container sidebar (400px < width < 800px) and (orientation: landscape) {}`))
+	assert.Equal(t, `.card{@container (width>400px){padding:1rem}}`, Print(t, `.card { @container (width > 400px) { padding: 1rem } }`))
+	assert.Equal(t, `.card{container:card / inline-size}`, Print(t, `.card { container: card / inline-size }`))
+	assert.Equal(t, `@container card{}`, Print(t, `@container card {}`))
+	assert.Equal(t, `@container (not (width<400px)) or ((width>800px) and (height>400px)){}`,
+		Print(t, `@container (not (width < 400px)) or ((width > 800px) and (height > 400px)) {}`))
+	assert.Equal(t, `@container card (min-width:1px) and style(--x: 1){}`, Print(t, `@container card (min-width:1px) and style(--x: 1) {}`))
+	assert.Equal(t, `@container not style(--x: 1){}`, Print(t, `@container not style(--x: 1) {}`))
+	assert.Equal(t, `@container (not style(--x: 1)) or (width>400px){}`, Print(t, `@container (not style(--x: 1)) or (width > 400px) {}`))
+}
+
+func TestContainer_Errors(t *testing.T) {
+	for _, c := range []string{
+		`@container {}`,
+		`@container (width > 400px) {`,
+		`@container ((width > 400px) {}`,
+		`@container (not width) {}`,
+	} {
+		_, err := parser.Parse(&sources.Source{
+			Path:    "main.css",
+			Content: c,
+		})
+		assert.Error(t, err, c)
+	}
+}
+
+func TestImportConditions(t *testing.T) {
+	assert.Equal(t, `@import "a.css" supports(display:grid);`, Print(t, `@import "a.css" supports(display: grid);`))
+	assert.Equal(t, `@import "a.css" supports(not (display:grid)) print;`, Print(t, `@import "a.css" supports(not (display: grid)) print;`))
+	assert.Equal(t, `@import "a.css" supports((display:grid) and selector(a > b));`,
+		Print(t, `@import "a.css" supports((display: grid) and selector(a > b));`))
+	assert.Equal(t, `@import "a.css" layer(base) supports(display:grid) screen and (min-width:30em);`,
+		Print(t, `@import "a.css" layer(base) supports(display: grid) screen and (min-width: 30em);`))
+}
+
+func TestICSSImport(t *testing.T) {
+	assert.Equal(t, `:import("./colors.css"){primary:blue}`,
+		Print(t, `:import("./colors.css") { primary: blue }`))
+}
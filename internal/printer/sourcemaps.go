@@ -1,5 +1,17 @@
 package printer
 
+import "encoding/base64"
+
+// SourceMappingURLComment returns a comment that links CSS output to its source map.
+func SourceMappingURLComment(url string) string {
+	return "\n/*# sourceMappingURL=" + url + " */\n"
+}
+
+// InlineSourceMapURL returns a data URL that contains the source map.
+func InlineSourceMapURL(sourceMap string) string {
+	return "data:application/json;base64," + base64.StdEncoding.EncodeToString([]byte(sourceMap))
+}
+
 var (
 	base64Forward = []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/")
 
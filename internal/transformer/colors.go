@@ -0,0 +1,493 @@
+package transformer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/internal/color"
+)
+
+// transformColor lowers a color that uses newer syntax or color spaces to a hex color or rgba().
+// Colors that older browsers already support, or that can't be computed ahead of time, are
+// returned as-is.
+func (t *transformer) transformColor(v ast.Value) ast.Value {
+	c, legacy, ok := t.parseColor(v)
+	if !ok || legacy {
+		return v
+	}
+	return colorValue(c, v.Location())
+}
+
+// transformColorValues returns values with each color lowered, including colors in the
+// arguments of other functions.
+func (t *transformer) transformColorValues(values []ast.Value) []ast.Value {
+	rv := make([]ast.Value, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case *ast.HexColor:
+			rv[i] = t.transformColor(v)
+
+		case *ast.Function:
+			if isColorFunction(v.Name) {
+				rv[i] = t.transformColor(v)
+				break
+			}
+			t.transformColorArguments(v)
+			rv[i] = v
+
+		default:
+			rv[i] = v
+		}
+	}
+	return rv
+}
+
+// transformColorArguments lowers colors in the arguments of f, e.g. linear-gradient(oklch(...), ...).
+func (t *transformer) transformColorArguments(f *ast.Function) {
+	for i, arg := range f.Arguments {
+		switch a := arg.(type) {
+		case *ast.HexColor:
+			f.Arguments[i] = t.transformColor(a)
+
+		case *ast.Function:
+			if isColorFunction(a.Name) {
+				f.Arguments[i] = t.transformColor(a)
+				break
+			}
+			t.transformColorArguments(a)
+		}
+	}
+}
+
+func isColorFunction(name string) bool {
+	switch strings.ToLower(name) {
+	case "rgb", "rgba", "hsl", "hsla", "hwb", "lab", "lch", "oklab", "oklch", "color", "color-mix":
+		return true
+	default:
+		return false
+	}
+}
+
+// colorValue returns c as a hex color if it is opaque, otherwise as rgba().
+func colorValue(c color.Color, span ast.Span) ast.Value {
+	channels, alpha := srgbChannels(c)
+	if alpha == 1 {
+		return &ast.HexColor{Span: span, RGBA: fmt.Sprintf("%02x%02x%02x", channels[0], channels[1], channels[2])}
+	}
+
+	f := &ast.Function{Span: span, Name: "rgba"}
+	for _, channel := range channels {
+		f.Arguments = append(f.Arguments, &ast.Dimension{Span: span, Value: strconv.Itoa(channel)}, &ast.Comma{Span: span})
+	}
+	f.Arguments = append(f.Arguments, &ast.Dimension{Span: span, Value: strconv.FormatFloat(alpha, 'f', -1, 64)})
+	return f
+}
+
+// srgbChannels returns the 8-bit sRGB channels of c, gamut mapping it if needed, and its
+// alpha rounded to 3 decimal places.
+func srgbChannels(c color.Color) (channels [3]int, alpha float64) {
+	rgb := c.ToGamut(color.SRGB)
+	for i, v := range rgb.Components {
+		if rgb.Missing[i] {
+			v = 0
+		}
+		// Round away float error first so that e.g. 127.49999999 becomes 128.
+		channels[i] = int(math.Round(math.Round(v*255*1e6) / 1e6))
+	}
+
+	alpha = c.Alpha
+	if c.Missing[3] {
+		alpha = 0
+	}
+	alpha = math.Round(math.Max(0, math.Min(1, alpha))*1000) / 1000
+	return channels, alpha
+}
+
+// parseColor returns the color for v. legacy is set if v is a color that older browsers
+// support, e.g. #fff or rgba(0, 0, 0, 0.5). ok is false if v is not a color or can't be
+// computed ahead of time, e.g. because it uses var().
+func (t *transformer) parseColor(v ast.Value) (c color.Color, legacy, ok bool) {
+	switch value := v.(type) {
+	case *ast.HexColor:
+		return parseHexColor(value)
+
+	case *ast.Identifier:
+		c, ok := color.Named(strings.ToLower(value.Value))
+		return c, true, ok
+
+	case *ast.Function:
+		name := strings.ToLower(value.Name)
+		if name == "color-mix" {
+			c, ok := t.parseColorMix(value)
+			return c, false, ok
+		}
+
+		if name == "color" {
+			return parseColorFunction(value)
+		}
+
+		components, alpha, isLegacy, ok := colorArguments(value.Arguments)
+		if !ok || len(components) != 3 {
+			return color.Color{}, false, false
+		}
+
+		c, ok = parseComponents(name, components)
+		if !ok {
+			return color.Color{}, false, false
+		}
+
+		c.Alpha = 1
+		if alpha != nil {
+			if c.Alpha, c.Missing[3], ok = parseAlpha(alpha); !ok {
+				return color.Color{}, false, false
+			}
+		}
+
+		return c, isLegacy && isLegacyColor(name, components, alpha), true
+	}
+
+	return color.Color{}, false, false
+}
+
+// isLegacyColor returns whether or not a color function with comma-separated arguments is
+// supported by older browsers, i.e. it uses the syntax from CSS Color 3.
+// See: https://www.w3.org/TR/css-color-3/#rgb-color.
+func isLegacyColor(name string, components []ast.Value, alpha ast.Value) bool {
+	if (name == "rgb" || name == "hsl") != (alpha == nil) {
+		return false
+	}
+
+	if alpha != nil {
+		if d, ok := alpha.(*ast.Dimension); !ok || d.Unit != "" {
+			return false
+		}
+	}
+
+	units := make([]string, 0, len(components))
+	for _, c := range components {
+		d, ok := c.(*ast.Dimension)
+		if !ok {
+			return false
+		}
+		units = append(units, d.Unit)
+	}
+
+	switch name {
+	case "rgb", "rgba":
+		return units[0] == units[1] && units[1] == units[2] && (units[0] == "" || units[0] == "%")
+	case "hsl", "hsla":
+		return units[0] == "" && units[1] == "%" && units[2] == "%"
+	default:
+		return false
+	}
+}
+
+func parseHexColor(v *ast.HexColor) (c color.Color, legacy, ok bool) {
+	hex := v.RGBA
+	if len(hex) == 3 || len(hex) == 4 {
+		var expanded strings.Builder
+		for _, r := range hex {
+			expanded.WriteRune(r)
+			expanded.WriteRune(r)
+		}
+		hex = expanded.String()
+	}
+
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.Color{}, false, false
+	}
+
+	n, err := strconv.ParseUint(hex, 16, 64)
+	if err != nil {
+		return color.Color{}, false, false
+	}
+
+	c = color.Color{Space: color.SRGB, Alpha: 1}
+	if len(hex) == 8 {
+		c.Alpha = float64(n&0xff) / 255
+		n >>= 8
+	}
+	c.Components = [3]float64{float64(n>>16&0xff) / 255, float64(n>>8&0xff) / 255, float64(n&0xff) / 255}
+
+	return c, len(v.RGBA) == 3 || len(v.RGBA) == 6, true
+}
+
+// colorArguments splits the arguments of a color function into its components and alpha.
+// Both the modern syntax, e.g. rgb(0 0 0 / 50%), and the legacy syntax, e.g. rgba(0, 0, 0, 0.5),
+// are supported.
+func colorArguments(args []ast.Value) (components []ast.Value, alpha ast.Value, legacy, ok bool) {
+	for _, arg := range args {
+		if _, ok := arg.(*ast.Comma); ok {
+			legacy = true
+			break
+		}
+	}
+
+	if legacy {
+		for i, arg := range args {
+			_, isComma := arg.(*ast.Comma)
+			if isComma != (i%2 == 1) {
+				return nil, nil, false, false
+			}
+			if !isComma {
+				components = append(components, arg)
+			}
+		}
+
+		if len(components) == 4 {
+			components, alpha = components[:3], components[3]
+		}
+		return components, alpha, true, true
+	}
+
+	for i, arg := range args {
+		if raw, ok := arg.(*ast.Raw); ok && raw.Value == "/" {
+			if i != len(args)-2 {
+				return nil, nil, false, false
+			}
+			return components, args[i+1], false, true
+		}
+		components = append(components, arg)
+	}
+	return components, nil, false, true
+}
+
+// parseComponents returns the color for the components of a color function.
+func parseComponents(name string, components []ast.Value) (color.Color, bool) {
+	// percentages is the value of 100% for each component. Hues use 0.
+	var space color.Space
+	var percentages [3]float64
+	switch name {
+	case "rgb", "rgba":
+		space, percentages = color.SRGB, [3]float64{255, 255, 255}
+	case "hsl", "hsla":
+		space, percentages = color.HSL, [3]float64{0, 100, 100}
+	case "hwb":
+		space, percentages = color.HWB, [3]float64{0, 100, 100}
+	case "lab":
+		space, percentages = color.Lab, [3]float64{100, 125, 125}
+	case "lch":
+		space, percentages = color.LCH, [3]float64{100, 150, 0}
+	case "oklab":
+		space, percentages = color.OKLab, [3]float64{1, 0.4, 0.4}
+	case "oklch":
+		space, percentages = color.OKLCH, [3]float64{1, 0.4, 0}
+	default:
+		return color.Color{}, false
+	}
+
+	c := color.Color{Space: space}
+	for i, component := range components {
+		var ok bool
+		if percentages[i] == 0 {
+			c.Components[i], c.Missing[i], ok = parseHue(component)
+		} else {
+			c.Components[i], c.Missing[i], ok = parseComponent(component, percentages[i])
+		}
+		if !ok {
+			return color.Color{}, false
+		}
+	}
+
+	// Components are stored from 0 to 1 where css uses 0 to 255 or 0 to 100.
+	switch space {
+	case color.SRGB:
+		// rgb() channels are clamped when they're parsed, rather than gamut mapped.
+		for i := range c.Components {
+			c.Components[i] = math.Max(0, math.Min(1, c.Components[i]/255))
+		}
+	case color.HSL, color.HWB:
+		c.Components[1] /= 100
+		c.Components[2] /= 100
+	}
+
+	return c, true
+}
+
+// parseColorFunction parses color(), e.g. color(display-p3 1 0 0).
+// See: https://www.w3.org/TR/css-color-4/#color-function.
+func parseColorFunction(f *ast.Function) (c color.Color, legacy, ok bool) {
+	components, alpha, isLegacy, ok := colorArguments(f.Arguments)
+	if !ok || isLegacy || len(components) != 4 {
+		return color.Color{}, false, false
+	}
+
+	name, ok := components[0].(*ast.Identifier)
+	if !ok {
+		return color.Color{}, false, false
+	}
+
+	space, ok := color.Spaces[strings.ToLower(name.Value)]
+	if !ok || !space.IsRGB() {
+		return color.Color{}, false, false
+	}
+
+	c = color.Color{Space: space, Alpha: 1}
+	for i, component := range components[1:] {
+		if c.Components[i], c.Missing[i], ok = parseComponent(component, 1); !ok {
+			return color.Color{}, false, false
+		}
+	}
+
+	if alpha != nil {
+		if c.Alpha, c.Missing[3], ok = parseAlpha(alpha); !ok {
+			return color.Color{}, false, false
+		}
+	}
+
+	return c, false, true
+}
+
+// parseColorMix parses color-mix(), e.g. color-mix(in oklch, red 40%, blue).
+// See: https://www.w3.org/TR/css-color-5/#color-mix.
+func (t *transformer) parseColorMix(f *ast.Function) (color.Color, bool) {
+	var groups [][]ast.Value
+	var group []ast.Value
+	for _, arg := range f.Arguments {
+		if _, ok := arg.(*ast.Comma); ok {
+			groups = append(groups, group)
+			group = nil
+			continue
+		}
+		group = append(group, arg)
+	}
+	groups = append(groups, group)
+
+	if len(groups) != 3 {
+		return color.Color{}, false
+	}
+
+	// The interpolation method is in <space> [<method> hue].
+	method := groups[0]
+	if len(method) != 2 && len(method) != 4 {
+		return color.Color{}, false
+	}
+
+	var words []string
+	for _, v := range method {
+		ident, ok := v.(*ast.Identifier)
+		if !ok {
+			return color.Color{}, false
+		}
+		words = append(words, strings.ToLower(ident.Value))
+	}
+
+	space, ok := color.Spaces[words[1]]
+	if words[0] != "in" || !ok {
+		return color.Color{}, false
+	}
+
+	var hue color.HueInterpolation
+	if len(words) == 4 {
+		if hue, ok = color.HueInterpolations[words[2]]; !ok || words[3] != "hue" {
+			return color.Color{}, false
+		}
+	}
+
+	var colors [2]color.Color
+	var percentages [2]float64
+	var hasPercentage [2]bool
+	for i, group := range groups[1:] {
+		if len(group) != 1 && len(group) != 2 {
+			return color.Color{}, false
+		}
+
+		for _, v := range group {
+			if d, ok := v.(*ast.Dimension); ok && d.Unit == "%" && !hasPercentage[i] {
+				n, err := strconv.ParseFloat(d.Value, 64)
+				if err != nil || n < 0 || n > 100 {
+					return color.Color{}, false
+				}
+				percentages[i], hasPercentage[i] = n, true
+				continue
+			}
+
+			c, _, ok := t.parseColor(v)
+			if !ok {
+				return color.Color{}, false
+			}
+			colors[i] = c
+		}
+
+		if hasPercentage[i] && len(group) == 1 {
+			return color.Color{}, false
+		}
+	}
+
+	switch {
+	case !hasPercentage[0] && !hasPercentage[1]:
+		percentages = [2]float64{50, 50}
+	case !hasPercentage[0]:
+		percentages[0] = 100 - percentages[1]
+	case !hasPercentage[1]:
+		percentages[1] = 100 - percentages[0]
+	}
+
+	total := percentages[0] + percentages[1]
+	if total == 0 {
+		t.addWarn(f, "color-mix() percentages must not add up to 0")
+		return color.Color{}, false
+	}
+
+	c := color.Mix(colors[0], colors[1], percentages[1]/total, space, hue)
+	if total < 100 {
+		c.Alpha *= total / 100
+	}
+	return c, true
+}
+
+// parseComponent returns the value of a color component, where 100% is percentage.
+func parseComponent(v ast.Value, percentage float64) (value float64, missing, ok bool) {
+	switch value := v.(type) {
+	case *ast.Identifier:
+		return 0, true, strings.EqualFold(value.Value, "none")
+
+	case *ast.Dimension:
+		n, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil {
+			return 0, false, false
+		}
+
+		switch value.Unit {
+		case "":
+			return n, false, true
+		case "%":
+			return n / 100 * percentage, false, true
+		}
+	}
+
+	return 0, false, false
+}
+
+// parseHue returns the value of a hue component in degrees.
+func parseHue(v ast.Value) (value float64, missing, ok bool) {
+	d, ok := v.(*ast.Dimension)
+	if !ok {
+		return parseComponent(v, 0)
+	}
+
+	n, err := strconv.ParseFloat(d.Value, 64)
+	if err != nil {
+		return 0, false, false
+	}
+
+	if d.Unit == "" {
+		return n, false, true
+	}
+
+	// Hues can be angles, but not percentages.
+	conversion, ok := conversions[strings.ToLower(d.Unit)]
+	if !ok || conversion.canonical != "deg" {
+		return 0, false, false
+	}
+	return n * conversion.factor, false, true
+}
+
+// parseAlpha returns the value of an alpha component, from 0 to 1.
+func parseAlpha(v ast.Value) (value float64, missing, ok bool) {
+	value, missing, ok = parseComponent(v, 1)
+	return math.Max(0, math.Min(1, value)), missing, ok
+}
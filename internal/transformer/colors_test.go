@@ -0,0 +1,77 @@
+package transformer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+)
+
+func transformColors(o *transformer.Options) {
+	o.ColorFunctions = transforms.ColorFunctionsTransform
+}
+
+func TestColors_RGB(t *testing.T) {
+	assert.Equal(t, `.a{color:rgba(0,0,0,0.5)}`, Transform(t, transformColors, `.a { color: rgb(0 0 0 / 50%) }`))
+	assert.Equal(t, `.a{color:#ff8000}`, Transform(t, transformColors, `.a { color: rgb(100% 50% 0%) }`))
+	assert.Equal(t, `.a{color:rgba(255,0,0,0.25)}`, Transform(t, transformColors, `.a { color: rgb(255, 0, 0, 0.25) }`))
+	assert.Equal(t, `.a{color:#000000}`, Transform(t, transformColors, `.a { color: rgb(none 0 0) }`))
+	assert.Equal(t, `.a{color:#ff0000}`, Transform(t, transformColors, `.a { color: rgb(300 -5 0) }`))
+	assert.Equal(t, `.a{color:#ff0000}`, Transform(t, transformColors, `.a { color: rgb(120% 0% -10%) }`))
+
+	// Legacy syntax is supported everywhere already.
+	assert.Equal(t, `.a{color:rgb(1,2,3);background:rgba(1,2,3,0.5)}`, Transform(t, transformColors, `.a { color: rgb(1, 2, 3); background: rgba(1, 2, 3, 0.5) }`))
+}
+
+func TestColors_Hex(t *testing.T) {
+	assert.Equal(t, `.a{color:#fff;background:#aabbcc}`, Transform(t, transformColors, `.a { color: #fff; background: #aabbcc }`))
+	assert.Equal(t, `.a{color:rgba(170,187,204,0.533)}`, Transform(t, transformColors, `.a { color: #aabbcc88 }`))
+	assert.Equal(t, `.a{color:#aabbcc}`, Transform(t, transformColors, `.a { color: #abcf }`))
+	assert.Equal(t, `.a{color:#abcf}`, Transform(t, nil, `.a { color: #abcf }`))
+}
+
+func TestColors_HSL(t *testing.T) {
+	assert.Equal(t, `.a{color:#008000}`, Transform(t, transformColors, `.a { color: hsl(120deg 100% 25%) }`))
+	assert.Equal(t, `.a{color:rgba(0,128,0,0.5)}`, Transform(t, transformColors, `.a { color: hsl(120 100 25 / 0.5) }`))
+	assert.Equal(t, `.a{color:#0000ff}`, Transform(t, transformColors, `.a { color: hsl(0.6667turn 100% 50%) }`))
+	assert.Equal(t, `.a{color:hsl(120,100%,25%)}`, Transform(t, transformColors, `.a { color: hsl(120, 100%, 25%) }`))
+	assert.Equal(t, `.a{color:#ff0000;background:#999999}`, Transform(t, transformColors, `.a { color: hwb(0 0% 0%); background: hwb(0 60% 40%) }`))
+}
+
+func TestColors_Lab(t *testing.T) {
+	assert.Equal(t, `.a{color:#ff0000}`, Transform(t, transformColors, `.a { color: lab(54.29 80.8 69.89) }`))
+	assert.Equal(t, `.a{color:#ff0000}`, Transform(t, transformColors, `.a { color: lch(54.29% 106.84 40.85deg) }`))
+	assert.Equal(t, `.a{color:#ff0000}`, Transform(t, transformColors, `.a { color: oklab(0.628 0.2249 0.1258) }`))
+	assert.Equal(t, `.a{color:rgba(255,0,0,0.5)}`, Transform(t, transformColors, `.a { color: oklch(62.8% 0.2577 29.23 / 0.5) }`))
+	assert.Equal(t, `.a{color:#ff0000}`, Transform(t, transformColors, `.a { color: color(srgb 1 0 0) }`))
+
+	// Colors outside of sRGB are gamut mapped rather than clipped.
+	assert.Equal(t, `.a{color:#00fb29}`, Transform(t, transformColors, `.a { color: color(display-p3 0 1 0) }`))
+}
+
+func TestColors_Mix(t *testing.T) {
+	assert.Equal(t, `.a{color:#800080}`, Transform(t, transformColors, `.a { color: color-mix(in srgb, red, blue) }`))
+	assert.Equal(t, `.a{color:#bf0040}`, Transform(t, transformColors, `.a { color: color-mix(in srgb, red 75%, blue) }`))
+	assert.Equal(t, `.a{color:rgba(128,0,128,0.5)}`, Transform(t, transformColors, `.a { color: color-mix(in srgb, red 25%, blue 25%) }`))
+	assert.Equal(t, `.a{color:rgba(255,0,0,0.5)}`, Transform(t, transformColors, `.a { color: color-mix(in srgb, red, transparent) }`))
+	assert.Equal(t, `.a{color:#00ff00}`, Transform(t, transformColors, `.a { color: color-mix(in hsl longer hue, red, blue) }`))
+	assert.Equal(t, `.a{color:color-mix(in srgb,var(--x),blue)}`, Transform(t, transformColors, `.a { color: color-mix(in srgb, var(--x), blue) }`))
+	assert.Equal(t, `.a{color:color-mix(in srgb,currentcolor,blue)}`, Transform(t, transformColors, `.a { color: color-mix(in srgb, currentcolor, blue) }`))
+}
+
+func TestColors_Arguments(t *testing.T) {
+	assert.Equal(t, `.a{background:linear-gradient(#ff0000,rgba(0,0,255,0.502))}`, Transform(t, transformColors, `.a { background: linear-gradient(oklch(62.8% 0.2577 29.23), #0000ff80) }`))
+	assert.Equal(t, `.a{color:rgb(var(--x) / 50%)}`, Transform(t, transformColors, `.a { color: rgb(var(--x) / 50%) }`))
+}
+
+func TestColors_Variables(t *testing.T) {
+	transformColorsAndVariables := func(o *transformer.Options) {
+		o.ColorFunctions = transforms.ColorFunctionsTransform
+		o.CustomProperties = transforms.CustomPropertiesTransformRoot
+	}
+
+	assert.Equal(t, `.a{color:#ff0000}`, Transform(t, transformColorsAndVariables, `:root { --c: oklch(62.8% 0.2577 29.23) } .a { color: var(--c) }`))
+	assert.Equal(t, `.a{border:1px solid rgba(170,187,204,0.533)}`, Transform(t, transformColorsAndVariables, `:root { --c: 1px solid #aabbcc88 } .a { border: var(--c) }`))
+	assert.Equal(t, `.a{background:linear-gradient(#ff0000,blue)}`, Transform(t, transformColorsAndVariables, `.a { background: var(--c, linear-gradient(oklch(62.8% 0.2577 29.23), blue)) }`))
+}
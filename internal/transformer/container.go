@@ -0,0 +1,134 @@
+package transformer
+
+import (
+	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/transforms"
+)
+
+// transformContainerRule validates the container name of a @container rule and lowers
+// feature ranges in its condition, if ranges are lowered. Container conditions don't
+// support custom media queries, so only ranges are transformed.
+// See: https://www.w3.org/TR/css-contain-3/#container-rule.
+func (t *transformer) transformContainerRule(node *ast.AtRule) {
+	for _, prelude := range node.Preludes {
+		switch p := prelude.(type) {
+		case *ast.Identifier:
+			if isReservedContainerName(p.Value) {
+				t.addWarn(p, "%s is not a valid container name", p.Value)
+			}
+
+		case *ast.MediaQuery:
+			if t.MediaFeatureRanges == transforms.MediaFeatureRangesPassthrough {
+				continue
+			}
+
+			// Unlike media queries, a container condition can start with not, so
+			// lowered ranges are grouped at the top level too.
+			p.Parts = t.transformMediaCondition(p.Parts, false, true)
+		}
+	}
+}
+
+// validateContainerDeclaration warns about container-name, container-type and container
+// declarations that browsers would drop.
+// See: https://www.w3.org/TR/css-contain-3/#container-queries.
+func (t *transformer) validateContainerDeclaration(d *ast.Declaration) {
+	for _, v := range d.Values {
+		// Values with substitutions can only be checked at runtime.
+		if fn, ok := v.(*ast.Function); ok && fn.Name == "var" {
+			return
+		}
+	}
+
+	switch d.Property {
+	case "container-name":
+		t.validateContainerName(d, d.Values)
+
+	case "container-type":
+		t.validateContainerType(d, d.Values)
+
+	case "container":
+		for i, v := range d.Values {
+			if raw, ok := v.(*ast.Raw); ok && raw.Value == "/" {
+				t.validateContainerName(d, d.Values[:i])
+				t.validateContainerType(d, d.Values[i+1:])
+				return
+			}
+		}
+		t.validateContainerName(d, d.Values)
+	}
+}
+
+func (t *transformer) validateContainerName(d *ast.Declaration, values []ast.Value) {
+	if len(values) == 0 {
+		t.addWarn(d, "expected container name")
+		return
+	}
+
+	if len(values) == 1 {
+		if ident, ok := values[0].(*ast.Identifier); ok && (ident.Value == "none" || isCSSWideKeyword(ident.Value)) {
+			return
+		}
+	}
+
+	for _, v := range values {
+		ident, ok := v.(*ast.Identifier)
+		if !ok {
+			t.addWarn(v, "expected identifier for container name")
+			continue
+		}
+
+		if isReservedContainerName(ident.Value) {
+			t.addWarn(ident, "%s is not a valid container name", ident.Value)
+		}
+	}
+}
+
+func (t *transformer) validateContainerType(d *ast.Declaration, values []ast.Value) {
+	if len(values) == 0 {
+		t.addWarn(d, "expected container type")
+		return
+	}
+
+	for _, v := range values {
+		ident, ok := v.(*ast.Identifier)
+		if !ok {
+			t.addWarn(v, "expected identifier for container type")
+			continue
+		}
+
+		switch ident.Value {
+		case "size", "inline-size", "scroll-state":
+		case "normal":
+			if len(values) != 1 {
+				t.addWarn(ident, "normal cannot be combined with other container types")
+			}
+
+		default:
+			if len(values) != 1 || !isCSSWideKeyword(ident.Value) {
+				t.addWarn(ident, "unknown container type: %s", ident.Value)
+			}
+		}
+	}
+}
+
+// isReservedContainerName returns whether or not name is excluded from <container-name>.
+func isReservedContainerName(name string) bool {
+	switch name {
+	case "none", "and", "not", "or", "default":
+		return true
+	default:
+		return isCSSWideKeyword(name)
+	}
+}
+
+// isCSSWideKeyword returns whether or not value is a keyword that is valid for every property.
+// See: https://www.w3.org/TR/css-values-4/#common-keywords.
+func isCSSWideKeyword(value string) bool {
+	switch value {
+	case "initial", "inherit", "unset", "revert", "revert-layer":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+package transformer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainer_Ranges(t *testing.T) {
+	compileRanges := func(o *transformer.Options) {
+		o.MediaFeatureRanges = transforms.MediaFeatureRangesTransform
+	}
+
+	assert.Equal(t, `@container card (min-inline-size:400.001px){.title{font-size:2rem}}`,
+		Transform(t, compileRanges, `@container card (inline-size > 400px) { .title { font-size: 2rem } }`))
+	assert.Equal(t, `@container (min-width:400px) and (max-width:800px){.a{color:red}}`,
+		Transform(t, compileRanges, `@container (400px <= width <= 800px) { .a { color: red } }`))
+	assert.Equal(t, `@container card (inline-size>400px){.a{color:red}}`,
+		Transform(t, nil, `@container card (inline-size > 400px) { .a { color: red } }`))
+
+	assert.Equal(t, `@container (not (min-width:400.001px)){.a{color:red}}`,
+		Transform(t, compileRanges, `@container (not (width > 400px)) { .a { color: red } }`))
+	assert.Equal(t, `@container not ((min-width:400px) and (max-width:800px)){.a{color:red}}`,
+		Transform(t, compileRanges, `@container not (400px <= width <= 800px) { .a { color: red } }`))
+	assert.Equal(t, `@container card ((min-width:100.001px) and (max-height:49.999px)) or (orientation:portrait){.a{color:red}}`,
+		Transform(t, compileRanges, `@container card ((width > 100px) and (height < 50px)) or (orientation: portrait) { .a { color: red } }`))
+	assert.Equal(t, `@container ((width>100px) and (height<50px)){.a{color:red}}`,
+		Transform(t, nil, `@container ((width > 100px) and (height < 50px)) { .a { color: red } }`))
+}
+
+func TestContainer_Style(t *testing.T) {
+	assert.Equal(t, `@container card (min-width:1px) and style(--x: 1){.a{color:red}}`, Transform(t, func(o *transformer.Options) {
+		o.MediaFeatureRanges = transforms.MediaFeatureRangesTransform
+	}, `@container card (width >= 1px) and style(--x: 1) { .a { color: red } }`))
+}
+
+func TestContainer_NameOnly(t *testing.T) {
+	assert.Equal(t, `@container card{.a{color:red}}`, Transform(t, nil, `@container card { .a { color: red } }`))
+	assert.Equal(t, `@container card{.a{color:red}}`, Transform(t, func(o *transformer.Options) {
+		o.MediaFeatureRanges = transforms.MediaFeatureRangesTransform
+	}, `@container card { .a { color: red } }`))
+}
+
+func TestContainer_Nesting(t *testing.T) {
+	assert.Equal(t, `@container (min-width:400.001px){.card{padding:1rem}}`, Transform(t, func(o *transformer.Options) {
+		o.MediaFeatureRanges = transforms.MediaFeatureRangesTransform
+		o.Nesting = transforms.NestingTransform
+	}, `.card { @container (width > 400px) { padding: 1rem } }`))
+}
+
+func TestContainer_Names(t *testing.T) {
+	compileRanges := func(o *transformer.Options) {
+		o.MediaFeatureRanges = transforms.MediaFeatureRangesTransform
+	}
+
+	for _, c := range []string{
+		`.a { container-name: card }`,
+		`.a { container-name: card sidebar }`,
+		`.a { container-name: none }`,
+		`.a { container-name: inherit }`,
+		`.a { container-type: inline-size }`,
+		`.a { container-type: normal }`,
+		`.a { container-type: var(--type) }`,
+		`.a { container: card / size }`,
+		`.a { container: card }`,
+		`@container card (width > 400px) {}`,
+		`@container card {}`,
+	} {
+		assert.NotPanics(t, func() { Transform(t, compileRanges, c) }, c)
+	}
+
+	for _, c := range []string{
+		`.a { container-name: none card }`,
+		`.a { container-name: and }`,
+		`.a { container-name: card initial }`,
+		`.a { container-name: "card" }`,
+		`.a { container-type: block-size }`,
+		`.a { container-type: normal size }`,
+		`.a { container: not / size }`,
+		`.a { container: card / }`,
+		`@container none (width > 400px) {}`,
+		`@container or (width > 400px) {}`,
+	} {
+		assert.Panics(t, func() { Transform(t, compileRanges, c) }, c)
+
+		// Names and types are checked even if ranges aren't lowered.
+		assert.Panics(t, func() { Transform(t, nil, c) }, c)
+	}
+}
@@ -44,3 +44,94 @@ func TestCustomProperties_Fallback(t *testing.T) {
 		font-family: var(--font, "Helvetica", sans-serif);
 	}`))
 }
+
+func TestCustomProperties_Math(t *testing.T) {
+	reduce := func(o *transformer.Options) {
+		compileCustomProperties(o)
+		o.CalcReduction = transforms.CalcReductionReduce
+	}
+
+	assert.Equal(t, ".a{width:20px}", Transform(t, reduce, `:root { --x: 10px; } .a { width: calc(var(--x) * 2); }`))
+	assert.Equal(t, ".a{width:3px;height:calc(100% - 3px)}", Transform(t, reduce, `:root { --x: calc(1px + 2px); } .a { width: var(--x); height: calc(100% - var(--x)); }`))
+	assert.Equal(t, ".a{width:4px}", Transform(t, reduce, `.a { width: calc(var(--y, 2px) * 2); }`))
+
+	// var() in functions is substituted even if math is not reduced.
+	assert.Equal(t, ".a{width:calc(10px*2)}", Transform(t, compileCustomProperties, `:root { --x: 10px; } .a { width: calc(var(--x) * 2); }`))
+}
+
+func transformCustomProperties(o *transformer.Options) {
+	o.CustomProperties = transforms.CustomPropertiesTransform
+}
+
+func TestCustomProperties_AnySelector(t *testing.T) {
+	assert.Equal(t, ":root{--gap:1rem}.a{--color:red}.a{color:red;margin:1rem}", Transform(t, transformCustomProperties, `:root { --gap: 1rem; }
+.a { --color: red; }
+.a { color: var(--color); margin: var(--gap); }`))
+
+	assert.Equal(t, ".a{--color:red}.a .b{color:red}.a > .b{color:red}.a.b{color:red}", Transform(t, transformCustomProperties, `.a { --color: red; }
+.a .b { color: var(--color); }
+.a > .b { color: var(--color); }
+.a.b { color: var(--color); }`))
+
+	// Elements matched by these selectors may not be inside of .a.
+	assert.Equal(t, ".a{--color:red}.b{color:var(--color)}.a + .b{color:var(--color)}.a,.b{color:var(--color)}", Transform(t, transformCustomProperties, `.a { --color: red; }
+.b { color: var(--color); }
+.a + .b { color: var(--color); }
+.a, .b { color: var(--color); }`))
+
+	assert.Equal(t, ".a{--x:red}.a.b ~ .c{color:var(--x)}.a:hover + .d{color:var(--x)}.a > .b ~ .c{color:red}.a .b + .c{color:red}", Transform(t, transformCustomProperties, `.a { --x: red; }
+.a.b ~ .c { color: var(--x); }
+.a:hover + .d { color: var(--x); }
+.a > .b ~ .c { color: var(--x); }
+.a .b + .c { color: var(--x); }`))
+}
+
+func TestCustomProperties_Unsafe(t *testing.T) {
+	assert.Equal(t, ".a{--color:red}.a{--color:blue}.a{color:var(--color)}", Transform(t, transformCustomProperties, `.a { --color: red; }
+.a { --color: blue; }
+.a { color: var(--color); }`))
+
+	assert.Equal(t, "@media print{:root{--color:red}}.a{color:var(--color,blue)}", Transform(t, transformCustomProperties, `@media print { :root { --color: red; } }
+.a { color: var(--color, blue); }`))
+}
+
+func TestCustomProperties_Nested(t *testing.T) {
+	assert.Equal(t, ":root{--a:1px;--b:var(--a) 2px}.a{margin:1px 2px}", Transform(t, transformCustomProperties, `:root { --a: 1px; --b: var(--a) 2px; }
+.a { margin: var(--b); }`))
+
+	assert.Equal(t, ":root{--a:1px}.a{margin:1px;padding:3px}", Transform(t, transformCustomProperties, `:root { --a: 1px; }
+.a { margin: var(--undefined, var(--a)); padding: var(--undefined, var(--other, 3px)); }`))
+
+	// The fallback is still substituted when the var() can't be.
+	assert.Equal(t, ":root{--a:1px}.b{--c:red}.a{margin:var(--c,1px)}", Transform(t, transformCustomProperties, `:root { --a: 1px; }
+.b { --c: red; }
+.a { margin: var(--c, var(--a)); }`))
+}
+
+type collectingReporter struct {
+	errs []error
+}
+
+func (r *collectingReporter) AddError(err error) {
+	r.errs = append(r.errs, err)
+}
+
+func TestCustomProperties_Cycles(t *testing.T) {
+	reporter := &collectingReporter{}
+	assert.Equal(t, ":root{--a:var(--b);--b:var(--a);--c:var(--a,1px)}.a{margin:var(--a);padding:1px}", Transform(t, func(o *transformer.Options) {
+		transformCustomProperties(o)
+		o.Reporter = reporter
+	}, `:root { --a: var(--b); --b: var(--a); --c: var(--a, 1px); }
+.a { margin: var(--a); padding: var(--c); }`))
+
+	if assert.Len(t, reporter.errs, 1) {
+		assert.Contains(t, reporter.errs[0].Error(), "custom property cycle: --a -> --b -> --a")
+	}
+}
+
+func TestCustomProperties_Preserve(t *testing.T) {
+	assert.Equal(t, ":root{--color:red}.a{color:red;color:var(--color);margin:0}", Transform(t, func(o *transformer.Options) {
+		o.CustomProperties = transforms.CustomPropertiesTransformPreserve
+	}, `:root { --color: red; }
+.a { color: var(--color); margin: 0; }`))
+}
@@ -0,0 +1,125 @@
+package transformer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/internal/parser"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/internal/sources"
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TransformImport transforms main with every @import replaced by other.
+func TransformImport(t testing.TB, opts transforms.Options, main, other string) string {
+	mainSource := &sources.Source{
+		Path:    "main.css",
+		Content: main,
+	}
+	mainSS, err := parser.Parse(mainSource)
+	require.NoError(t, err)
+
+	otherSS, err := parser.Parse(&sources.Source{
+		Path:    "other.css",
+		Content: other,
+	})
+	require.NoError(t, err)
+
+	replacements := make(map[*ast.AtRule]*ast.Stylesheet)
+	for _, imp := range mainSS.Imports {
+		replacements[imp.AtRule] = otherSS
+	}
+
+	opts.ImportRules = transforms.ImportRulesInline
+	out, err := printer.Print(transformer.Transform(mainSS, transformer.Options{
+		OriginalSource:     mainSource,
+		Reporter:           &reporter{},
+		ImportReplacements: replacements,
+		Options:            opts,
+	}), printer.Options{})
+	require.NoError(t, err)
+	return out
+}
+
+func TestImports_Conditions(t *testing.T) {
+	other := `.a { color: red }`
+
+	assert.Equal(t, `.a{color:red}`, TransformImport(t, transforms.Options{}, `@import "other.css";`, other))
+	assert.Equal(t, `@media print{.a{color:red}}`, TransformImport(t, transforms.Options{}, `@import "other.css" print;`, other))
+	assert.Equal(t, `@media screen,print and (min-width:30em){.a{color:red}}`,
+		TransformImport(t, transforms.Options{}, `@import "other.css" screen, print and (min-width: 30em);`, other))
+	assert.Equal(t, `@supports (display:grid){.a{color:red}}`,
+		TransformImport(t, transforms.Options{}, `@import "other.css" supports(display: grid);`, other))
+	assert.Equal(t, `@supports not (display:grid){.a{color:red}}`,
+		TransformImport(t, transforms.Options{}, `@import "other.css" supports(not (display: grid));`, other))
+	assert.Equal(t, `@media print{@supports (display:grid){@layer base{.a{color:red}}}}`,
+		TransformImport(t, transforms.Options{}, `@import "other.css" layer(base) supports(display: grid) print;`, other))
+}
+
+func TestImports_NestedConditions(t *testing.T) {
+	assert.Equal(t, `@media print{.a{color:red}@media (min-width:30em){.b{color:blue}}}`,
+		TransformImport(t, transforms.Options{}, `@import "other.css" print;`, `.a { color: red } @media (min-width: 30em) { .b { color: blue } }`))
+
+	assert.Equal(t, `@media (min-width:30em){.a{color:red}}`, TransformImport(t, transforms.Options{
+		MediaFeatureRanges: transforms.MediaFeatureRangesTransform,
+	}, `@import "other.css" (width >= 30em);`, `.a { color: red }`))
+}
+
+func TestImports_ConditionalVariables(t *testing.T) {
+	// Variables from conditionally imported files only apply conditionally, so they are not substituted.
+	assert.Equal(t, `@media print{:root{--color:red}}`, TransformImport(t, transforms.Options{
+		CustomProperties: transforms.CustomPropertiesTransformRoot,
+	}, `@import "other.css" print;`, `:root { --color: red }`))
+}
+
+func TestImports_Hoist(t *testing.T) {
+	// Imports that are not inlined must come before other rules, so they're moved up.
+	assert.Equal(t, `@layer a,b;@import "ext.css";.a{color:red}`, TransformImport(t, transforms.Options{},
+		`@layer a, b; @import "other.css";`, `.a { color: red } @import "ext.css";`))
+
+	assert.Panics(t, func() {
+		TransformImport(t, transforms.Options{}, `@import "other.css" print;`, `@import "ext.css"; .a { color: red }`)
+	})
+}
+
+func TestImports_Diamonds(t *testing.T) {
+	// Each level imports a and b, which both import the next level. Every stylesheet is
+	// shared by both paths to it, so copying each path separately would take exponential time.
+	const levels = 30
+	parse := func(path, content string) *ast.Stylesheet {
+		ss, err := parser.Parse(&sources.Source{Path: path, Content: content})
+		require.NoError(t, err)
+		return ss
+	}
+
+	replacements := make(map[*ast.AtRule]*ast.Stylesheet)
+	next := parse("end.css", `.end { color: red }`)
+	expected := `.end{color:red}`
+	for i := levels - 1; i >= 0; i-- {
+		a := parse("a.css", fmt.Sprintf(`@import "next.css"; .a%d { color: red }`, i))
+		b := parse("b.css", fmt.Sprintf(`@import "next.css"; .b%d { color: red }`, i))
+		replacements[a.Imports[0].AtRule] = next
+		replacements[b.Imports[0].AtRule] = next
+
+		level := parse("level.css", fmt.Sprintf(`@import "a.css"; @import "b.css"; .l%d { color: red }`, i))
+		replacements[level.Imports[0].AtRule] = a
+		replacements[level.Imports[1].AtRule] = b
+		next = level
+
+		// The next level is only inlined by its last import, in b.
+		expected = fmt.Sprintf(`.a%d{color:red}%s.b%d{color:red}.l%d{color:red}`, i, expected, i, i)
+	}
+
+	out, err := printer.Print(transformer.Transform(next, transformer.Options{
+		OriginalSource:     &sources.Source{Path: "level.css"},
+		Reporter:           &reporter{},
+		ImportReplacements: replacements,
+		Options:            transforms.Options{ImportRules: transforms.ImportRulesInline, CustomProperties: transforms.CustomPropertiesTransform},
+	}), printer.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, expected, out)
+}
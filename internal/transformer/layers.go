@@ -0,0 +1,202 @@
+package transformer
+
+import (
+	"github.com/stephen/cssc/internal/ast"
+)
+
+// layer is a node in the tree of cascade layers declared by a stylesheet.
+type layer struct {
+	// children are the sublayers of this layer, in the order that they were declared.
+	children []*layer
+
+	// byName is the set of named sublayers. Anonymous layers cannot be referenced,
+	// so they are only in children.
+	byName map[string]*layer
+
+	// rules are the rules that belong directly to this layer.
+	rules []layeredRule
+
+	// unlayered is set on the implicit root layer, which holds rules not in any @layer.
+	unlayered bool
+}
+
+// layeredRule is a rule and the conditional group rules (e.g. @media) that
+// it was declared in.
+type layeredRule struct {
+	node       ast.Node
+	conditions []*ast.AtRule
+}
+
+// sublayer returns the sublayer with the given name, declaring it if it does not exist.
+func (l *layer) sublayer(name string) *layer {
+	if child, ok := l.byName[name]; ok {
+		return child
+	}
+
+	child := &layer{}
+	if l.byName == nil {
+		l.byName = make(map[string]*layer)
+	}
+	l.byName[name] = child
+	l.children = append(l.children, child)
+	return child
+}
+
+// resolve returns the layer for the dotted layer name, declaring any layers along the way.
+func (l *layer) resolve(name string) *layer {
+	current := l
+	start := 0
+	for i := 0; i <= len(name); i++ {
+		if i == len(name) || name[i] == '.' {
+			current = current.sublayer(name[start:i])
+			start = i + 1
+		}
+	}
+	return current
+}
+
+// flattenLayer returns all rules in this layer in cascade order: sublayers in the
+// order they were declared, then the rules in this layer itself.
+func (t *transformer) flattenLayer(l *layer) []ast.Node {
+	var rv []ast.Node
+	for _, child := range l.children {
+		rv = append(rv, t.flattenLayer(child)...)
+	}
+	return append(rv, t.wrapConditions(l.rules, 0)...)
+}
+
+// wrapConditions re-creates the conditional group rules around each rule. Consecutive rules
+// that were declared in the same conditional group rule are placed in a single copy of it.
+func (t *transformer) wrapConditions(rules []layeredRule, depth int) []ast.Node {
+	var rv []ast.Node
+	for i := 0; i < len(rules); {
+		if len(rules[i].conditions) <= depth {
+			rv = append(rv, rules[i].node)
+			i++
+			continue
+		}
+
+		condition := rules[i].conditions[depth]
+		j := i + 1
+		for j < len(rules) && len(rules[j].conditions) > depth && rules[j].conditions[depth] == condition {
+			j++
+		}
+
+		wrapped := &ast.AtRule{
+			Span:     condition.Span,
+			Name:     condition.Name,
+			Preludes: condition.Preludes,
+			Block: &ast.QualifiedRuleBlock{
+				Span:  condition.Block.Location(),
+				Rules: t.wrapConditions(rules[i:j], depth+1),
+			},
+		}
+		if source, ok := t.NodeSources[condition]; ok {
+			t.NodeSources[wrapped] = source
+		}
+		rv = append(rv, wrapped)
+		i = j
+	}
+	return rv
+}
+
+// flattenLayers removes all @layer rules from nodes and reorders layered rules so that
+// they are in cascade order. Unlayered rules come last, since they take precedence over
+// all layered rules.
+// See: https://www.w3.org/TR/css-cascade-5/#layer-ordering.
+func (t *transformer) flattenLayers(nodes []ast.Node) []ast.Node {
+	var head []ast.Node
+	for len(nodes) > 0 {
+		// @charset and @import must stay at the top of the stylesheet.
+		at, ok := nodes[0].(*ast.AtRule)
+		if !ok || (at.Name != "charset" && at.Name != "import") {
+			break
+		}
+		head = append(head, at)
+		nodes = nodes[1:]
+	}
+
+	root := &layer{unlayered: true}
+	t.collectLayers(nodes, root, nil)
+	return append(head, t.flattenLayer(root)...)
+}
+
+// collectLayers adds nodes to the layer tree under current. conditions is the
+// list of conditional group rules that the nodes are inside of.
+func (t *transformer) collectLayers(nodes []ast.Node, current *layer, conditions []*ast.AtRule) {
+	for _, n := range nodes {
+		at, ok := n.(*ast.AtRule)
+		if !ok {
+			t.addLayeredRule(current, n, conditions)
+			continue
+		}
+
+		switch at.Name {
+		case "layer":
+			var names []*ast.Identifier
+			if len(at.Preludes) > 0 {
+				if list, ok := at.Preludes[0].(*ast.LayerList); ok {
+					names = list.Names
+				}
+			}
+
+			if at.Block == nil {
+				for _, name := range names {
+					current.resolve(name.Value)
+				}
+				continue
+			}
+
+			block, ok := at.Block.(*ast.QualifiedRuleBlock)
+			if !ok {
+				t.addWarn(at, "@layer block must contain rules")
+				continue
+			}
+
+			t.inheritSource(at, block.Rules)
+			target := &layer{}
+			if len(names) > 0 {
+				target = current.resolve(names[0].Value)
+			} else {
+				current.children = append(current.children, target)
+			}
+
+			t.collectLayers(block.Rules, target, conditions)
+
+		case "media", "supports", "container":
+			block, ok := at.Block.(*ast.QualifiedRuleBlock)
+			if !ok {
+				t.addLayeredRule(current, n, conditions)
+				continue
+			}
+
+			t.inheritSource(at, block.Rules)
+
+			// Copy conditions so that sibling rules don't share a backing array.
+			nested := make([]*ast.AtRule, len(conditions), len(conditions)+1)
+			copy(nested, conditions)
+			t.collectLayers(block.Rules, current, append(nested, at))
+
+		default:
+			t.addLayeredRule(current, n, conditions)
+		}
+	}
+}
+
+// addLayeredRule adds a rule to l. It warns about !important declarations in layered rules,
+// since layer order is reversed for them and flattening cannot preserve that.
+func (t *transformer) addLayeredRule(l *layer, n ast.Node, conditions []*ast.AtRule) {
+	l.rules = append(l.rules, layeredRule{node: n, conditions: conditions})
+	if l.unlayered {
+		return
+	}
+
+	ast.Walk(n, func(n ast.Node) {
+		decl, ok := n.(*ast.Declaration)
+		if !ok || !decl.Important {
+			return
+		}
+
+		t.addWarn(decl, "!important declarations in @layer cannot be flattened without changing their precedence")
+	})
+}
@@ -0,0 +1,97 @@
+package transformer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+)
+
+func compileLayers(o *transformer.Options) {
+	o.CascadeLayers = transforms.CascadeLayersTransform
+}
+
+func TestLayers(t *testing.T) {
+	assert.Equal(t, `.b{color:blue}.a{color:red}.c{color:green}`, Transform(t, compileLayers, `
+@layer b, a;
+.c { color: green }
+@layer a { .a { color: red } }
+@layer b { .b { color: blue } }
+`))
+
+	assert.Equal(t, `.a{color:red}.a{color:blue}`, Transform(t, compileLayers, `
+@layer a { .a { color: red } }
+@layer a { .a { color: blue } }
+`))
+
+	assert.Equal(t, `.anon{color:red}.a{color:blue}`, Transform(t, compileLayers, `
+@layer { .anon { color: red } }
+@layer a { .a { color: blue } }
+`))
+}
+
+func TestLayers_Sublayers(t *testing.T) {
+	assert.Equal(t, `.theme{color:red}.reset{margin:0}.base{color:blue}`, Transform(t, compileLayers, `
+@layer base {
+	.base { color: blue }
+	@layer theme { .theme { color: red } }
+}
+@layer base.reset { .reset { margin: 0 } }
+@layer base.reset, base.theme;
+`))
+
+	assert.Equal(t, `.b{color:red}.a{color:blue}`, Transform(t, compileLayers, `
+@layer x.b, x.a;
+@layer x { @layer a { .a { color: blue } } }
+@layer x.b { .b { color: red } }
+`))
+}
+
+func TestLayers_Conditions(t *testing.T) {
+	assert.Equal(t, `@media print{.b{color:blue}.c{color:green}}@media print{.a{color:red}}`, Transform(t, compileLayers, `
+@layer b, a;
+@media print {
+	@layer a { .a { color: red } }
+	@layer b { .b { color: blue } .c { color: green } }
+}
+@layer a { }
+`))
+
+	assert.Equal(t, `@media print{@supports (display:grid){.b{display:grid}}}@media print{.a{color:red}}`, Transform(t, compileLayers, `
+@layer b, a;
+@media print {
+	@layer a { .a { color: red } }
+	@supports (display: grid) { @layer b { .b { display: grid } } }
+}
+`))
+}
+
+func TestLayers_KeepsImports(t *testing.T) {
+	assert.Equal(t, `@import "a.css";.b{color:blue}.a{color:red}`, Transform(t, compileLayers, `
+@import "a.css";
+.a { color: red }
+@layer b { .b { color: blue } }
+`))
+}
+
+func TestLayers_Nesting(t *testing.T) {
+	assert.Equal(t, `.a{color:blue}.a{color:red}`, Transform(t, func(o *transformer.Options) {
+		o.CascadeLayers = transforms.CascadeLayersTransform
+		o.Nesting = transforms.NestingTransform
+	}, `
+.a {
+	color: red;
+	@layer base { color: blue }
+}
+`))
+}
+
+func TestLayers_Important(t *testing.T) {
+	assert.Panics(t, func() { Transform(t, compileLayers, `@layer a { .a { color: red !important } }`) })
+	assert.Equal(t, `.a{color:red!important}`, Transform(t, compileLayers, `.a { color: red !important }`))
+}
+
+func TestLayers_Passthrough(t *testing.T) {
+	assert.Equal(t, `@layer b,a;@layer a{.a{color:red}}`, Transform(t, nil, `@layer b, a; @layer a { .a { color: red } }`))
+}
@@ -0,0 +1,469 @@
+package transformer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/stephen/cssc/internal/ast"
+)
+
+// unitConversion is how to convert a unit into the canonical unit of its type.
+type unitConversion struct {
+	// canonical is the canonical unit, e.g. px for lengths.
+	canonical string
+
+	// factor is the number of canonical units in one of the unit.
+	factor float64
+}
+
+// conversions is the set of units that can be converted between each other.
+// See: https://www.w3.org/TR/css-values-4/#absolute-lengths.
+var conversions = map[string]unitConversion{
+	"px": {"px", 1},
+	"in": {"px", 96},
+	"cm": {"px", 96 / 2.54},
+	"mm": {"px", 96 / 25.4},
+	"q":  {"px", 96 / 101.6},
+	"pt": {"px", 96.0 / 72},
+	"pc": {"px", 16},
+
+	"deg":  {"deg", 1},
+	"grad": {"deg", 0.9},
+	"rad":  {"deg", 180 / math.Pi},
+	"turn": {"deg", 360},
+
+	"s":  {"s", 1},
+	"ms": {"s", 0.001},
+
+	"hz":  {"hz", 1},
+	"khz": {"hz", 1000},
+
+	"dppx": {"dppx", 1},
+	"x":    {"dppx", 1},
+	"dpi":  {"dppx", 1.0 / 96},
+	"dpcm": {"dppx", 2.54 / 96},
+}
+
+// constants is the set of numeric constants allowed in math functions.
+// See: https://www.w3.org/TR/css-values-4/#calc-constants.
+var constants = map[string]float64{
+	"e":  math.E,
+	"pi": math.Pi,
+}
+
+// term is a single term of a simplified math expression.
+type term struct {
+	// value is the numeric value of the term. If node is set, it is the coefficient of node.
+	value float64
+
+	// unit is the unit of value. It is empty for numbers.
+	unit string
+
+	// node is a value that can't be reduced any further, e.g. var(). If node is nil, the term is numeric.
+	node ast.Value
+}
+
+// sum is a math expression simplified into a sum of terms. Numeric terms are combined when they
+// have compatible units.
+// See: https://www.w3.org/TR/css-values-4/#calc-simplification.
+type sum []term
+
+// number returns the value of s if it is a single number.
+func (s sum) number() (float64, bool) {
+	if len(s) != 1 || s[0].node != nil || s[0].unit != "" {
+		return 0, false
+	}
+	return s[0].value, true
+}
+
+// isNumeric returns whether or not every term in s is numeric.
+func (s sum) isNumeric() bool {
+	for _, t := range s {
+		if t.node != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// scale returns s multiplied by n.
+func (s sum) scale(n float64) sum {
+	rv := make(sum, 0, len(s))
+	for _, t := range s {
+		t.value *= n
+		rv = append(rv, t)
+	}
+	return rv
+}
+
+// divide returns s divided by n.
+func (s sum) divide(n float64) sum {
+	rv := make(sum, 0, len(s))
+	for _, t := range s {
+		t.value /= n
+		rv = append(rv, t)
+	}
+	return rv
+}
+
+// reduceMathFunction reduces calc(), min(), max(), or clamp() as much as possible. If the
+// function can't be reduced, it is returned as-is.
+func (t *transformer) reduceMathFunction(f *ast.Function) ast.Value {
+	if f.Name == "calc" {
+		if len(f.Arguments) != 1 {
+			t.addWarn(f, "expected single argument for calc()")
+			return f
+		}
+
+		s, ok := t.simplifyMath(f.Arguments[0])
+		if !ok {
+			return f
+		}
+
+		if len(s) == 1 {
+			if s[0].node == nil {
+				return s[0].toDimension(f.Span)
+			}
+
+			// Nested math functions don't need to be wrapped in calc().
+			if fn, ok := s[0].node.(*ast.Function); ok && fn.IsMath() && s[0].value == 1 {
+				return fn
+			}
+		}
+
+		return &ast.Function{Span: f.Span, Name: f.Name, Arguments: []ast.Value{s.toNode(f.Span)}}
+	}
+
+	var args []sum
+	newFunction := &ast.Function{Span: f.Span, Name: f.Name}
+	for i, arg := range f.Arguments {
+		if i%2 == 1 {
+			if _, ok := arg.(*ast.Comma); !ok {
+				t.addWarn(arg, "expected comma between arguments to %s()", f.Name)
+				return f
+			}
+			newFunction.Arguments = append(newFunction.Arguments, arg)
+			continue
+		}
+
+		s, ok := t.simplifyMath(arg)
+		if !ok {
+			return f
+		}
+		args = append(args, s)
+		newFunction.Arguments = append(newFunction.Arguments, s.toNode(arg.Location()))
+	}
+
+	if f.Name == "clamp" && len(args) != 3 {
+		t.addWarn(f, "expected three arguments for clamp()")
+		return f
+	}
+
+	if reduced := compareMathArguments(f.Name, args); reduced != nil {
+		return reduced.toDimension(f.Span)
+	}
+	return newFunction
+}
+
+// compareMathArguments returns the result of min(), max(), or clamp() if all of args are
+// numbers that can be compared.
+func compareMathArguments(name string, args []sum) *term {
+	if len(args) == 0 {
+		return nil
+	}
+
+	var canonical string
+	values := make([]float64, 0, len(args))
+	for i, arg := range args {
+		if len(arg) != 1 || arg[0].node != nil {
+			return nil
+		}
+
+		value, unit := arg[0].canonical()
+		if i > 0 && unit != canonical {
+			return nil
+		}
+		canonical = unit
+		values = append(values, value)
+	}
+
+	pick := func(i int, better func(a, b float64) bool, candidates ...int) int {
+		for _, c := range candidates {
+			if better(values[c], values[i]) {
+				i = c
+			}
+		}
+		return i
+	}
+	less := func(a, b float64) bool { return a < b }
+	greater := func(a, b float64) bool { return a > b }
+
+	var chosen int
+	switch name {
+	case "min", "max":
+		better := less
+		if name == "max" {
+			better = greater
+		}
+		for i := range values {
+			chosen = pick(chosen, better, i)
+		}
+
+	case "clamp":
+		// clamp(min, val, max) is max(min, min(val, max)).
+		chosen = pick(0, greater, pick(1, less, 2))
+
+	default:
+		return nil
+	}
+
+	return &args[chosen][0]
+}
+
+// simplifyMath simplifies a math expression into a sum of terms. If the expression is
+// invalid, an error is reported and ok is false.
+func (t *transformer) simplifyMath(v ast.Value) (s sum, ok bool) {
+	switch value := v.(type) {
+	case *ast.Dimension:
+		n, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil {
+			t.addError(value, "could not parse dimension value: %s", value.Value)
+			return nil, false
+		}
+		return sum{{value: n, unit: value.Unit}}, true
+
+	case *ast.Identifier:
+		if n, ok := constants[strings.ToLower(value.Value)]; ok {
+			return sum{{value: n}}, true
+		}
+
+	case *ast.MathParenthesizedExpression:
+		return t.simplifyMath(value.Value)
+
+	case *ast.Function:
+		if !value.IsMath() {
+			break
+		}
+
+		// Nested calc() is the same as parentheses.
+		if value.Name == "calc" && len(value.Arguments) == 1 {
+			return t.simplifyMath(value.Arguments[0])
+		}
+
+		reduced := t.reduceMathFunction(value)
+		if d, ok := reduced.(*ast.Dimension); ok {
+			return t.simplifyMath(d)
+		}
+		return sum{{value: 1, node: reduced}}, true
+
+	case *ast.MathExpression:
+		return t.simplifyMathExpression(value)
+	}
+
+	return sum{{value: 1, node: v}}, true
+}
+
+func (t *transformer) simplifyMathExpression(expr *ast.MathExpression) (sum, bool) {
+	l, ok := t.simplifyMath(expr.Left)
+	if !ok {
+		return nil, false
+	}
+
+	r, ok := t.simplifyMath(expr.Right)
+	if !ok {
+		return nil, false
+	}
+
+	switch expr.Operator {
+	case "+", "-":
+		if expr.Operator == "-" {
+			r = r.scale(-1)
+		}
+
+		rv := append(sum{}, l...)
+		for _, term := range r {
+			var err error
+			if rv, err = rv.add(term); err != nil {
+				t.addError(expr, "%s", err)
+				return nil, false
+			}
+		}
+		return rv, true
+
+	case "*":
+		if n, ok := l.number(); ok {
+			return r.scale(n), true
+		}
+
+		if n, ok := r.number(); ok {
+			return l.scale(n), true
+		}
+
+		if l.isNumeric() && r.isNumeric() {
+			t.addError(expr, "one side of multiplication must be a number (non-percentage/dimension)")
+			return nil, false
+		}
+
+	case "/":
+		if n, ok := r.number(); ok {
+			if n == 0 {
+				t.addError(expr, "cannot divide by zero")
+				return nil, false
+			}
+			return l.divide(n), true
+		}
+
+		if r.isNumeric() {
+			t.addError(expr, "right side of division must be a number (non-percentage/dimension)")
+			return nil, false
+		}
+
+	default:
+		t.addError(expr, "unknown op: %s", expr.Operator)
+		return nil, false
+	}
+
+	// The product can't be reduced, e.g. var(--x) * var(--y), so it's kept as a single term.
+	return sum{{value: 1, node: &ast.MathExpression{
+		Span:     expr.Span,
+		Operator: expr.Operator,
+		Left:     l.toFactor(expr.Left.Location()),
+		Right:    r.toFactor(expr.Right.Location()),
+	}}}, true
+}
+
+// add returns s with t added to it. t is combined with an existing term if their units are compatible.
+func (s sum) add(t term) (sum, error) {
+	if t.node != nil {
+		return append(s, t), nil
+	}
+
+	for i, existing := range s {
+		if existing.node != nil {
+			continue
+		}
+
+		if (existing.unit == "") != (t.unit == "") {
+			unit := existing.unit
+			if unit == "" {
+				unit = t.unit
+			}
+			return nil, fmt.Errorf("cannot add number type and %s type together", unit)
+		}
+
+		if strings.EqualFold(existing.unit, t.unit) {
+			s[i].value += t.value
+			return s, nil
+		}
+
+		existingConversion, ok := conversions[strings.ToLower(existing.unit)]
+		if !ok {
+			continue
+		}
+		conversion, ok := conversions[strings.ToLower(t.unit)]
+		if !ok {
+			continue
+		}
+
+		if existingConversion.canonical != conversion.canonical {
+			return nil, fmt.Errorf("cannot add %s type and %s type together", existing.unit, t.unit)
+		}
+
+		value, unit := existing.canonical()
+		s[i] = term{value: value + t.value*conversion.factor, unit: unit}
+		return s, nil
+	}
+
+	return append(s, t), nil
+}
+
+// canonical returns the value of a numeric term in the canonical unit for its type. Units
+// that can't be converted are returned as-is.
+func (t term) canonical() (float64, string) {
+	conversion, ok := conversions[strings.ToLower(t.unit)]
+	if !ok {
+		return t.value, strings.ToLower(t.unit)
+	}
+	return t.value * conversion.factor, conversion.canonical
+}
+
+// precision is the number of decimal places that computed values are rounded to, so that
+// float errors from unit conversion aren't printed, e.g. 0.30000000000000004px.
+const precision = 1e6
+
+// toDimension returns the value of a numeric term.
+func (t term) toDimension(span ast.Span) *ast.Dimension {
+	value := math.Round(t.value*precision) / precision
+	// Avoid printing -0.
+	if value == 0 {
+		value = 0
+	}
+	return &ast.Dimension{Span: span, Value: strconv.FormatFloat(value, 'f', -1, 64), Unit: t.unit}
+}
+
+// toNode returns s as a math expression.
+func (s sum) toNode(span ast.Span) ast.Value {
+	var rv ast.Value
+	for i, t := range s {
+		negative := t.value < 0
+		if i == 0 {
+			negative = false
+		} else if negative {
+			t.value = -t.value
+		}
+
+		var v ast.Value
+		switch {
+		case t.node == nil:
+			v = t.toDimension(span)
+
+		case t.value == 1:
+			v = t.node
+
+		default:
+			v = &ast.MathExpression{Span: span, Operator: "*", Left: t.node, Right: term{value: t.value}.toDimension(span)}
+			if i == 0 && t.value < 0 {
+				v = &ast.MathExpression{Span: span, Operator: "*", Left: term{value: t.value}.toDimension(span), Right: t.node}
+			}
+		}
+
+		if rv == nil {
+			rv = v
+			continue
+		}
+
+		op := "+"
+		if negative {
+			op = "-"
+		}
+		rv = &ast.MathExpression{Span: span, Operator: op, Left: rv, Right: v}
+	}
+	return rv
+}
+
+// toFactor returns s as an operand of * or /, adding parentheses if necessary.
+func (s sum) toFactor(span ast.Span) ast.Value {
+	v := s.toNode(span)
+	if _, ok := v.(*ast.MathExpression); ok {
+		return &ast.MathParenthesizedExpression{Span: span, Value: v}
+	}
+	return v
+}
+
+// reduceMathArguments reduces math functions in the arguments of f, e.g. translate(calc(1px + 1px)).
+func (t *transformer) reduceMathArguments(f *ast.Function) {
+	for i, arg := range f.Arguments {
+		fn, ok := arg.(*ast.Function)
+		if !ok {
+			continue
+		}
+
+		if fn.IsMath() {
+			f.Arguments[i] = t.reduceMathFunction(fn)
+		} else {
+			t.reduceMathArguments(fn)
+		}
+	}
+}
@@ -15,7 +15,7 @@ func compileMath(o *transformer.Options) {
 func TestMath(t *testing.T) {
 	assert.Equal(t, `.class{width:3px}`, Transform(t, compileMath, `.class { width: calc(1px + 2px) }`))
 	assert.Equal(t, `.class{width:-1px}`, Transform(t, compileMath, `.class { width: calc(1px - 2px) }`))
-	assert.Equal(t, `.class{width:calc(1px+2rem)}`, Transform(t, compileMath, `.class { width: calc(1px + 2rem) }`))
+	assert.Equal(t, `.class{width:calc(1px + 2rem)}`, Transform(t, compileMath, `.class { width: calc(1px + 2rem) }`))
 	assert.Equal(t, `.class{width:17%}`, Transform(t, compileMath, `.class { width: calc(22% - 5%) }`))
 	assert.Panics(t, func() { Transform(t, compileMath, `.class { width: calc(2 + 25%) }`) })
 
@@ -26,7 +26,7 @@ func TestMath(t *testing.T) {
 	assert.Equal(t, `.class{width:5%}`, Transform(t, compileMath, `.class { width: calc(10% / 2) }`))
 
 	// XXX: fix precision in below
-	assert.Equal(t, `.class{width:3.3333333333333335%}`, Transform(t, compileMath, `.class { width: calc(10% / 3) }`))
+	assert.Equal(t, `.class{width:3.333333%}`, Transform(t, compileMath, `.class { width: calc(10% / 3) }`))
 	assert.Equal(t, `.class{width:4px}`, Transform(t, compileMath, `.class { width: calc(20px / 5) }`))
 	assert.Equal(t, `.class{width:20}`, Transform(t, compileMath, `.class { width: calc(20 / 1) }`))
 	assert.Panics(t, func() { Transform(t, compileMath, `.class { width: calc(2% / 25%) }`) })
@@ -34,15 +34,49 @@ func TestMath(t *testing.T) {
 
 	assert.Equal(t, `.class{width:3px}`, Transform(t, compileMath, `.class { width: calc(1px + 4px / 2) }`))
 
-	assert.Equal(t, `.class{width:calc(1px+2px)}`, Transform(t, nil, `.class { width: calc(1px + 2px) }`))
+	assert.Equal(t, `.class{width:calc(1px + 2px)}`, Transform(t, nil, `.class { width: calc(1px + 2px) }`))
 
-	// XXX: this should work but doesn't. because we treat math as left-associative and binary but
-	// 22% - 1rem cannot be reduced into something that can be added to 8rem.
-	// assert.Equal(t, `.class{width:calc(22%+7rem)}`, Transform(t, compileMath, `.class { width: calc(22% - 1rem + 8rem) }`))
+	assert.Equal(t, `.class{width:calc(22% + 7rem)}`, Transform(t, compileMath, `.class { width: calc(22% - 1rem + 8rem) }`))
+}
+
+func TestMath_PartialReduction(t *testing.T) {
+	assert.Equal(t, `.class{width:calc(100% - 15px)}`, Transform(t, compileMath, `.class { width: calc(100% - 10px - 5px) }`))
+	assert.Equal(t, `.class{width:calc(10px + 100%)}`, Transform(t, compileMath, `.class { width: calc(5px + 100% + 5px) }`))
+	assert.Equal(t, `.class{width:calc(var(--x) + 15px)}`, Transform(t, compileMath, `.class { width: calc(var(--x) + 5px + 10px) }`))
+	assert.Equal(t, `.class{width:calc(200% - 20px)}`, Transform(t, compileMath, `.class { width: calc((100% - 10px) * 2) }`))
+	assert.Equal(t, `.class{width:calc(var(--x)*2 + 2px)}`, Transform(t, compileMath, `.class { width: calc(2 * (var(--x) + 1px)) }`))
+	assert.Equal(t, `.class{width:calc(1px - var(--x))}`, Transform(t, compileMath, `.class { width: calc(1px - var(--x)) }`))
+	assert.Equal(t, `.class{width:calc(2px/var(--x))}`, Transform(t, compileMath, `.class { width: calc((1px + 1px) / var(--x)) }`))
+}
+
+func TestMath_Units(t *testing.T) {
+	assert.Equal(t, `.class{width:97px}`, Transform(t, compileMath, `.class { width: calc(1in + 1px) }`))
+	assert.Equal(t, `.class{width:2in}`, Transform(t, compileMath, `.class { width: calc(1in + 1in) }`))
+	assert.Equal(t, `.class{width:112px}`, Transform(t, compileMath, `.class { width: calc(1in + 12pt) }`))
+	assert.Equal(t, `.class{transform:rotate(450deg)}`, Transform(t, compileMath, `.class { transform: rotate(calc(1turn + 90deg)) }`))
+	assert.Equal(t, `.class{transition-duration:1.5s}`, Transform(t, compileMath, `.class { transition-duration: calc(1s + 500ms) }`))
+	assert.Panics(t, func() { Transform(t, compileMath, `.class { width: calc(1px + 1deg) }`) })
+}
+
+func TestMath_Precision(t *testing.T) {
+	assert.Equal(t, `.class{width:133.795276px}`, Transform(t, compileMath, `.class { width: calc(1in + 1cm) }`))
+	assert.Equal(t, `.class{width:0.3px}`, Transform(t, compileMath, `.class { width: calc(.1px + .2px) }`))
+	assert.Equal(t, `.class{width:0.333333px}`, Transform(t, compileMath, `.class { width: calc(1px / 3) }`))
+	assert.Equal(t, `.class{width:0px}`, Transform(t, compileMath, `.class { width: calc(-0.0000001px) }`))
+}
+
+func TestMath_Nested(t *testing.T) {
+	assert.Equal(t, `.class{width:6px}`, Transform(t, compileMath, `.class { width: calc(calc(1px + 2px) * 2) }`))
+	assert.Equal(t, `.class{width:calc(100% - 3px)}`, Transform(t, compileMath, `.class { width: calc(100% - calc(1px + 2px)) }`))
+	assert.Equal(t, `.class{width:6.283185}`, Transform(t, compileMath, `.class { width: calc(2 * pi) }`))
+}
 
-	// XXX: does this one work?
-	// calc(var(--x) + 5px)
-	// ((5px + 22%) + 5px)
-	// (((5px + 22%) + 5px) + 5px) = 22% + 15px
-	// (((5px + 22%) + 22%) + 5px) = 44% + 10px
+func TestMath_Comparisons(t *testing.T) {
+	assert.Equal(t, `.class{width:1px}`, Transform(t, compileMath, `.class { width: min(1px, 2px, calc(1px + 2px)) }`))
+	assert.Equal(t, `.class{width:1in}`, Transform(t, compileMath, `.class { width: max(1in, 90px) }`))
+	assert.Equal(t, `.class{width:2px}`, Transform(t, compileMath, `.class { width: clamp(1px, 2px, 3px) }`))
+	assert.Equal(t, `.class{width:3px}`, Transform(t, compileMath, `.class { width: clamp(1px, 5px, 3px) }`))
+	assert.Equal(t, `.class{width:4px}`, Transform(t, compileMath, `.class { width: clamp(4px, 2px, 3px) }`))
+	assert.Equal(t, `.class{width:min(3px,10%)}`, Transform(t, compileMath, `.class { width: min(calc(1px + 2px), 10%) }`))
+	assert.Equal(t, `.class{width:calc(10% + 2px)}`, Transform(t, compileMath, `.class { width: calc(10% + max(1px, 2px)) }`))
 }
@@ -29,6 +29,13 @@ func TestMediaQueryRanges(t *testing.T) {
 	assert.Equal(t, `@media (max-width:199.999px){}`, Transform(t, compileMediaQueryRanges, `@media (200px > width) {}`))
 }
 
+func TestMediaQueryRanges_Nested(t *testing.T) {
+	assert.Equal(t, `@media ((min-width:200.001px) and (not (max-width:599.999px))){}`,
+		Transform(t, compileMediaQueryRanges, `@media ((width > 200px) and (not (width < 600px))) {}`))
+	assert.Equal(t, `@media (not ((min-width:200px) and (max-width:600px))){}`,
+		Transform(t, compileMediaQueryRanges, `@media (not (200px <= width <= 600px)) {}`))
+}
+
 func TestMediaQueryRanges_Passthrough(t *testing.T) {
 	assert.Equal(t, `@media (200px>=width>=600px),(200px>=width),(width>=600px){}`,
 		Transform(t, nil, `@media (200px >= width >= 600px), (200px >= width), (width >= 600px) {}`))
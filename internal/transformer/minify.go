@@ -0,0 +1,321 @@
+package transformer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/internal/browsers"
+	"github.com/stephen/cssc/internal/color"
+)
+
+// minifyNodes rewrites nodes into shorter, equivalent forms and removes rules that
+// have no effect.
+func (t *transformer) minifyNodes(nodes []ast.Node) []ast.Node {
+	rv := make([]ast.Node, 0, len(nodes))
+	for _, n := range nodes {
+		switch node := n.(type) {
+		case *ast.QualifiedRule:
+			if selList, ok := node.Prelude.(*ast.SelectorList); ok {
+				minifySelectors(selList)
+			}
+
+			t.minifyBlock(node.Block)
+			if isEmptyBlock(node.Block) {
+				continue
+			}
+
+		case *ast.AtRule:
+			for _, prelude := range node.Preludes {
+				if mq, ok := prelude.(*ast.MediaQueryList); ok {
+					minifyMediaQueries(mq)
+				}
+			}
+
+			t.minifyBlock(node.Block)
+
+			// Empty @layer blocks still declare the order of layers, so they are kept.
+			switch node.Name {
+			case "media", "supports", "container":
+				if isEmptyBlock(node.Block) {
+					continue
+				}
+			}
+		}
+
+		rv = append(rv, n)
+	}
+	return rv
+}
+
+func (t *transformer) minifyBlock(block ast.Block) {
+	switch b := block.(type) {
+	case *ast.QualifiedRuleBlock:
+		b.Rules = t.minifyNodes(b.Rules)
+
+	case *ast.DeclarationBlock:
+		decls := b.Declarations[:0]
+		for _, decl := range b.Declarations {
+			switch d := decl.(type) {
+			case *ast.Declaration:
+				// Custom properties can be used anywhere, so their values are left as-is.
+				if !strings.HasPrefix(d.Property, "--") {
+					property := strings.ToLower(d.Property)
+					d.Values = t.minifyValues(strings.TrimPrefix(property, browsers.Vendor(property)), d.Values, false)
+				}
+
+			case *ast.QualifiedRule, *ast.AtRule:
+				nodes := t.minifyNodes([]ast.Node{d})
+				if len(nodes) == 0 {
+					continue
+				}
+			}
+			decls = append(decls, decl)
+		}
+		b.Declarations = decls
+	}
+}
+
+func isEmptyBlock(block ast.Block) bool {
+	switch b := block.(type) {
+	case *ast.QualifiedRuleBlock:
+		return len(b.Rules) == 0
+
+	case *ast.DeclarationBlock:
+		return len(b.Declarations) == 0
+
+	default:
+		return false
+	}
+}
+
+// minifyValues minifies the values of a declaration for property, which is lowercase and
+// unprefixed. inMath is set inside of math functions, where units are always needed.
+func (t *transformer) minifyValues(property string, values []ast.Value, inMath bool) []ast.Value {
+	for i, value := range values {
+		values[i] = t.minifyValue(property, value, inMath)
+	}
+	return values
+}
+
+func (t *transformer) minifyValue(property string, value ast.Value, inMath bool) ast.Value {
+	switch v := value.(type) {
+	case *ast.Dimension:
+		minifyDimension(v)
+
+		// flex-basis needs a unit to be told apart from flex-grow and flex-shrink.
+		if v.Value == "0" && lengthUnits[v.Unit] && !inMath && property != "flex" && property != "flex-basis" {
+			v.Unit = ""
+		}
+
+	case *ast.HexColor:
+		// Colors with alpha are shortest as hex, and rgba() can't write every alpha exactly.
+		if c, _, ok := parseHexColor(v); ok && c.Alpha == 1 {
+			return shortestColor(c, v.Span)
+		}
+		v.RGBA = shortHex(strings.ToLower(v.RGBA))
+
+	case *ast.Function:
+		name := strings.ToLower(v.Name)
+		switch name {
+		case "url":
+			if len(v.Arguments) == 1 {
+				if s, ok := v.Arguments[0].(*ast.String); ok && isUnquotableURL(s.Value) {
+					v.Arguments[0] = &ast.Identifier{Span: s.Span, Value: s.Value}
+				}
+			}
+			return v
+
+		case "rgb", "rgba", "hsl", "hsla", "hwb":
+			// Other color spaces can have colors outside of sRGB, so they are left as-is.
+			if c, _, ok := t.parseColor(v); ok {
+				return shortestColor(c, v.Span)
+			}
+		}
+
+		v.Arguments = t.minifyValues(property, v.Arguments, inMath || v.IsMath())
+
+	case *ast.MathExpression:
+		v.Left = t.minifyValue(property, v.Left, true)
+		v.Right = t.minifyValue(property, v.Right, true)
+
+	case *ast.MathParenthesizedExpression:
+		v.Value = t.minifyValue(property, v.Value, true)
+	}
+
+	return value
+}
+
+// lengthUnits is the set of units that can be left off of 0 lengths.
+var lengthUnits = map[string]bool{
+	"px": true, "em": true, "rem": true, "ex": true, "ch": true, "vw": true, "vh": true, "vmin": true,
+	"vmax": true, "cm": true, "mm": true, "q": true, "in": true, "pt": true, "pc": true,
+}
+
+func minifyDimension(d *ast.Dimension) {
+	d.Value = minifyNumber(d.Value)
+
+	// Units are case-insensitive, unlike the rest of a dimension's token.
+	if d.Unit != "%" && isASCIILetters(d.Unit) {
+		d.Unit = strings.ToLower(d.Unit)
+	}
+}
+
+// minifyNumber returns the shortest form of a number, e.g. .5 for 0.50.
+func minifyNumber(n string) string {
+	if strings.ContainsAny(n, "eE") {
+		return n
+	}
+
+	sign := ""
+	switch {
+	case strings.HasPrefix(n, "-"):
+		sign, n = "-", n[1:]
+	case strings.HasPrefix(n, "+"):
+		n = n[1:]
+	}
+
+	integer, fraction := n, ""
+	if i := strings.IndexByte(n, '.'); i >= 0 {
+		integer, fraction = n[:i], n[i+1:]
+	}
+	integer = strings.TrimLeft(integer, "0")
+	fraction = strings.TrimRight(fraction, "0")
+
+	switch {
+	case integer == "" && fraction == "":
+		return "0"
+	case fraction == "":
+		return sign + integer
+	default:
+		return sign + integer + "." + fraction
+	}
+}
+
+// shortestColor returns the shortest way to write an sRGB color: a name, a 3 or 6 digit hex
+// color, or rgba() for colors with alpha.
+func shortestColor(c color.Color, span ast.Span) ast.Value {
+	channels, alpha := srgbChannels(c)
+	if alpha != 1 {
+		f := colorValue(c, span).(*ast.Function)
+		for _, arg := range f.Arguments {
+			if d, ok := arg.(*ast.Dimension); ok {
+				minifyDimension(d)
+			}
+		}
+		return f
+	}
+
+	hex := shortHex(fmt.Sprintf("%02x%02x%02x", channels[0], channels[1], channels[2]))
+
+	if name, ok := color.Name([3]uint8{uint8(channels[0]), uint8(channels[1]), uint8(channels[2])}); ok && len(name) <= len(hex) {
+		return &ast.Identifier{Span: span, Value: name}
+	}
+	return &ast.HexColor{Span: span, RGBA: hex}
+}
+
+// shortHex returns the 3 or 4 digit form of a 6 or 8 digit hex color, if it has one.
+func shortHex(hex string) string {
+	if len(hex) != 6 && len(hex) != 8 {
+		return hex
+	}
+
+	short := make([]byte, 0, len(hex)/2)
+	for i := 0; i < len(hex); i += 2 {
+		if hex[i] != hex[i+1] {
+			return hex
+		}
+		short = append(short, hex[i])
+	}
+	return string(short)
+}
+
+// isUnquotableURL returns whether or not a url can be written without quotes.
+// See: https://www.w3.org/TR/css-syntax-3/#consume-url-token.
+func isUnquotableURL(url string) bool {
+	if url == "" {
+		return false
+	}
+
+	for _, r := range url {
+		switch {
+		case r == '"' || r == '\'' || r == '(' || r == ')' || r == '\\':
+			return false
+		case r <= ' ' || r == 0x7f:
+			return false
+		}
+	}
+	return true
+}
+
+// isIdentifier returns whether or not s can be written as an identifier without escapes.
+// See: https://www.w3.org/TR/css-syntax-3/#would-start-an-identifier.
+func isIdentifier(s string) bool {
+	rest := strings.TrimPrefix(s, "-")
+	if rest == "" {
+		return false
+	}
+
+	if !strings.HasPrefix(rest, "-") && !isNameStart(rune(rest[0])) {
+		return false
+	}
+
+	for _, r := range rest {
+		if !isNameStart(r) && r != '-' && !('0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameStart(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_' || r >= 0x80
+}
+
+func isASCIILetters(s string) bool {
+	for _, r := range s {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// minifySelectors removes quotes from attribute selector values where they aren't needed.
+func minifySelectors(selList *ast.SelectorList) {
+	for _, s := range selList.Selectors {
+		for _, part := range s.Parts {
+			switch p := part.(type) {
+			case *ast.AttributeSelector:
+				if str, ok := p.Value.(*ast.String); ok && isIdentifier(str.Value) {
+					p.Value = &ast.Identifier{Span: str.Span, Value: str.Value}
+				}
+
+			case *ast.PseudoClassSelector:
+				if args, ok := p.Arguments.(*ast.SelectorList); ok {
+					minifySelectors(args)
+				}
+			}
+		}
+	}
+}
+
+func minifyMediaQueries(mq *ast.MediaQueryList) {
+	for _, q := range mq.Queries {
+		for _, part := range q.Parts {
+			switch p := part.(type) {
+			case *ast.MediaFeaturePlain:
+				if d, ok := p.Value.(*ast.Dimension); ok {
+					minifyDimension(d)
+				}
+
+			case *ast.MediaFeatureRange:
+				for _, v := range []ast.Value{p.LeftValue, p.RightValue} {
+					if d, ok := v.(*ast.Dimension); ok {
+						minifyDimension(d)
+					}
+				}
+			}
+		}
+	}
+}
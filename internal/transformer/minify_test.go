@@ -0,0 +1,47 @@
+package transformer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stretchr/testify/assert"
+)
+
+func minify(o *transformer.Options) {
+	o.Minify = true
+}
+
+func TestMinify_Numbers(t *testing.T) {
+	assert.Equal(t, `.a{opacity:.5;width:10.5px;margin:0 0 0 -.25em;z-index:10}`, Transform(t, minify, `.a { opacity: 0.50; width: 010.500px; margin: 0px 0.0em -0 -0.25EM; z-index: +10 }`))
+	assert.Equal(t, `.a{width:0%;transition:0s;flex:1 1 0px;line-height:1e3}`, Transform(t, minify, `.a { width: 0%; transition: 0s; flex: 1 1 0px; line-height: 1e3 }`))
+	assert.Equal(t, `.a{-webkit-flex:1 1 0px;FLEX:1 1 0px;-ms-flex-basis:0px}`, Transform(t, minify, `.a { -webkit-flex: 1 1 0px; FLEX: 1 1 0px; -ms-flex-basis: 0px }`))
+	assert.Equal(t, `.a{width:calc(100% - 0px)}`, Transform(t, minify, `.a { width: calc(100% - 0px) }`))
+	assert.Equal(t, `.a{--x:0.50px}`, Transform(t, minify, `.a { --x: 0.50px }`))
+	assert.Equal(t, `@media (min-width:.5em){.a{color:blue}}`, Transform(t, minify, `@media (min-width: 0.50em) { .a { color: blue } }`))
+}
+
+func TestMinify_Colors(t *testing.T) {
+	assert.Equal(t, `.a{color:#fff;background:#abcdef}`, Transform(t, minify, `.a { color: #FFFFFF; background: #ABCDEF }`))
+	assert.Equal(t, `.a{color:red;background:navy}`, Transform(t, minify, `.a { color: rgb(255, 0, 0); background: #000080 }`))
+	assert.Equal(t, `.a{color:rgba(0,0,0,.5);background:red}`, Transform(t, minify, `.a { color: rgba(0, 0, 0, 0.50); background: hsl(0 100% 50% / 1) }`))
+	assert.Equal(t, `.a{background:linear-gradient(red,#fff)}`, Transform(t, minify, `.a { background: linear-gradient(#ff0000, rgb(255 255 255)) }`))
+
+	// Hex colors with alpha stay hex, which is shorter and exact.
+	assert.Equal(t, `.a{color:#ff000080;background:#f008}`, Transform(t, minify, `.a { color: #FF000080; background: #F008 }`))
+	assert.Equal(t, `.a{color:#f008;background:red}`, Transform(t, minify, `.a { color: #ff000088; background: #ff0000ff }`))
+
+	// Colors that could be outside of sRGB are left as-is.
+	assert.Equal(t, `.a{color:oklch(.5 .1 20)}`, Transform(t, minify, `.a { color: oklch(0.5 0.1 20) }`))
+	assert.Equal(t, `.a{color:rgb(var(--x))}`, Transform(t, minify, `.a { color: rgb(var(--x)) }`))
+}
+
+func TestMinify_Quotes(t *testing.T) {
+	assert.Equal(t, `.a{background:url(a.png)}`, Transform(t, minify, `.a { background: url("a.png") }`))
+	assert.Equal(t, `.a{background:url("a b.png")}`, Transform(t, minify, `.a { background: url("a b.png") }`))
+	assert.Equal(t, `input[type=text],a[href="#top"],a[data-x="1"]{color:red}`, Transform(t, minify, `input[type="text"], a[href="#top"], a[data-x="1"] { color: red }`))
+	assert.Equal(t, `:not([type=text]){color:red}`, Transform(t, minify, `:not([type="text"]) { color: red }`))
+}
+
+func TestMinify_EmptyRules(t *testing.T) {
+	assert.Equal(t, `.b{color:red}@layer a{}`, Transform(t, minify, `.a {} @media print { .a {} } @supports (display: grid) {} .b { color: red } @layer a {}`))
+}
@@ -0,0 +1,631 @@
+package transformer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/internal/browsers"
+	"github.com/stephen/cssc/internal/logging"
+	"github.com/stephen/cssc/internal/sources"
+)
+
+// Modules is the set of options for compiling a stylesheet as a CSS Module. Class names, IDs,
+// and @keyframes names are replaced with generated names, so that they are local to the
+// stylesheet. Names inside of :global() or after :global are left as-is.
+// See: https://github.com/css-modules/css-modules.
+type Modules struct {
+	// LocalName returns the generated name for a local name. It must return the same name
+	// each time it is called with the same local name.
+	LocalName func(local string) string
+
+	// Dependency returns the exports of the CSS Module that specifier refers to. It is used
+	// for composes: a from "specifier" and :import("specifier"). If it is nil, other modules
+	// can't be used.
+	Dependency func(specifier string) (map[string]string, error)
+
+	// Exports is filled in with the exports of the stylesheet: the generated name of each local
+	// name, followed by the names that it composes, and the values in :export rules. It must
+	// be non-nil.
+	Exports map[string]string
+}
+
+// composition is a class name, or a set of generated names, that a local class name composes.
+type composition struct {
+	// local is the composed class name, if it is from the same stylesheet.
+	local string
+
+	// names is the composed generated names, if they are from another module or global.
+	names []string
+
+	// decl and source are the location of the composes declaration.
+	decl   *ast.Declaration
+	source *sources.Source
+}
+
+// ModuleExports returns the exports of s as a CSS Module, without transforming or modifying
+// it. Only OriginalSource, Reporter, Modules, ImportReplacements, and ImportSources are used
+// from opts.
+func ModuleExports(s *ast.Stylesheet, opts Options) map[string]string {
+	t := &transformer{Options: opts}
+	if opts.Reporter == nil {
+		t.Reporter = logging.DefaultReporter
+	}
+	t.scopeStylesheet(t.cloneStylesheet(s))
+	return opts.Modules.Exports
+}
+
+// ModuleDependencies returns the specifiers of the CSS Modules that s uses in composes
+// declarations and :import rules.
+func ModuleDependencies(s *ast.Stylesheet) []string {
+	var specifiers []string
+
+	var visit func(nodes []ast.Node)
+	visit = func(nodes []ast.Node) {
+		for _, n := range nodes {
+			switch node := n.(type) {
+			case *ast.QualifiedRule:
+				if pc, ok := icssRule(node, "import"); ok {
+					if specifier, ok := pc.Arguments.(*ast.String); ok {
+						specifiers = append(specifiers, specifier.Value)
+					}
+					continue
+				}
+
+				block, ok := node.Block.(*ast.DeclarationBlock)
+				if !ok {
+					continue
+				}
+
+				for _, decl := range block.Declarations {
+					switch d := decl.(type) {
+					case *ast.Declaration:
+						if d.Property != "composes" || len(d.Values) < 2 {
+							continue
+						}
+						if specifier, ok := d.Values[len(d.Values)-1].(*ast.String); ok {
+							specifiers = append(specifiers, specifier.Value)
+						}
+
+					case *ast.QualifiedRule, *ast.AtRule:
+						visit([]ast.Node{d})
+					}
+				}
+
+			case *ast.AtRule:
+				if block, ok := node.Block.(*ast.QualifiedRuleBlock); ok {
+					visit(block.Rules)
+				}
+			}
+		}
+	}
+	visit(s.Nodes)
+
+	return specifiers
+}
+
+// scopeStylesheet replaces the local names in s and in every stylesheet that will be
+// inlined into it. The names in inlined stylesheets are local to s as well. Names are
+// replaced in place, so s and ImportReplacements must be copies, see cloneStylesheet.
+func (t *transformer) scopeStylesheet(s *ast.Stylesheet) {
+	stylesheets := []*ast.Stylesheet{s}
+	sources := []*sources.Source{t.OriginalSource}
+	visited := make(map[*ast.Stylesheet]bool)
+
+	var visit func(nodes []ast.Node)
+	visit = func(nodes []ast.Node) {
+		for _, n := range nodes {
+			at, ok := n.(*ast.AtRule)
+			if !ok || at.Name != "import" {
+				continue
+			}
+
+			imported, ok := t.ImportReplacements[at]
+			if !ok || imported == nil || visited[imported] {
+				continue
+			}
+			visited[imported] = true
+
+			source, ok := t.ImportSources[imported]
+			if !ok {
+				source = t.OriginalSource
+			}
+			stylesheets = append(stylesheets, imported)
+			sources = append(sources, source)
+			visit(imported.Nodes)
+		}
+	}
+	visit(s.Nodes)
+
+	t.localKeyframes = make(map[string]bool)
+	for _, ss := range stylesheets {
+		t.collectKeyframes(ss.Nodes)
+	}
+
+	t.localClasses = make(map[string]bool)
+	t.compositions = make(map[string][]composition)
+	originalSource := t.OriginalSource
+	for i, ss := range stylesheets {
+		t.OriginalSource = sources[i]
+		t.aliases = t.collectImports(ss)
+		ss.Nodes = t.scopeNodes(ss.Nodes)
+	}
+	t.OriginalSource = originalSource
+	t.aliases = nil
+
+	t.resolveCompositions()
+}
+
+// collectKeyframes adds the name of every @keyframes in nodes to t.localKeyframes, so that
+// references to them in animation and animation-name can be replaced.
+func (t *transformer) collectKeyframes(nodes []ast.Node) {
+	for _, n := range nodes {
+		at, ok := n.(*ast.AtRule)
+		if !ok {
+			continue
+		}
+
+		if name, ok := keyframesLocalName(at); ok {
+			t.localKeyframes[name] = true
+			continue
+		}
+
+		if block, ok := at.Block.(*ast.QualifiedRuleBlock); ok {
+			t.collectKeyframes(block.Rules)
+		}
+	}
+}
+
+// keyframesLocalName returns the name of a @keyframes rule.
+func keyframesLocalName(at *ast.AtRule) (string, bool) {
+	if !strings.HasSuffix(at.Name, "keyframes") || len(at.Preludes) != 1 {
+		return "", false
+	}
+
+	switch name := at.Preludes[0].(type) {
+	case *ast.Identifier:
+		return name.Value, true
+
+	case *ast.String:
+		return name.Value, true
+	}
+	return "", false
+}
+
+// icssRule returns the pseudo class of an ICSS rule, e.g. :export, if rule is one.
+func icssRule(rule *ast.QualifiedRule, name string) (*ast.PseudoClassSelector, bool) {
+	selList, ok := rule.Prelude.(*ast.SelectorList)
+	if !ok || len(selList.Selectors) != 1 {
+		return nil, false
+	}
+
+	parts := trimWhitespace(selList.Selectors[0].Parts)
+	if len(parts) != 1 {
+		return nil, false
+	}
+
+	pc, ok := parts[0].(*ast.PseudoClassSelector)
+	if !ok || pc.Name != name {
+		return nil, false
+	}
+	return pc, true
+}
+
+// collectImports removes the :import rules from ss and returns the values that they import,
+// keyed by their alias. See: https://github.com/css-modules/icss#import.
+func (t *transformer) collectImports(ss *ast.Stylesheet) map[string]string {
+	aliases := make(map[string]string)
+	nodes := make([]ast.Node, 0, len(ss.Nodes))
+	for _, n := range ss.Nodes {
+		rule, ok := n.(*ast.QualifiedRule)
+		if !ok {
+			nodes = append(nodes, n)
+			continue
+		}
+
+		pc, ok := icssRule(rule, "import")
+		if !ok {
+			nodes = append(nodes, n)
+			continue
+		}
+
+		specifier, ok := pc.Arguments.(*ast.String)
+		if !ok {
+			t.addError(pc, ":import() must contain a path")
+			continue
+		}
+
+		exports, ok := t.dependency(pc, specifier.Value)
+		if !ok {
+			continue
+		}
+
+		block, ok := rule.Block.(*ast.DeclarationBlock)
+		if !ok {
+			continue
+		}
+
+		for _, decl := range block.Declarations {
+			d, ok := decl.(*ast.Declaration)
+			if !ok {
+				continue
+			}
+
+			name, ok := singleIdentifier(d.Values)
+			if !ok {
+				t.addError(d, "expected the name of an export from %s", specifier.Value)
+				continue
+			}
+
+			value, ok := exports[name]
+			if !ok {
+				t.addError(d, "%s is not exported by %s", name, specifier.Value)
+				continue
+			}
+			aliases[d.Property] = value
+		}
+	}
+	ss.Nodes = nodes
+	return aliases
+}
+
+func singleIdentifier(values []ast.Value) (string, bool) {
+	if len(values) != 1 {
+		return "", false
+	}
+
+	id, ok := values[0].(*ast.Identifier)
+	if !ok {
+		return "", false
+	}
+	return id.Value, true
+}
+
+// dependency returns the exports of another module. Errors are reported at loc.
+func (t *transformer) dependency(loc ast.Node, specifier string) (map[string]string, bool) {
+	if t.Modules.Dependency == nil {
+		t.addError(loc, "could not resolve %s", specifier)
+		return nil, false
+	}
+
+	exports, err := t.Modules.Dependency(specifier)
+	if err != nil {
+		t.addError(loc, "%s", err)
+		return nil, false
+	}
+	return exports, true
+}
+
+// localName returns the generated name for a local name and adds it to the exports.
+func (t *transformer) localName(local string) string {
+	name := t.Modules.LocalName(local)
+	t.Modules.Exports[local] = name
+	return name
+}
+
+// scopeNodes replaces the local names in nodes. ICSS :export rules are removed.
+func (t *transformer) scopeNodes(nodes []ast.Node) []ast.Node {
+	rv := make([]ast.Node, 0, len(nodes))
+	for _, n := range nodes {
+		switch node := n.(type) {
+		case *ast.QualifiedRule:
+			if _, ok := icssRule(node, "export"); ok {
+				t.collectExports(node)
+				continue
+			}
+
+			var class string
+			if selList, ok := node.Prelude.(*ast.SelectorList); ok {
+				class = composingClass(selList)
+				t.scopeSelectorList(selList)
+			}
+			t.scopeBlock(node.Block, class)
+
+		case *ast.AtRule:
+			if name, ok := keyframesLocalName(node); ok {
+				node.Preludes[0] = &ast.Identifier{Span: node.Preludes[0].Location(), Value: t.localName(name)}
+				break
+			}
+			t.scopeBlock(node.Block, "")
+		}
+
+		rv = append(rv, n)
+	}
+	return rv
+}
+
+// composingClass returns the class name of a selector list that is a single class, e.g. .a.
+// Only rules with a single class can have composes declarations.
+func composingClass(selList *ast.SelectorList) string {
+	if len(selList.Selectors) != 1 {
+		return ""
+	}
+
+	parts := trimWhitespace(selList.Selectors[0].Parts)
+	if len(parts) != 1 {
+		return ""
+	}
+
+	if class, ok := parts[0].(*ast.ClassSelector); ok {
+		return class.Name
+	}
+	return ""
+}
+
+// scopeBlock replaces the local names in block. class is the class name of the rule that
+// block is in, if composes can be used in it.
+func (t *transformer) scopeBlock(block ast.Block, class string) {
+	switch b := block.(type) {
+	case *ast.QualifiedRuleBlock:
+		b.Rules = t.scopeNodes(b.Rules)
+
+	case *ast.DeclarationBlock:
+		decls := make([]ast.Declarationish, 0, len(b.Declarations))
+		for _, decl := range b.Declarations {
+			switch d := decl.(type) {
+			case *ast.Declaration:
+				if d.Property == "composes" {
+					t.compose(d, class)
+					continue
+				}
+				t.scopeDeclaration(d)
+
+			case *ast.QualifiedRule, *ast.AtRule:
+				if len(t.scopeNodes([]ast.Node{d})) == 0 {
+					continue
+				}
+			}
+			decls = append(decls, decl)
+		}
+		b.Declarations = decls
+	}
+}
+
+// scopeDeclaration replaces references to local @keyframes in animation and animation-name,
+// and aliases from :import rules.
+func (t *transformer) scopeDeclaration(d *ast.Declaration) {
+	t.substituteAliases(d.Values)
+
+	property := strings.TrimPrefix(d.Property, browsers.Vendor(d.Property))
+	if property != "animation" && property != "animation-name" {
+		return
+	}
+
+	for i, v := range d.Values {
+		switch v := v.(type) {
+		case *ast.Identifier:
+			if t.localKeyframes[v.Value] {
+				d.Values[i] = &ast.Identifier{Span: v.Span, Value: t.localName(v.Value)}
+			}
+
+		case *ast.String:
+			if property == "animation-name" && t.localKeyframes[v.Value] {
+				d.Values[i] = &ast.Identifier{Span: v.Span, Value: t.localName(v.Value)}
+			}
+		}
+	}
+}
+
+// substituteAliases replaces aliases from :import rules in values with the imported values.
+func (t *transformer) substituteAliases(values []ast.Value) {
+	for i, v := range values {
+		switch v := v.(type) {
+		case *ast.Identifier:
+			if value, ok := t.aliases[v.Value]; ok {
+				values[i] = &ast.Raw{Span: v.Span, Value: value}
+			}
+
+		case *ast.Function:
+			t.substituteAliases(v.Arguments)
+		}
+	}
+}
+
+// collectExports adds the values in an :export rule to the exports.
+// See: https://github.com/css-modules/icss#export.
+func (t *transformer) collectExports(rule *ast.QualifiedRule) {
+	block, ok := rule.Block.(*ast.DeclarationBlock)
+	if !ok {
+		return
+	}
+
+	for _, decl := range block.Declarations {
+		d, ok := decl.(*ast.Declaration)
+		if !ok {
+			continue
+		}
+
+		if name, ok := singleIdentifier(d.Values); ok {
+			if value, ok := t.aliases[name]; ok {
+				t.Modules.Exports[d.Property] = value
+				continue
+			}
+		}
+
+		// Values are exported as they are written.
+		var value string
+		if len(d.Values) > 0 {
+			value = t.OriginalSource.Content[d.Values[0].Location().Start:d.Values[len(d.Values)-1].Location().End]
+		}
+		t.Modules.Exports[d.Property] = value
+	}
+}
+
+// compose records the class names that a composes declaration adds to class.
+// See: https://github.com/css-modules/css-modules#composition.
+func (t *transformer) compose(d *ast.Declaration, class string) {
+	if class == "" {
+		t.addError(d, "composes can only be used in a rule with a single class selector")
+		return
+	}
+
+	var names []string
+	var from ast.Value
+	for i, v := range d.Values {
+		id, ok := v.(*ast.Identifier)
+		if !ok {
+			t.addError(v, "expected a class name")
+			return
+		}
+
+		if id.Value == "from" && i == len(d.Values)-2 {
+			from = d.Values[i+1]
+			break
+		}
+		names = append(names, id.Value)
+	}
+
+	if len(names) == 0 {
+		t.addError(d, "expected a class name")
+		return
+	}
+
+	switch from := from.(type) {
+	case nil:
+		for _, name := range names {
+			t.compositions[class] = append(t.compositions[class], composition{local: name, decl: d, source: t.OriginalSource})
+		}
+
+	case *ast.Identifier:
+		if from.Value != "global" {
+			t.addError(from, "expected a path or global")
+			return
+		}
+		t.compositions[class] = append(t.compositions[class], composition{names: names})
+
+	case *ast.String:
+		exports, ok := t.dependency(d, from.Value)
+		if !ok {
+			return
+		}
+
+		var composed []string
+		for _, name := range names {
+			generated, ok := exports[name]
+			if !ok {
+				t.addError(d, "%s is not exported by %s", name, from.Value)
+				continue
+			}
+			composed = append(composed, strings.Fields(generated)...)
+		}
+		t.compositions[class] = append(t.compositions[class], composition{names: composed})
+
+	default:
+		t.addError(from, "expected a path or global")
+	}
+}
+
+// resolveCompositions adds the names that each local class name composes to its export.
+func (t *transformer) resolveCompositions() {
+	classes := make([]string, 0, len(t.compositions))
+	for class := range t.compositions {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	for _, class := range classes {
+		for _, c := range t.compositions[class] {
+			if c.local != "" && !t.localClasses[c.local] {
+				t.Reporter.AddError(logging.LocationErrorf(c.source, c.decl.Location(), "%s is not a class in this file", c.local))
+			}
+		}
+	}
+
+	var expand func(class string, seen map[string]bool) []string
+	expand = func(class string, seen map[string]bool) []string {
+		if seen[class] {
+			return nil
+		}
+		seen[class] = true
+
+		names := []string{t.Modules.LocalName(class)}
+		for _, c := range t.compositions[class] {
+			if c.local == "" {
+				names = append(names, c.names...)
+			} else if t.localClasses[c.local] {
+				names = append(names, expand(c.local, seen)...)
+			}
+		}
+		return names
+	}
+
+	for _, class := range classes {
+		var names []string
+		seen := make(map[string]bool)
+		for _, name := range expand(class, make(map[string]bool)) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		t.Modules.Exports[class] = strings.Join(names, " ")
+	}
+}
+
+func (t *transformer) scopeSelectorList(selList *ast.SelectorList) {
+	for _, s := range selList.Selectors {
+		s.Parts = t.scopeSelectorParts(s.Parts, true)
+	}
+}
+
+// scopeSelectorParts replaces the class names and IDs in parts. local is whether or not names
+// are local at the start of parts, which changes after a bare :global or :local.
+func (t *transformer) scopeSelectorParts(parts []ast.SelectorPart, local bool) []ast.SelectorPart {
+	rv := make([]ast.SelectorPart, 0, len(parts))
+
+	// The whitespace after a bare :global or :local is not a descendant combinator.
+	var skipWhitespace bool
+	for _, part := range parts {
+		if _, ok := part.(*ast.Whitespace); ok && skipWhitespace {
+			continue
+		}
+		skipWhitespace = false
+
+		switch p := part.(type) {
+		case *ast.ClassSelector:
+			if local {
+				t.localClasses[p.Name] = true
+				p.Name = t.localName(p.Name)
+			}
+
+		case *ast.IDSelector:
+			if local {
+				p.Name = t.localName(p.Name)
+			}
+
+		case *ast.PseudoClassSelector:
+			if p.Name != "global" && p.Name != "local" {
+				if args, ok := p.Arguments.(*ast.SelectorList); ok {
+					for _, s := range args.Selectors {
+						s.Parts = t.scopeSelectorParts(s.Parts, local)
+					}
+				}
+				break
+			}
+
+			if p.Arguments == nil {
+				local = p.Name == "local"
+				skipWhitespace = true
+				continue
+			}
+
+			args, ok := p.Arguments.(*ast.SelectorList)
+			if !ok || len(args.Selectors) != 1 {
+				t.addError(p, ":%s() must contain a single selector", p.Name)
+				continue
+			}
+			rv = append(rv, t.scopeSelectorParts(trimWhitespace(args.Selectors[0].Parts), p.Name == "local")...)
+			continue
+
+		case *ast.PseudoElementSelector:
+			if args, ok := p.Inner.Arguments.(*ast.SelectorList); ok {
+				for _, s := range args.Selectors {
+					s.Parts = t.scopeSelectorParts(s.Parts, local)
+				}
+			}
+		}
+
+		rv = append(rv, part)
+	}
+	return rv
+}
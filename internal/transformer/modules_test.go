@@ -0,0 +1,112 @@
+package transformer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+)
+
+// TransformModule transforms s as a CSS Module and returns the output and the exports. The
+// only other module is ./other.css.
+func TransformModule(t testing.TB, s string) (string, map[string]string) {
+	modules := &transformer.Modules{
+		LocalName: func(local string) string { return "main_" + local },
+		Dependency: func(specifier string) (map[string]string, error) {
+			if specifier != "./other.css" {
+				return nil, fmt.Errorf("could not resolve %s", specifier)
+			}
+			return map[string]string{"a": "other_a", "b": "other_b other_c", "primary": "blue"}, nil
+		},
+		Exports: make(map[string]string),
+	}
+	return Transform(t, func(o *transformer.Options) { o.Modules = modules }, s), modules.Exports
+}
+
+func TestModules_Selectors(t *testing.T) {
+	out, exports := TransformModule(t, `.a, #b .c:hover > div.d { color: red }`)
+	assert.Equal(t, `.main_a,#main_b .main_c:hover > div.main_d{color:red}`, out)
+	assert.Equal(t, map[string]string{"a": "main_a", "b": "main_b", "c": "main_c", "d": "main_d"}, exports)
+
+	out, exports = TransformModule(t, `.a:not(.b, .c) { color: red }`)
+	assert.Equal(t, `.main_a:not(.main_b, .main_c){color:red}`, out)
+	assert.Equal(t, map[string]string{"a": "main_a", "b": "main_b", "c": "main_c"}, exports)
+
+	out, _ = TransformModule(t, `@media print { .a { color: red } }`)
+	assert.Equal(t, `@media print{.main_a{color:red}}`, out)
+}
+
+func TestModules_Global(t *testing.T) {
+	out, exports := TransformModule(t, `:global(.a) .b { color: red }`)
+	assert.Equal(t, `.a .main_b{color:red}`, out)
+	assert.Equal(t, map[string]string{"b": "main_b"}, exports)
+
+	out, _ = TransformModule(t, `.a :global(.b.c) .d { color: red }`)
+	assert.Equal(t, `.main_a .b.c .main_d{color:red}`, out)
+
+	out, _ = TransformModule(t, `:global .a .b :local(.c) { color: red }`)
+	assert.Equal(t, `.a .b .main_c{color:red}`, out)
+
+	out, _ = TransformModule(t, `.a :global .b, .c { color: red }`)
+	assert.Equal(t, `.main_a .b,.main_c{color:red}`, out)
+
+	out, _ = TransformModule(t, `:local .a { color: red }`)
+	assert.Equal(t, `.main_a{color:red}`, out)
+
+	assert.Panics(t, func() { TransformModule(t, `:global(.a, .b) { color: red }`) })
+}
+
+func TestModules_Keyframes(t *testing.T) {
+	out, exports := TransformModule(t, `.a { animation: spin 1s, fade 2s; animation-name: spin } @keyframes spin { to { opacity: 0 } }`)
+	assert.Equal(t, `.main_a{animation:main_spin 1s,fade 2s;animation-name:main_spin}@keyframes main_spin{to{opacity:0}}`, out)
+	assert.Equal(t, map[string]string{"a": "main_a", "spin": "main_spin"}, exports)
+
+	out, _ = TransformModule(t, `@keyframes "spin" { to { opacity: 0 } } .a { -webkit-animation-name: "spin" }`)
+	assert.Equal(t, `@keyframes main_spin{to{opacity:0}}.main_a{-webkit-animation-name:main_spin}`, out)
+}
+
+func TestModules_Composes(t *testing.T) {
+	out, exports := TransformModule(t, `.a { color: red } .b { composes: a; margin: 0 } .c { composes: b from global; composes: b; }`)
+	assert.Equal(t, `.main_a{color:red}.main_b{margin:0}`, out)
+	assert.Equal(t, map[string]string{"a": "main_a", "b": "main_b main_a", "c": "main_c b main_b main_a"}, exports)
+
+	out, exports = TransformModule(t, `.a { composes: a b from "./other.css"; color: red }`)
+	assert.Equal(t, `.main_a{color:red}`, out)
+	assert.Equal(t, map[string]string{"a": "main_a other_a other_b other_c"}, exports)
+
+	// Compositions can be defined after they are used and can refer to each other.
+	_, exports = TransformModule(t, `.a { composes: b } .b { composes: a }`)
+	assert.Equal(t, map[string]string{"a": "main_a main_b", "b": "main_b main_a"}, exports)
+
+	assert.Panics(t, func() { TransformModule(t, `.a { composes: missing }`) })
+	assert.Panics(t, func() { TransformModule(t, `.a { composes: missing from "./other.css" }`) })
+	assert.Panics(t, func() { TransformModule(t, `.a { composes: a from "./missing.css" }`) })
+	assert.Panics(t, func() { TransformModule(t, `.a .b { composes: a from global }`) })
+	assert.Panics(t, func() { TransformModule(t, `.a { composes: a from elsewhere }`) })
+}
+
+func TestModules_ICSS(t *testing.T) {
+	out, exports := TransformModule(t, `:export { gutter: 10px; font: "Helvetica", sans-serif } .a { color: red }`)
+	assert.Equal(t, `.main_a{color:red}`, out)
+	assert.Equal(t, map[string]string{"gutter": "10px", "font": `"Helvetica", sans-serif`, "a": "main_a"}, exports)
+
+	out, exports = TransformModule(t, `:import("./other.css") { brand: primary } .a { color: brand; border: 1px solid brand } :export { color: brand }`)
+	assert.Equal(t, `.main_a{color:blue;border:1px solid blue}`, out)
+	assert.Equal(t, map[string]string{"a": "main_a", "color": "blue"}, exports)
+
+	assert.Panics(t, func() { TransformModule(t, `:import("./other.css") { brand: missing }`) })
+	assert.Panics(t, func() { TransformModule(t, `:import("./missing.css") { brand: primary }`) })
+}
+
+func TestModules_Nesting(t *testing.T) {
+	modules := &transformer.Modules{
+		LocalName: func(local string) string { return "main_" + local },
+		Exports:   make(map[string]string),
+	}
+	assert.Equal(t, `.main_a{color:red}.main_a .b{color:blue}`, Transform(t, func(o *transformer.Options) {
+		o.Modules = modules
+		o.Nesting = transforms.NestingTransform
+	}, `.a { color: red; & :global(.b) { color: blue } }`))
+}
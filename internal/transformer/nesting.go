@@ -0,0 +1,299 @@
+package transformer
+
+import (
+	"github.com/stephen/cssc/internal/ast"
+)
+
+// hasNestedRules returns whether or not the rule contains nested rules.
+func hasNestedRules(rule *ast.QualifiedRule) bool {
+	block, ok := rule.Block.(*ast.DeclarationBlock)
+	if !ok {
+		return false
+	}
+
+	for _, d := range block.Declarations {
+		switch d.(type) {
+		case *ast.QualifiedRule, *ast.AtRule:
+			return true
+		}
+	}
+
+	return false
+}
+
+// lowerNesting flattens rule, which may contain nested rules, into a list of
+// rules without any nesting. The list is in the same cascade order as the input.
+// See: https://www.w3.org/TR/css-nesting-1/.
+func (t *transformer) lowerNesting(rule *ast.QualifiedRule) []ast.Node {
+	block, ok := rule.Block.(*ast.DeclarationBlock)
+	if !ok {
+		return []ast.Node{rule}
+	}
+
+	selectors, ok := rule.Prelude.(*ast.SelectorList)
+	if !ok {
+		return []ast.Node{rule}
+	}
+
+	var rv []ast.Node
+
+	// Declarations are split around nested rules so that declarations after a nested
+	// rule still come after it in the cascade.
+	var decls []ast.Declarationish
+	flush := func() {
+		if len(decls) == 0 {
+			return
+		}
+
+		rv = append(rv, &ast.QualifiedRule{
+			Span:    rule.Span,
+			Prelude: cloneSelectorList(selectors),
+			Block: &ast.DeclarationBlock{
+				Span:         block.Span,
+				Declarations: decls,
+			},
+		})
+		decls = nil
+	}
+
+	for _, d := range block.Declarations {
+		switch nested := d.(type) {
+		case *ast.QualifiedRule:
+			flush()
+
+			nestedSelectors, ok := nested.Prelude.(*ast.SelectorList)
+			if !ok {
+				t.addError(nested.Prelude, "expected selector list for nested rule")
+				continue
+			}
+
+			rv = append(rv, t.lowerNesting(&ast.QualifiedRule{
+				Span: nested.Span,
+				Prelude: &ast.SelectorList{
+					Span:      nestedSelectors.Span,
+					Selectors: resolveNestedSelectors(nestedSelectors.Selectors, selectors),
+				},
+				Block: nested.Block,
+			})...)
+
+		case *ast.AtRule:
+			flush()
+			rv = append(rv, t.lowerNestedAtRule(nested, rule, selectors))
+
+		default:
+			decls = append(decls, d)
+		}
+	}
+	flush()
+
+	return rv
+}
+
+// lowerNestedAtRule hoists a conditional rule nested in parent so that it wraps
+// a copy of parent instead. For example, .a { @media print { color: red } } becomes
+// @media print { .a { color: red } }.
+func (t *transformer) lowerNestedAtRule(at *ast.AtRule, parent *ast.QualifiedRule, selectors *ast.SelectorList) ast.Node {
+	if at.Name == "layer" && at.Block == nil {
+		// @layer statements only declare layer order, so they can be hoisted as-is.
+		return at
+	}
+
+	block, ok := at.Block.(*ast.DeclarationBlock)
+	if !ok || !isConditionalGroupRule(at.Name) {
+		t.addWarn(at, "@%s cannot be nested inside a style rule", at.Name)
+		return at
+	}
+
+	return &ast.AtRule{
+		Span:     at.Span,
+		Name:     at.Name,
+		Preludes: at.Preludes,
+		Block: &ast.QualifiedRuleBlock{
+			Span: block.Span,
+			Rules: t.lowerNesting(&ast.QualifiedRule{
+				Span:    parent.Span,
+				Prelude: selectors,
+				Block:   block,
+			}),
+		},
+	}
+}
+
+// cloneSelectorList returns a deep copy of selectors, so that each rule that lowering
+// emits has its own selectors and transforming one of them doesn't change the others.
+func cloneSelectorList(selectors *ast.SelectorList) *ast.SelectorList {
+	return ast.NewCloner().Node(selectors).(*ast.SelectorList)
+}
+
+// isConditionalGroupRule returns whether or not the at-rule with the given name
+// can be nested inside a style rule.
+func isConditionalGroupRule(name string) bool {
+	switch name {
+	case "media", "supports", "layer", "container":
+		return true
+	default:
+		return false
+	}
+}
+
+// resolveNestedSelectors returns a copy of nested with all nesting selectors (&)
+// replaced by parent. Selectors without a nesting selector are treated as relative
+// to the parent, e.g. > .child is the same as & > .child.
+// See: https://www.w3.org/TR/css-nesting-1/#nest-selector.
+func resolveNestedSelectors(nested []*ast.Selector, parent *ast.SelectorList) []*ast.Selector {
+	rv := make([]*ast.Selector, 0, len(nested))
+	for _, sel := range nested {
+		parts := trimWhitespace(sel.Parts)
+		if !containsNestingSelector(parts) {
+			parts = append([]ast.SelectorPart{&ast.NestingSelector{}, &ast.Whitespace{}}, parts...)
+		}
+
+		rv = append(rv, &ast.Selector{
+			Span:  sel.Span,
+			Parts: substituteNestingSelector(parts, parent),
+		})
+	}
+
+	return rv
+}
+
+// substituteNestingSelector replaces & in parts with parent. When possible, the parent
+// is substituted directly. Otherwise, it's wrapped in :is() to keep the same meaning.
+func substituteNestingSelector(parts []ast.SelectorPart, parent *ast.SelectorList) []ast.SelectorPart {
+	rv := make([]ast.SelectorPart, 0, len(parts))
+	for i, part := range parts {
+		switch p := part.(type) {
+		case *ast.NestingSelector:
+			if direct, ok := directParentParts(parts, i, parent); ok {
+				for _, part := range direct {
+					rv = append(rv, ast.NewCloner().Node(part).(ast.SelectorPart))
+				}
+				break
+			}
+
+			rv = append(rv, &ast.PseudoClassSelector{
+				Span:      p.Span,
+				Name:      "is",
+				Arguments: cloneSelectorList(parent),
+			})
+
+		case *ast.PseudoClassSelector:
+			args, ok := p.Arguments.(*ast.SelectorList)
+			if !ok || !containsNestingSelector([]ast.SelectorPart{p}) {
+				rv = append(rv, p)
+				break
+			}
+
+			newArgs := &ast.SelectorList{
+				Span:      args.Span,
+				Selectors: make([]*ast.Selector, 0, len(args.Selectors)),
+			}
+			for _, arg := range args.Selectors {
+				newArgs.Selectors = append(newArgs.Selectors, &ast.Selector{
+					Span:  arg.Span,
+					Parts: substituteNestingSelector(trimWhitespace(arg.Parts), parent),
+				})
+			}
+
+			rv = append(rv, &ast.PseudoClassSelector{
+				Span:      p.Span,
+				Name:      p.Name,
+				Arguments: newArgs,
+			})
+
+		default:
+			rv = append(rv, part)
+		}
+	}
+
+	return rv
+}
+
+// directParentParts returns the parent's selector parts if they can be substituted
+// for the & at parts[i] without changing the meaning of the selector. This is only
+// the case when the parent is a single selector and either the & is at the start of
+// the selector, or the parent is a single compound selector.
+func directParentParts(parts []ast.SelectorPart, i int, parent *ast.SelectorList) ([]ast.SelectorPart, bool) {
+	if len(parent.Selectors) != 1 {
+		return nil, false
+	}
+
+	parentParts := trimWhitespace(parent.Selectors[0].Parts)
+	if i == 0 {
+		return parentParts, true
+	}
+
+	if !isCompoundSelector(parentParts) {
+		return nil, false
+	}
+
+	// A type selector must come first in a compound selector, so it can't be
+	// appended to the previous part, e.g. .a& with a parent of div.
+	_, isType := parentParts[0].(*ast.TypeSelector)
+	switch parts[i-1].(type) {
+	case *ast.Whitespace, *ast.CombinatorSelector:
+	default:
+		if isType {
+			return nil, false
+		}
+	}
+
+	return parentParts, true
+}
+
+// isCompoundSelector returns whether or not parts is a compound selector, i.e. it
+// has no combinators.
+func isCompoundSelector(parts []ast.SelectorPart) bool {
+	for _, part := range parts {
+		switch part.(type) {
+		case *ast.Whitespace, *ast.CombinatorSelector:
+			return false
+		}
+	}
+
+	return len(parts) > 0
+}
+
+// containsNestingSelector returns whether or not parts contains &, including
+// inside of pseudo class arguments like :not(&).
+func containsNestingSelector(parts []ast.SelectorPart) bool {
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *ast.NestingSelector:
+			return true
+
+		case *ast.PseudoClassSelector:
+			args, ok := p.Arguments.(*ast.SelectorList)
+			if !ok {
+				continue
+			}
+
+			for _, arg := range args.Selectors {
+				if containsNestingSelector(arg.Parts) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// trimWhitespace returns parts without any leading or trailing whitespace.
+func trimWhitespace(parts []ast.SelectorPart) []ast.SelectorPart {
+	for len(parts) > 0 {
+		if _, ok := parts[0].(*ast.Whitespace); !ok {
+			break
+		}
+		parts = parts[1:]
+	}
+
+	for len(parts) > 0 {
+		if _, ok := parts[len(parts)-1].(*ast.Whitespace); !ok {
+			break
+		}
+		parts = parts[:len(parts)-1]
+	}
+
+	return parts
+}
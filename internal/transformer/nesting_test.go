@@ -0,0 +1,106 @@
+package transformer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+)
+
+func compileNesting(o *transformer.Options) {
+	o.Nesting = transforms.NestingTransform
+}
+
+func TestNesting(t *testing.T) {
+	assert.Equal(t, `.card{color:red}.card .title{font-weight:bold}.card:hover{color:blue}`, Transform(t, compileNesting, `
+.card {
+	color: red;
+	& .title { font-weight: bold; }
+	&:hover { color: blue; }
+}`))
+
+	assert.Equal(t, `.card .title{color:red}.card > .icon{color:blue}.card + .card{margin:0}`, Transform(t, compileNesting, `
+.card {
+	.title { color: red }
+	> .icon { color: blue }
+	+ .card { margin: 0 }
+}`))
+
+	assert.Equal(t, `.a .b .c{color:red}`, Transform(t, compileNesting, `.a { .b { .c { color: red } } }`))
+	assert.Equal(t, `.a.b{color:red}`, Transform(t, compileNesting, `.a { &.b { color: red } }`))
+	assert.Equal(t, `.b.a{color:red}`, Transform(t, compileNesting, `.a { .b& { color: red } }`))
+	assert.Equal(t, `.a .a{color:red}`, Transform(t, compileNesting, `.a { & & { color: red } }`))
+}
+
+func TestNesting_Is(t *testing.T) {
+	assert.Equal(t, `:is(.a,.b) .c{color:red}`, Transform(t, compileNesting, `.a, .b { .c { color: red } }`))
+	assert.Equal(t, `:is(.a,.b):hover,:is(.a,.b):focus{color:red}`, Transform(t, compileNesting, `.a, .b { &:hover, &:focus { color: red } }`))
+	assert.Equal(t, `.a .b:hover{color:red}`, Transform(t, compileNesting, `.a .b { &:hover { color: red } }`))
+	assert.Equal(t, `.c :is(.a .b){color:red}`, Transform(t, compileNesting, `.a .b { .c & { color: red } }`))
+	assert.Equal(t, `.c .a{color:red}`, Transform(t, compileNesting, `.a { .c & { color: red } }`))
+	assert.Equal(t, `.c:is(div){color:red}`, Transform(t, compileNesting, `div { .c& { color: red } }`))
+	assert.Equal(t, `.c:not(.a){color:red}`, Transform(t, compileNesting, `.a { .c:not(&) { color: red } }`))
+	assert.Equal(t, `:is(.a,.b) .c .d{color:red}`, Transform(t, compileNesting, `.a, .b { .c { & .d { color: red } } }`))
+}
+
+func TestNesting_DeclarationOrder(t *testing.T) {
+	assert.Equal(t, `.a{color:red}.a .b{color:blue}.a{margin:0}`, Transform(t, compileNesting, `
+.a {
+	color: red;
+	.b { color: blue; }
+	margin: 0;
+}`))
+}
+
+func TestNesting_ConditionalRules(t *testing.T) {
+	assert.Equal(t, `.card{color:red}@media (min-width:30em){.card{padding:1rem}.card .title{color:blue}}`, Transform(t, compileNesting, `
+.card {
+	color: red;
+	@media (min-width: 30em) {
+		padding: 1rem;
+		& .title { color: blue; }
+	}
+}`))
+
+	assert.Equal(t, `@supports (display:grid){@media print{.a{display:grid}}}`, Transform(t, compileNesting, `
+.a {
+	@supports (display: grid) {
+		@media print {
+			display: grid;
+		}
+	}
+}`))
+
+	assert.Equal(t, `@media print{.a .b{color:red}}`, Transform(t, compileNesting, `
+@media print {
+	.a { .b { color: red } }
+}`))
+}
+
+func TestNesting_WithOtherTransforms(t *testing.T) {
+	assert.Equal(t, `@media (min-width:200px){.a{color:red}}.a:visited,.a:link{color:blue}`, Transform(t, func(o *transformer.Options) {
+		o.Nesting = transforms.NestingTransform
+		o.MediaFeatureRanges = transforms.MediaFeatureRangesTransform
+		o.AnyLink = transforms.AnyLinkTransform
+	}, `
+.a {
+	@media (width >= 200px) { color: red; }
+	&:any-link { color: blue; }
+}`))
+}
+
+func TestNesting_Passthrough(t *testing.T) {
+	assert.Equal(t, `.card{color:red;& .title{font-weight:bold}@media print{color:blue}}`, Transform(t, nil, `
+.card {
+	color: red;
+	& .title { font-weight: bold; }
+	@media print { color: blue; }
+}`))
+}
+
+func TestNesting_SharedImport(t *testing.T) {
+	// Both imports inline the same stylesheet, so lowering must not modify it.
+	assert.Equal(t, `@media print{.a{color:red}.a .b{color:blue}}.a{color:red}.a .b{color:blue}`,
+		TransformImport(t, transforms.Options{Nesting: transforms.NestingTransform}, `@import "other.css" print; @import "other.css";`, `.a { color: red; .b { color: blue } }`))
+}
@@ -0,0 +1,495 @@
+package transformer
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/internal/browsers"
+	"github.com/stephen/cssc/internal/color"
+)
+
+// optimizeNodes merges and removes rules and declarations in nodes without changing the
+// result of the cascade. Only adjacent rules are merged, since moving a rule past another
+// one could change which of them wins.
+func (t *transformer) optimizeNodes(nodes []ast.Node) []ast.Node {
+	for _, n := range nodes {
+		if at, ok := n.(*ast.AtRule); ok {
+			if block, ok := at.Block.(*ast.QualifiedRuleBlock); ok && at.Name != "keyframes" {
+				block.Rules = t.optimizeNodes(block.Rules)
+			}
+		}
+	}
+
+	nodes = removeDuplicateKeyframes(nodes)
+	nodes = mergeAdjacentSelectors(nodes)
+	nodes = mergeAdjacentBlocks(nodes)
+	return mergeAdjacentSelectors(nodes)
+}
+
+// removeDuplicateKeyframes removes @keyframes that are replaced by a later @keyframes with
+// the same name, since only the last one is used.
+func removeDuplicateKeyframes(nodes []ast.Node) []ast.Node {
+	last := make(map[string]int)
+	for i, n := range nodes {
+		if name, ok := keyframesName(n); ok {
+			last[name] = i
+		}
+	}
+
+	rv := make([]ast.Node, 0, len(nodes))
+	for i, n := range nodes {
+		if name, ok := keyframesName(n); ok && last[name] != i {
+			continue
+		}
+		rv = append(rv, n)
+	}
+	return rv
+}
+
+// keyframesName returns the at-rule and animation name of a @keyframes rule, e.g.
+// -webkit-keyframes spin.
+func keyframesName(n ast.Node) (string, bool) {
+	at, ok := n.(*ast.AtRule)
+	if !ok || !strings.HasSuffix(at.Name, "keyframes") || len(at.Preludes) != 1 {
+		return "", false
+	}
+
+	switch name := at.Preludes[0].(type) {
+	case *ast.Identifier:
+		return at.Name + " " + name.Value, true
+
+	case *ast.String:
+		return at.Name + " " + name.Value, true
+	}
+	return "", false
+}
+
+// optimizableRule returns the declarations of n, if n is a style rule that can be merged
+// with others. Rules with nested rules are left alone.
+func optimizableRule(n ast.Node) (*ast.QualifiedRule, *ast.SelectorList, *ast.DeclarationBlock, bool) {
+	rule, ok := n.(*ast.QualifiedRule)
+	if !ok || hasNestedRules(rule) {
+		return nil, nil, nil, false
+	}
+
+	selList, ok := rule.Prelude.(*ast.SelectorList)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	block, ok := rule.Block.(*ast.DeclarationBlock)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	return rule, selList, block, true
+}
+
+// mergeAdjacentSelectors merges adjacent rules with the same selectors, e.g.
+// .a { color: red } .a { margin: 0 } becomes .a { color: red; margin: 0 }.
+func mergeAdjacentSelectors(nodes []ast.Node) []ast.Node {
+	rv := make([]ast.Node, 0, len(nodes))
+	for _, n := range nodes {
+		_, selList, block, ok := optimizableRule(n)
+		if !ok {
+			rv = append(rv, n)
+			continue
+		}
+
+		if len(rv) > 0 {
+			if _, prevSelList, prevBlock, ok := optimizableRule(rv[len(rv)-1]); ok && equalSelectors(prevSelList, selList) {
+				prevBlock.Declarations = append(prevBlock.Declarations, block.Declarations...)
+				prevBlock.Declarations = optimizeDeclarations(prevBlock.Declarations)
+				continue
+			}
+		}
+
+		block.Declarations = optimizeDeclarations(block.Declarations)
+		rv = append(rv, n)
+	}
+	return rv
+}
+
+// mergeAdjacentBlocks merges adjacent rules with the same declarations into one rule with
+// both selector lists, e.g. .a { color: red } .b { color: red } becomes .a, .b { color: red }.
+func mergeAdjacentBlocks(nodes []ast.Node) []ast.Node {
+	rv := make([]ast.Node, 0, len(nodes))
+	for _, n := range nodes {
+		_, selList, block, ok := optimizableRule(n)
+		if !ok || len(rv) == 0 {
+			rv = append(rv, n)
+			continue
+		}
+
+		prev, prevSelList, prevBlock, ok := optimizableRule(rv[len(rv)-1])
+		if !ok || !equalNodes(reflect.ValueOf(prevBlock.Declarations), reflect.ValueOf(block.Declarations)) || !canCombineSelectors(prevSelList) || !canCombineSelectors(selList) {
+			rv = append(rv, n)
+			continue
+		}
+
+		selectors := make([]*ast.Selector, 0, len(prevSelList.Selectors)+len(selList.Selectors))
+		selectors = append(selectors, prevSelList.Selectors...)
+	outer:
+		for _, s := range selList.Selectors {
+			for _, existing := range selectors {
+				if equalSelector(existing, s) {
+					continue outer
+				}
+			}
+			selectors = append(selectors, s)
+		}
+
+		rv[len(rv)-1] = &ast.QualifiedRule{
+			Span:    prev.Span,
+			Prelude: &ast.SelectorList{Span: prevSelList.Span, Selectors: selectors},
+			Block:   prev.Block,
+		}
+	}
+	return rv
+}
+
+func equalSelectors(a, b *ast.SelectorList) bool {
+	if len(a.Selectors) != len(b.Selectors) {
+		return false
+	}
+
+	for i := range a.Selectors {
+		if !equalSelector(a.Selectors[i], b.Selectors[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalSelector(a, b *ast.Selector) bool {
+	return equalNodes(reflect.ValueOf(trimWhitespace(a.Parts)), reflect.ValueOf(trimWhitespace(b.Parts)))
+}
+
+// canCombineSelectors returns whether or not the selectors in selList can be put in the same
+// list as other selectors. A browser drops a rule if it doesn't support one of its selectors,
+// so selectors that some browsers may not support can't be combined.
+func canCombineSelectors(selList *ast.SelectorList) bool {
+	for _, s := range selList.Selectors {
+		for _, part := range s.Parts {
+			switch p := part.(type) {
+			case *ast.PseudoClassSelector:
+				if !widelySupportedPseudoClasses[strings.ToLower(p.Name)] {
+					return false
+				}
+
+			case *ast.PseudoElementSelector:
+				switch p.Inner.Name {
+				case "before", "after", "first-line", "first-letter":
+				default:
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// widelySupportedPseudoClasses is the set of pseudo-classes from CSS 2.1 and Selectors Level 3
+// that every browser supports.
+var widelySupportedPseudoClasses = map[string]bool{
+	"link": true, "visited": true, "hover": true, "active": true, "focus": true,
+	"first-child": true, "lang": true, "last-child": true, "only-child": true,
+	"first-of-type": true, "last-of-type": true, "only-of-type": true, "nth-child": true,
+	"nth-last-child": true, "nth-of-type": true, "nth-last-of-type": true, "root": true,
+	"empty": true, "target": true, "enabled": true, "disabled": true, "checked": true,
+}
+
+// optimizeDeclarations removes declarations that are overridden by another declaration in
+// decls and collapses longhands into shorthands.
+func optimizeDeclarations(decls []ast.Declarationish) []ast.Declarationish {
+	return collapseShorthands(removeOverriddenDeclarations(decls))
+}
+
+// removeOverriddenDeclarations removes declarations that lose to a later or !important
+// declaration of the same property. Declarations that may be a fallback for browsers
+// that don't support the winning declaration, e.g. display: -webkit-box before display: flex,
+// or height: 100vh before height: 100dvh, are kept.
+func removeOverriddenDeclarations(decls []ast.Declarationish) []ast.Declarationish {
+	winners := make(map[string]*ast.Declaration)
+	for _, decl := range decls {
+		d, ok := decl.(*ast.Declaration)
+		if !ok {
+			continue
+		}
+
+		if winner, ok := winners[d.Property]; !ok || d.Important || !winner.Important {
+			winners[d.Property] = d
+		}
+	}
+
+	rv := make([]ast.Declarationish, 0, len(decls))
+	for _, decl := range decls {
+		d, ok := decl.(*ast.Declaration)
+		if !ok {
+			rv = append(rv, decl)
+			continue
+		}
+
+		winner := winners[d.Property]
+		if winner != d && (equalNodes(reflect.ValueOf(winner.Values), reflect.ValueOf(d.Values)) || !isFallback(d, winner)) {
+			continue
+		}
+		rv = append(rv, d)
+	}
+	return rv
+}
+
+// isFallback returns whether or not d might be used by browsers that don't support winner.
+// Only a winner that every browser supports is known to make d unused.
+func isFallback(d, winner *ast.Declaration) bool {
+	return hasVendorValues(d.Values) || !isWidelySupported(winner.Values)
+}
+
+// isWidelySupported returns whether or not every browser supports values, because they only
+// use units, keywords, and functions from CSS 2.1 or named colors.
+func isWidelySupported(values []ast.Value) bool {
+	for _, v := range values {
+		switch v := v.(type) {
+		case *ast.Dimension:
+			if !widelySupportedUnits[strings.ToLower(v.Unit)] {
+				return false
+			}
+
+		case *ast.String, *ast.Comma:
+
+		case *ast.HexColor:
+			if len(v.RGBA) != 3 && len(v.RGBA) != 6 {
+				return false
+			}
+
+		case *ast.Identifier:
+			name := strings.ToLower(v.Value)
+			if _, ok := color.Named(name); !ok && !widelySupportedKeywords[name] {
+				return false
+			}
+
+		case *ast.Function:
+			switch strings.ToLower(v.Name) {
+			case "url", "rgb", "rgba", "hsl", "hsla", "attr", "counter", "counters", "local", "format":
+				if !isWidelySupported(v.Arguments) {
+					return false
+				}
+			default:
+				return false
+			}
+
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// widelySupportedUnits is the set of units from CSS 2.1, plus rem. The empty unit is a number.
+var widelySupportedUnits = map[string]bool{
+	"": true, "%": true, "px": true, "em": true, "ex": true, "rem": true, "in": true, "cm": true, "mm": true,
+	"pt": true, "pc": true, "deg": true, "rad": true, "grad": true, "s": true, "ms": true,
+}
+
+// widelySupportedKeywords is the set of common keywords from CSS 2.1.
+var widelySupportedKeywords = map[string]bool{
+	"inherit": true, "auto": true, "none": true, "normal": true, "hidden": true, "visible": true,
+	"block": true, "inline": true, "inline-block": true, "list-item": true, "table": true,
+	"table-row": true, "table-cell": true, "static": true, "relative": true, "absolute": true,
+	"fixed": true, "left": true, "right": true, "center": true, "top": true, "bottom": true,
+	"middle": true, "justify": true, "both": true, "bold": true, "bolder": true, "lighter": true,
+	"italic": true, "oblique": true, "solid": true, "dashed": true, "dotted": true, "double": true,
+	"groove": true, "ridge": true, "inset": true, "outset": true, "thin": true, "medium": true,
+	"thick": true, "pointer": true, "default": true, "nowrap": true, "pre": true, "underline": true,
+	"overline": true, "line-through": true, "uppercase": true, "lowercase": true,
+	"capitalize": true, "collapse": true, "separate": true, "baseline": true, "sub": true,
+	"super": true, "text-top": true, "text-bottom": true, "repeat": true, "repeat-x": true,
+	"repeat-y": true, "no-repeat": true, "scroll": true, "serif": true, "sans-serif": true,
+	"monospace": true, "cursive": true, "fantasy": true,
+}
+
+func hasVendorValues(values []ast.Value) bool {
+	for _, v := range values {
+		switch v := v.(type) {
+		case *ast.Identifier:
+			if browsers.Vendor(v.Value) != "" {
+				return true
+			}
+
+		case *ast.Function:
+			if browsers.Vendor(v.Name) != "" || hasVendorValues(v.Arguments) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasNewerFunctions returns whether or not values use a function that isn't supported by
+// every browser, e.g. var() or calc().
+func hasNewerFunctions(values []ast.Value) bool {
+	for _, v := range values {
+		f, ok := v.(*ast.Function)
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(f.Name) {
+		case "url", "rgb", "rgba", "hsl", "hsla", "attr", "counter", "counters", "local", "format":
+			if hasNewerFunctions(f.Arguments) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// shorthand is a shorthand property for the four sides or corners of a box.
+type shorthand struct {
+	property string
+
+	// longhands are the properties for each side, in the order that the shorthand takes them.
+	longhands [4]string
+
+	// overlaps returns whether or not a property other than the longhands also sets one of them.
+	overlaps func(property string) bool
+}
+
+var shorthands = []shorthand{
+	{
+		property:  "margin",
+		longhands: [4]string{"margin-top", "margin-right", "margin-bottom", "margin-left"},
+		overlaps:  func(p string) bool { return strings.HasPrefix(p, "margin") },
+	},
+	{
+		property:  "padding",
+		longhands: [4]string{"padding-top", "padding-right", "padding-bottom", "padding-left"},
+		overlaps:  func(p string) bool { return strings.HasPrefix(p, "padding") },
+	},
+	borderShorthand("width"),
+	borderShorthand("style"),
+	borderShorthand("color"),
+	{
+		property:  "border-radius",
+		longhands: [4]string{"border-top-left-radius", "border-top-right-radius", "border-bottom-right-radius", "border-bottom-left-radius"},
+		overlaps:  func(p string) bool { return strings.HasPrefix(p, "border") && strings.HasSuffix(p, "radius") },
+	},
+}
+
+// borderShorthand returns the shorthand for one part of the border on each side, e.g. border-width.
+func borderShorthand(part string) shorthand {
+	return shorthand{
+		property:  "border-" + part,
+		longhands: [4]string{"border-top-" + part, "border-right-" + part, "border-bottom-" + part, "border-left-" + part},
+		overlaps: func(p string) bool {
+			switch p {
+			case "border", "border-top", "border-right", "border-bottom", "border-left":
+				return true
+			}
+			return strings.HasPrefix(p, "border-inline") || strings.HasPrefix(p, "border-block") || strings.HasPrefix(p, "border") && strings.HasSuffix(p, part)
+		},
+	}
+}
+
+// mightSet returns whether or not property might set properties other than itself that aren't
+// covered by a shorthand's overlaps, e.g. all or -webkit-margin-start.
+func mightSet(property string) bool {
+	if strings.HasPrefix(property, "--") {
+		return false
+	}
+	return strings.EqualFold(property, "all") || strings.HasPrefix(property, "-")
+}
+
+// collapseShorthands replaces the four longhands of a shorthand with the shorthand, e.g.
+// margin-top, margin-right, margin-bottom, and margin-left with margin. Longhands are only
+// collapsed if no other declaration sets them, so the order of the declarations doesn't matter.
+func collapseShorthands(decls []ast.Declarationish) []ast.Declarationish {
+	for _, s := range shorthands {
+		decls = s.collapse(decls)
+	}
+	return decls
+}
+
+func (s shorthand) collapse(decls []ast.Declarationish) []ast.Declarationish {
+	var sides [4]*ast.Declaration
+	first, last := -1, -1
+	for i, decl := range decls {
+		d, ok := decl.(*ast.Declaration)
+		if !ok || !s.overlaps(d.Property) {
+			continue
+		}
+
+		side := -1
+		for j, longhand := range s.longhands {
+			if d.Property == longhand {
+				side = j
+			}
+		}
+		if side < 0 || sides[side] != nil || len(d.Values) != 1 {
+			return decls
+		}
+
+		sides[side] = d
+		if first < 0 {
+			first = i
+		}
+		last = i
+	}
+
+	// The shorthand takes the place of the first longhand, so anything in between that might
+	// set the longhands would no longer be overridden by the later ones.
+	for i := first + 1; i < last; i++ {
+		d, ok := decls[i].(*ast.Declaration)
+		if !ok || !s.overlaps(d.Property) && mightSet(d.Property) {
+			return decls
+		}
+	}
+
+	for _, d := range sides {
+		if d == nil || d.Important != sides[0].Important || hasNewerFunctions(d.Values) {
+			return decls
+		}
+	}
+
+	values := []ast.Value{sides[0].Values[0], sides[1].Values[0], sides[2].Values[0], sides[3].Values[0]}
+	equal := func(a, b int) bool {
+		return equalNodes(reflect.ValueOf(values[a]), reflect.ValueOf(values[b]))
+	}
+
+	// CSS-wide keywords can't be combined with other values.
+	for _, v := range values {
+		if id, ok := v.(*ast.Identifier); ok && isCSSWideKeyword(id.Value) && !(equal(0, 1) && equal(0, 2) && equal(0, 3)) {
+			return decls
+		}
+	}
+	switch {
+	case equal(1, 3) && equal(0, 2) && equal(0, 1):
+		values = values[:1]
+	case equal(1, 3) && equal(0, 2):
+		values = values[:2]
+	case equal(1, 3):
+		values = values[:3]
+	}
+
+	rv := make([]ast.Declarationish, 0, len(decls)-3)
+	for i, decl := range decls {
+		if i == first {
+			rv = append(rv, &ast.Declaration{
+				Span:      sides[0].Span,
+				Property:  s.property,
+				Values:    values,
+				Important: sides[0].Important,
+			})
+			continue
+		}
+
+		if d, ok := decl.(*ast.Declaration); ok && s.overlaps(d.Property) {
+			continue
+		}
+		rv = append(rv, decl)
+	}
+	return rv
+}
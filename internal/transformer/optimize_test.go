@@ -0,0 +1,93 @@
+package transformer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stretchr/testify/assert"
+)
+
+func optimize(o *transformer.Options) {
+	o.Optimize = true
+}
+
+func TestOptimize_MergeSelectors(t *testing.T) {
+	assert.Equal(t, `.a{color:red;margin:0}`, Transform(t, optimize, `.a { color: red } .a { margin: 0 }`))
+	assert.Equal(t, `.a,.b{color:red;margin:0}`, Transform(t, optimize, `.a, .b { color: red } .a,.b { margin: 0 }`))
+	assert.Equal(t, `@media print{.a{color:red;margin:0}}`, Transform(t, optimize, `@media print { .a { color: red } .a { margin: 0 } }`))
+
+	// Rules that aren't next to each other can't be merged, since the rule between them could
+	// apply to the same elements.
+	assert.Equal(t, `.a{color:red}.b{color:blue}.a{color:green}`, Transform(t, optimize, `.a { color: red } .b { color: blue } .a { color: green }`))
+}
+
+func TestOptimize_MergeBlocks(t *testing.T) {
+	assert.Equal(t, `.a,.b{color:red}`, Transform(t, optimize, `.a { color: red } .b { color: red }`))
+	assert.Equal(t, `.a,.b,.c{color:red}`, Transform(t, optimize, `.a { color: red } .b { color: red } .c { color: red }`))
+	assert.Equal(t, `.a::before,.b:nth-child(2){color:red}`, Transform(t, optimize, `.a::before { color: red } .b:nth-child(2) { color: red }`))
+	assert.Equal(t, `.a{color:red}.b{color:blue}`, Transform(t, optimize, `.a { color: red } .b { color: blue }`))
+
+	// A browser drops the whole rule if it doesn't support one of the selectors.
+	assert.Equal(t, `.a{color:red}.b::-moz-selection{color:red}`, Transform(t, optimize, `.a { color: red } .b::-moz-selection { color: red }`))
+	assert.Equal(t, `.a{color:red}.b:is(.c){color:red}`, Transform(t, optimize, `.a { color: red } .b:is(.c) { color: red }`))
+	assert.Equal(t, `.a{color:red}.b::placeholder{color:red}`, Transform(t, optimize, `.a { color: red } .b::placeholder { color: red }`))
+	assert.Equal(t, `.a{color:red}.b:focus-visible{color:red}`, Transform(t, optimize, `.a { color: red } .b:focus-visible { color: red }`))
+	assert.Equal(t, `.a:hover,.b:first-child{color:red}`, Transform(t, optimize, `.a:hover { color: red } .b:first-child { color: red }`))
+}
+
+func TestOptimize_Declarations(t *testing.T) {
+	assert.Equal(t, `.a{color:blue}`, Transform(t, optimize, `.a { color: red; color: blue }`))
+	assert.Equal(t, `.a{color:red!important}`, Transform(t, optimize, `.a { color: red !important; color: blue }`))
+	assert.Equal(t, `.a{color:green!important}`, Transform(t, optimize, `.a { color: red !important; color: green !important; color: blue }`))
+	assert.Equal(t, `.a{margin:0;color:red}`, Transform(t, optimize, `.a { color: red; margin: 0; color: red }`))
+
+	// Declarations that are fallbacks for browsers that don't support a later one are kept.
+	assert.Equal(t, `.a{display:-webkit-box;display:flex}`, Transform(t, optimize, `.a { display: -webkit-box; display: flex }`))
+	assert.Equal(t, `.a{position:sticky;position:-webkit-sticky}`, Transform(t, optimize, `.a { position: sticky; position: -webkit-sticky }`))
+	assert.Equal(t, `.a{color:red;color:var(--color)}`, Transform(t, optimize, `.a { color: red; color: var(--color) }`))
+	assert.Equal(t, `.a{width:100px;width:calc(100% - 10px)}`, Transform(t, optimize, `.a { width: 100px; width: calc(100% - 10px) }`))
+	assert.Equal(t, `.a{height:100vh;height:100dvh}`, Transform(t, optimize, `.a { height: 100vh; height: 100dvh }`))
+	assert.Equal(t, `.a{display:block;display:contents}`, Transform(t, optimize, `.a { display: block; display: contents }`))
+	assert.Equal(t, `.a{color:red;color:#ff000080}`, Transform(t, optimize, `.a { color: red; color: #ff000080 }`))
+
+	// A value that every browser supports doesn't need a fallback.
+	assert.Equal(t, `.a{height:100px}`, Transform(t, optimize, `.a { height: 100vh; height: 100px }`))
+	assert.Equal(t, `.a{display:block}`, Transform(t, optimize, `.a { display: flex; display: block }`))
+}
+
+func TestOptimize_Shorthands(t *testing.T) {
+	assert.Equal(t, `.a{margin:1px 2px 3px 4px}`, Transform(t, optimize, `.a { margin-top: 1px; margin-right: 2px; margin-bottom: 3px; margin-left: 4px }`))
+	assert.Equal(t, `.a{color:red;padding:1px 2px 3px}`, Transform(t, optimize, `.a { color: red; padding-bottom: 3px; padding-left: 2px; padding-top: 1px; padding-right: 2px }`))
+	assert.Equal(t, `.a{margin:1px 2px}`, Transform(t, optimize, `.a { margin-top: 1px; margin-right: 2px; margin-bottom: 1px; margin-left: 2px }`))
+	assert.Equal(t, `.a{margin:0}`, Transform(t, optimize, `.a { margin-top: 0; margin-right: 0; margin-bottom: 0; margin-left: 0 }`))
+	assert.Equal(t, `.a{border-width:1px;border-style:solid;border-color:red blue}`, Transform(t, optimize, `.a {
+		border-top-width: 1px; border-right-width: 1px; border-bottom-width: 1px; border-left-width: 1px;
+		border-top-style: solid; border-right-style: solid; border-bottom-style: solid; border-left-style: solid;
+		border-top-color: red; border-right-color: blue; border-bottom-color: red; border-left-color: blue;
+	}`))
+	assert.Equal(t, `.a{border-radius:4px 4px 0 0}`, Transform(t, optimize, `.a { border-top-left-radius: 4px; border-top-right-radius: 4px; border-bottom-right-radius: 0; border-bottom-left-radius: 0 }`))
+	assert.Equal(t, `.a{margin:1px!important}`, Transform(t, optimize, `.a { margin-top: 1px !important; margin-right: 1px !important; margin-bottom: 1px !important; margin-left: 1px !important }`))
+	assert.Equal(t, `.a{margin:inherit}`, Transform(t, optimize, `.a { margin-top: inherit; margin-right: inherit; margin-bottom: inherit; margin-left: inherit }`))
+
+	// Longhands are left alone if collapsing them could change which value applies.
+	assert.Equal(t, `.a{margin-top:1px;margin-right:1px;margin-bottom:1px}`, Transform(t, optimize, `.a { margin-top: 1px; margin-right: 1px; margin-bottom: 1px }`))
+	assert.Equal(t, `.a{margin-top:1px!important;margin-right:1px;margin-bottom:1px;margin-left:1px}`, Transform(t, optimize, `.a { margin-top: 1px !important; margin-right: 1px; margin-bottom: 1px; margin-left: 1px }`))
+	assert.Equal(t, `.a{margin-top:1px;margin-right:1px;margin-bottom:1px;margin-left:1px;margin-inline:auto}`, Transform(t, optimize, `.a { margin-top: 1px; margin-right: 1px; margin-bottom: 1px; margin-left: 1px; margin-inline: auto }`))
+	assert.Equal(t, `.a{margin-top:inherit;margin-right:1px;margin-bottom:1px;margin-left:1px}`, Transform(t, optimize, `.a { margin-top: inherit; margin-right: 1px; margin-bottom: 1px; margin-left: 1px }`))
+	assert.Equal(t, `.a{margin-top:var(--x);margin-right:1px;margin-bottom:1px;margin-left:1px}`, Transform(t, optimize, `.a { margin-top: var(--x); margin-right: 1px; margin-bottom: 1px; margin-left: 1px }`))
+	assert.Equal(t, `.a{margin-top:1px;all:unset;margin-right:2px;margin-bottom:3px;margin-left:4px}`, Transform(t, optimize, `.a { margin-top: 1px; all: unset; margin-right: 2px; margin-bottom: 3px; margin-left: 4px }`))
+	assert.Equal(t, `.a{margin-top:1px;-webkit-margin-start:0;margin-right:2px;margin-bottom:3px;margin-left:4px}`, Transform(t, optimize, `.a { margin-top: 1px; -webkit-margin-start: 0; margin-right: 2px; margin-bottom: 3px; margin-left: 4px }`))
+	assert.Equal(t, `.a{all:unset;margin:1px 2px 3px 4px;color:red}`, Transform(t, optimize, `.a { all: unset; margin-top: 1px; margin-right: 2px; margin-bottom: 3px; margin-left: 4px; color: red }`))
+	assert.Equal(t, `.a{margin:1px 2px 3px 4px;--x:1px;color:red}`, Transform(t, optimize, `.a { margin-top: 1px; --x: 1px; color: red; margin-right: 2px; margin-bottom: 3px; margin-left: 4px }`))
+	assert.Equal(t, `.a{border-top-width:1px;border-right-width:1px;border-bottom-width:1px;border-left-width:1px;border-top:none}`, Transform(t, optimize, `.a { border-top-width: 1px; border-right-width: 1px; border-bottom-width: 1px; border-left-width: 1px; border-top: none }`))
+}
+
+func TestOptimize_Keyframes(t *testing.T) {
+	assert.Equal(t, `.a{color:red}@keyframes spin{to{transform:rotate(1turn)}}`, Transform(t, optimize, `@keyframes spin { from { opacity: 0 } } .a { color: red } @keyframes spin { to { transform: rotate(1turn) } }`))
+	assert.Equal(t, `@keyframes a{to{opacity:0}}@keyframes b{to{opacity:0}}`, Transform(t, optimize, `@keyframes a { to { opacity: 0 } } @keyframes b { to { opacity: 0 } }`))
+	assert.Equal(t, `@-webkit-keyframes a{to{opacity:0}}@keyframes a{to{opacity:0}}`, Transform(t, optimize, `@-webkit-keyframes a { to { opacity: 0 } } @keyframes a { to { opacity: 0 } }`))
+}
+
+func TestOptimize_Disabled(t *testing.T) {
+	assert.Equal(t, `.a{color:red}.a{color:red;color:red}`, Transform(t, nil, `.a { color: red } .a { color: red; color: red }`))
+}
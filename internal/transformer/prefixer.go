@@ -0,0 +1,384 @@
+package transformer
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/internal/browsers"
+	"github.com/stephen/cssc/transforms"
+)
+
+// vendors is the order that prefixed copies of a feature are added in.
+var vendors = []string{"-webkit-", "-moz-", "-ms-"}
+
+// prefixDeclaration returns d, preceded by any prefixed copies of it that the targets need,
+// e.g. -webkit-user-select: none for user-select: none. siblings is the rest of the block,
+// which is checked so that prefixed declarations that are already there are not repeated.
+// If d is itself a prefixed declaration that no target needs, nil is returned.
+func (t *transformer) prefixDeclaration(d *ast.Declaration, siblings []ast.Declarationish) []ast.Declarationish {
+	if t.Prefixer == transforms.PrefixerPassthrough || t.targets == nil {
+		return []ast.Declarationish{d}
+	}
+
+	property := d.Property
+	if feature, p, ok := browsers.Properties.Unprefixed(property); ok {
+		if !p.Needed(t.targets) {
+			return nil
+		}
+		property = feature
+	} else if vendor := browsers.Vendor(property); vendor != "" {
+		feature := strings.TrimPrefix(property, vendor)
+		for _, p := range keywordProperties(feature, d.Values) {
+			if p.Name != property {
+				continue
+			}
+			if !p.Needed(t.targets) {
+				return nil
+			}
+			property = feature
+		}
+	}
+
+	for _, v := range d.Values {
+		if t.isObsoleteValue(property, v) {
+			return nil
+		}
+	}
+
+	var rv []ast.Declarationish
+	add := func(prefixed *ast.Declaration) {
+		for _, s := range append(siblings, rv...) {
+			if s, ok := s.(*ast.Declaration); ok && s.Property == prefixed.Property && equalNodes(reflect.ValueOf(s.Values), reflect.ValueOf(prefixed.Values)) {
+				return
+			}
+		}
+		rv = append(rv, prefixed)
+	}
+
+	prefixes := browsers.Properties[d.Property]
+	if keywordPrefixes := keywordProperties(d.Property, d.Values); keywordPrefixes != nil {
+		prefixes = keywordPrefixes
+	}
+	for _, p := range prefixes {
+		if !p.Needed(t.targets) || hasProperty(siblings, p.Name) {
+			continue
+		}
+		add(&ast.Declaration{Span: d.Span, Property: p.Name, Values: cloneValues(d.Values), Important: d.Important})
+	}
+
+	for _, vendor := range vendors {
+		values, ok := t.prefixValues(property, d.Values, vendor)
+		if !ok {
+			continue
+		}
+		add(&ast.Declaration{Span: d.Span, Property: d.Property, Values: values, Important: d.Important})
+	}
+
+	return append(rv, d)
+}
+
+// keywordProperties returns the prefixed forms of property that are only used when its
+// value is a certain keyword, e.g. -webkit-background-clip for background-clip: text.
+func keywordProperties(property string, values []ast.Value) []browsers.Prefix {
+	if len(values) != 1 {
+		return nil
+	}
+
+	keyword, ok := values[0].(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	return browsers.KeywordProperties[property][keyword.Value]
+}
+
+// isObsoleteValue returns whether or not v is a prefixed keyword or function that no target needs.
+func (t *transformer) isObsoleteValue(property string, v ast.Value) bool {
+	switch v := v.(type) {
+	case *ast.Identifier:
+		if _, p, ok := browsers.Values[property].Unprefixed(v.Value); ok {
+			return !p.Needed(t.targets)
+		}
+
+	case *ast.Function:
+		if _, p, ok := browsers.Functions.Unprefixed(v.Name); ok && !p.Needed(t.targets) {
+			return true
+		}
+		for _, arg := range v.Arguments {
+			if t.isObsoleteValue(property, arg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prefixValues returns a copy of values with keywords and functions replaced by their
+// prefixed form for vendor, e.g. -webkit-sticky for sticky. ok is false if no values
+// need to be prefixed for vendor.
+func (t *transformer) prefixValues(property string, values []ast.Value, vendor string) (rv []ast.Value, ok bool) {
+	rv = make([]ast.Value, 0, len(values))
+	for _, v := range values {
+		switch v := v.(type) {
+		case *ast.Identifier:
+			if p, needed := t.neededPrefix(browsers.Values[property][v.Value], vendor); needed {
+				rv = append(rv, &ast.Identifier{Span: v.Span, Value: p.Name})
+				ok = true
+				continue
+			}
+
+		case *ast.Function:
+			args, prefixedArgs := t.prefixValues(property, v.Arguments, vendor)
+			p, needed := t.neededPrefix(browsers.Functions[v.Name], vendor)
+			if needed || prefixedArgs {
+				f := &ast.Function{Span: v.Span, Name: v.Name, Arguments: args}
+				if needed {
+					f.Name = p.Name
+				}
+				rv = append(rv, f)
+				ok = true
+				continue
+			}
+		}
+
+		rv = append(rv, ast.NewCloner().Node(v).(ast.Value))
+	}
+	return rv, ok
+}
+
+// neededPrefix returns the prefix in prefixes for vendor, if the targets need it.
+func (t *transformer) neededPrefix(prefixes []browsers.Prefix, vendor string) (browsers.Prefix, bool) {
+	for _, p := range prefixes {
+		if p.Vendor() == vendor && p.Needed(t.targets) {
+			return p, true
+		}
+	}
+	return browsers.Prefix{}, false
+}
+
+func hasProperty(decls []ast.Declarationish, property string) bool {
+	for _, decl := range decls {
+		if d, ok := decl.(*ast.Declaration); ok && d.Property == property {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneValues(values []ast.Value) []ast.Value {
+	c := ast.NewCloner()
+	rv := make([]ast.Value, 0, len(values))
+	for _, v := range values {
+		rv = append(rv, c.Node(v).(ast.Value))
+	}
+	return rv
+}
+
+// prefixRule returns rule, preceded by copies of it for each vendor whose prefixed pseudo
+// classes or elements the targets need, e.g. ::-moz-selection for ::selection. The copies
+// only have the selectors that were prefixed, since a browser drops a whole rule if it
+// doesn't recognize any of its selectors. Selectors with prefixes that no target needs
+// are removed, and if there are none left, nil is returned.
+func (t *transformer) prefixRule(rule *ast.QualifiedRule, selList *ast.SelectorList) []ast.Node {
+	if t.Prefixer == transforms.PrefixerPassthrough || t.targets == nil {
+		return []ast.Node{rule}
+	}
+
+	selectors := selList.Selectors[:0]
+	for _, s := range selList.Selectors {
+		if !t.hasObsoletePseudo(s) {
+			selectors = append(selectors, s)
+		}
+	}
+	selList.Selectors = selectors
+	if len(selectors) == 0 {
+		return nil
+	}
+
+	var rv []ast.Node
+	for _, vendor := range vendors {
+		var prefixed []*ast.Selector
+		for _, s := range selList.Selectors {
+			if p, ok := t.prefixSelector(s, vendor); ok {
+				prefixed = append(prefixed, p)
+			}
+		}
+		if len(prefixed) == 0 {
+			continue
+		}
+
+		rv = append(rv, &ast.QualifiedRule{
+			Span:    rule.Span,
+			Prelude: &ast.SelectorList{Span: selList.Span, Selectors: prefixed},
+			Block:   ast.NewCloner().Node(rule.Block).(ast.Block),
+		})
+	}
+	return append(rv, rule)
+}
+
+// pseudoName returns the name of a pseudo class or element and the table of its prefixes.
+func pseudoName(part ast.SelectorPart) (string, browsers.Table, bool) {
+	switch p := part.(type) {
+	case *ast.PseudoClassSelector:
+		return p.Name, browsers.PseudoClasses, true
+
+	case *ast.PseudoElementSelector:
+		return p.Inner.Name, browsers.PseudoElements, true
+	}
+	return "", nil, false
+}
+
+// hasObsoletePseudo returns whether or not s has a prefixed pseudo class or element
+// that no target needs.
+func (t *transformer) hasObsoletePseudo(s *ast.Selector) bool {
+	for _, part := range s.Parts {
+		name, table, ok := pseudoName(part)
+		if !ok {
+			continue
+		}
+
+		if _, p, ok := table.Unprefixed(name); ok && !p.Needed(t.targets) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixSelector returns a copy of s with its pseudo classes and elements replaced by the
+// prefixed forms for vendor. ok is false if s has no pseudos that need a prefix for vendor,
+// or if it has a pseudo that needs a prefix for a different vendor only.
+func (t *transformer) prefixSelector(s *ast.Selector, vendor string) (rv *ast.Selector, ok bool) {
+	rv = &ast.Selector{Span: s.Span, Parts: make([]ast.SelectorPart, 0, len(s.Parts))}
+	for _, part := range s.Parts {
+		name, table, isPseudo := pseudoName(part)
+		if !isPseudo || !anyNeeded(t.targets, table[name]) {
+			rv.Parts = append(rv.Parts, part)
+			continue
+		}
+
+		p, needed := t.neededPrefix(table[name], vendor)
+		if !needed {
+			return nil, false
+		}
+
+		switch part := part.(type) {
+		case *ast.PseudoClassSelector:
+			rv.Parts = append(rv.Parts, &ast.PseudoClassSelector{Span: part.Span, Name: p.Name, Arguments: part.Arguments})
+
+		case *ast.PseudoElementSelector:
+			rv.Parts = append(rv.Parts, &ast.PseudoElementSelector{Span: part.Span, Inner: &ast.PseudoClassSelector{Span: part.Inner.Span, Name: p.Name, Arguments: part.Inner.Arguments}})
+		}
+		ok = true
+	}
+	return rv, ok
+}
+
+func anyNeeded(targets browsers.Targets, prefixes []browsers.Prefix) bool {
+	for _, p := range prefixes {
+		if p.Needed(targets) {
+			return true
+		}
+	}
+	return false
+}
+
+// transformKeyframes transforms the declarations of each keyframe in a @keyframes rule.
+func (t *transformer) transformKeyframes(rule *ast.AtRule) {
+	block, ok := rule.Block.(*ast.QualifiedRuleBlock)
+	if !ok {
+		return
+	}
+
+	for _, n := range block.Rules {
+		keyframe, ok := n.(*ast.QualifiedRule)
+		if !ok {
+			continue
+		}
+
+		if decls, ok := keyframe.Block.(*ast.DeclarationBlock); ok {
+			decls.Declarations = t.transformDeclarations(decls.Declarations)
+		}
+	}
+}
+
+// prefixAtRule returns rule, preceded by prefixed copies of it that the targets need, e.g.
+// @-webkit-keyframes for @keyframes. Declarations with a different vendor's prefix are left
+// out of each copy. siblings is the list of nodes that rule is in, which is checked so that
+// prefixed rules that are already there are not repeated. If rule is itself a prefixed rule
+// that no target needs, nil is returned.
+func (t *transformer) prefixAtRule(rule *ast.AtRule, siblings []ast.Node) []ast.Node {
+	if t.Prefixer == transforms.PrefixerPassthrough || t.targets == nil {
+		return []ast.Node{rule}
+	}
+
+	if _, p, ok := browsers.AtRules.Unprefixed(rule.Name); ok {
+		if !p.Needed(t.targets) {
+			return nil
+		}
+		return []ast.Node{rule}
+	}
+
+	var rv []ast.Node
+outer:
+	for _, p := range browsers.AtRules[rule.Name] {
+		if !p.Needed(t.targets) {
+			continue
+		}
+
+		for _, s := range siblings {
+			if s, ok := s.(*ast.AtRule); ok && s.Name == p.Name && equalNodes(reflect.ValueOf(s.Preludes), reflect.ValueOf(rule.Preludes)) {
+				continue outer
+			}
+		}
+
+		prefixed := ast.NewCloner().Node(rule).(*ast.AtRule)
+		prefixed.Name = p.Name
+		removeOtherVendors(prefixed.Block, p.Vendor())
+		rv = append(rv, prefixed)
+	}
+	return append(rv, rule)
+}
+
+// removeOtherVendors removes declarations in block with a vendor prefix other than vendor.
+func removeOtherVendors(block ast.Block, vendor string) {
+	switch b := block.(type) {
+	case *ast.QualifiedRuleBlock:
+		for _, r := range b.Rules {
+			if r, ok := r.(*ast.QualifiedRule); ok {
+				removeOtherVendors(r.Block, vendor)
+			}
+		}
+
+	case *ast.DeclarationBlock:
+		decls := b.Declarations[:0]
+		for _, decl := range b.Declarations {
+			if d, ok := decl.(*ast.Declaration); ok && !hasVendor(d, vendor) {
+				continue
+			}
+			decls = append(decls, decl)
+		}
+		b.Declarations = decls
+	}
+}
+
+// hasVendor returns whether or not d is unprefixed or only uses vendor's prefix.
+func hasVendor(d *ast.Declaration, vendor string) bool {
+	if v := browsers.Vendor(d.Property); v != "" && v != vendor {
+		return false
+	}
+
+	for _, value := range d.Values {
+		switch value := value.(type) {
+		case *ast.Identifier:
+			if v := browsers.Vendor(value.Value); v != "" && v != vendor {
+				return false
+			}
+
+		case *ast.Function:
+			if v := browsers.Vendor(value.Name); v != "" && v != vendor {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package transformer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+)
+
+func prefix(targets string) func(*transformer.Options) {
+	return func(o *transformer.Options) {
+		o.Prefixer = transforms.PrefixerTransform
+		o.Targets = targets
+	}
+}
+
+func TestPrefixer_Properties(t *testing.T) {
+	assert.Equal(t, `.a{-webkit-user-select:none;-moz-user-select:none;user-select:none}`, Transform(t, prefix("chrome 50, firefox 60"), `.a { user-select: none }`))
+	assert.Equal(t, `.a{-webkit-user-select:none;user-select:none}`, Transform(t, prefix("chrome 100, safari 17"), `.a { user-select: none }`))
+	assert.Equal(t, `.a{user-select:none}`, Transform(t, prefix("chrome 100"), `.a { user-select: none }`))
+	assert.Equal(t, `.a{-webkit-backdrop-filter:blur(2px)!important;backdrop-filter:blur(2px)!important}`, Transform(t, prefix("safari 16"), `.a { backdrop-filter: blur(2px) !important }`))
+
+	// Existing prefixes are not repeated.
+	assert.Equal(t, `.a{-webkit-user-select:none;user-select:none}`, Transform(t, prefix("safari 17"), `.a { -webkit-user-select: none; user-select: none }`))
+
+	// Prefixes that no target needs are removed.
+	assert.Equal(t, `.a{transition:opacity 1s}`, Transform(t, prefix("chrome 100, firefox 100"), `.a { -webkit-transition: opacity 1s; -moz-transition: opacity 1s; transition: opacity 1s }`))
+	assert.Equal(t, `.a{-webkit-user-select:none;user-select:none}`, Transform(t, prefix("safari 17"), `.a { -webkit-user-select: none; -moz-user-select: none; user-select: none }`))
+
+	assert.Equal(t, `.a{border-radius:4px}`, Transform(t, prefix("chrome 100, firefox 100"), `.a { -webkit-border-radius: 4px; -moz-border-radius: 4px; border-radius: 4px }`))
+	assert.Equal(t, `.a{-ms-user-select:none;user-select:none}`, Transform(t, prefix("chrome 100, ie 11"), `.a { -ms-user-select: none; user-select: none }`))
+	assert.Equal(t, `.a{user-select:none}`, Transform(t, prefix("chrome 100"), `.a { -ms-user-select: none; user-select: none }`))
+
+	assert.Equal(t, `.a{-webkit-user-select:none;-moz-user-select:none;user-select:none}`, Transform(t, nil, `.a { -webkit-user-select: none; -moz-user-select: none; user-select: none }`))
+}
+
+func TestPrefixer_IE(t *testing.T) {
+	assert.Equal(t, `.a{-ms-user-select:none;user-select:none}`, Transform(t, prefix("ie 11"), `.a { user-select: none }`))
+	assert.Equal(t, `.a{-ms-hyphens:auto;hyphens:auto}`, Transform(t, prefix("ie 11"), `.a { hyphens: auto }`))
+	assert.Equal(t, `.a{-ms-transform:rotate(1deg);transform:rotate(1deg)}`, Transform(t, prefix("ie 9"), `.a { transform: rotate(1deg) }`))
+	assert.Equal(t, `.a{transform:rotate(1deg)}`, Transform(t, prefix("ie 11"), `.a { transform: rotate(1deg) }`))
+	assert.Equal(t,
+		`.a{display:-ms-grid;display:grid;-ms-grid-columns:1fr 2fr;grid-template-columns:1fr 2fr}`,
+		Transform(t, prefix("ie 11"), `.a { display: grid; grid-template-columns: 1fr 2fr }`),
+	)
+	assert.Equal(t, `.a{display:grid}`, Transform(t, prefix("edge 100"), `.a { display: -ms-grid; display: grid }`))
+}
+
+func TestPrefixer_KeywordProperties(t *testing.T) {
+	assert.Equal(t, `.a{-webkit-background-clip:text;background-clip:text}`, Transform(t, prefix("chrome 100"), `.a { background-clip: text }`))
+	assert.Equal(t, `.a{-webkit-background-clip:text;background-clip:text}`, Transform(t, prefix("safari 17"), `.a { -webkit-background-clip: text; background-clip: text }`))
+	assert.Equal(t, `.a{background-clip:padding-box}`, Transform(t, prefix("chrome 100"), `.a { background-clip: padding-box }`))
+
+	// The prefix is removed once no target needs it.
+	assert.Equal(t, `.a{background-clip:text}`, Transform(t, prefix("chrome 120, firefox 100"), `.a { -webkit-background-clip: text; background-clip: text }`))
+}
+
+func TestPrefixer_Values(t *testing.T) {
+	assert.Equal(t, `.a{position:-webkit-sticky;position:sticky}`, Transform(t, prefix("safari 12"), `.a { position: sticky }`))
+	assert.Equal(t, `.a{position:sticky}`, Transform(t, prefix("safari 13"), `.a { position: -webkit-sticky; position: sticky }`))
+	assert.Equal(t, `.a{width:-webkit-fit-content;width:-moz-fit-content;width:fit-content}`, Transform(t, prefix("safari 10, firefox 90"), `.a { width: fit-content }`))
+	assert.Equal(t, `.a{width:-moz-fit-content;width:fit-content}`, Transform(t, prefix("safari 11, firefox 90"), `.a { width: -moz-fit-content; width: fit-content }`))
+	assert.Equal(t, `.a{height:-webkit-fill-available;height:-moz-available;height:stretch}`, Transform(t, prefix("chrome 100, firefox 100"), `.a { height: stretch }`))
+	assert.Equal(t, `.a{background:-webkit-image-set(url(a.png) 1x,url(b.png) 2x) no-repeat;background:image-set(url(a.png) 1x,url(b.png) 2x) no-repeat}`, Transform(t, prefix("safari 13"), `.a { background: image-set(url(a.png) 1x, url(b.png) 2x) no-repeat }`))
+
+	// Keywords are only prefixed for the properties that use them.
+	assert.Equal(t, `.a{transition-property:flex}`, Transform(t, prefix("safari 8"), `.a { transition-property: flex }`))
+}
+
+func TestPrefixer_Selectors(t *testing.T) {
+	assert.Equal(t, `::-moz-selection{color:red}::selection{color:red}`, Transform(t, prefix("firefox 60"), `::selection { color: red }`))
+	assert.Equal(t, `::selection{color:red}`, Transform(t, prefix("firefox 100"), `::-moz-selection { color: red } ::selection { color: red }`))
+	assert.Equal(t, `.a,::selection{color:red}`, Transform(t, prefix("firefox 100"), `.a, ::-moz-selection, ::selection { color: red }`))
+	assert.Equal(t,
+		`input::-webkit-input-placeholder{color:gray}input::-moz-placeholder{color:gray}.a,input::placeholder{color:gray}`,
+		Transform(t, prefix("chrome 50, firefox 50"), `.a, input::placeholder { color: gray }`),
+	)
+	assert.Equal(t, `:-webkit-full-screen .a{color:red}:fullscreen .a{color:red}`, Transform(t, prefix("safari 16"), `:fullscreen .a { color: red }`))
+}
+
+func TestPrefixer_Keyframes(t *testing.T) {
+	assert.Equal(t,
+		`@-webkit-keyframes spin{from{-webkit-transform:rotate(0);transform:rotate(0)}to{-webkit-transform:rotate(360deg);transform:rotate(360deg)}}`+
+			`@-moz-keyframes spin{from{-moz-transform:rotate(0);transform:rotate(0)}to{-moz-transform:rotate(360deg);transform:rotate(360deg)}}`+
+			`@keyframes spin{from{-webkit-transform:rotate(0);-moz-transform:rotate(0);transform:rotate(0)}to{-webkit-transform:rotate(360deg);-moz-transform:rotate(360deg);transform:rotate(360deg)}}`,
+		Transform(t, prefix("safari 8, firefox 15"), `@keyframes spin { from { transform: rotate(0) } to { transform: rotate(360deg) } }`),
+	)
+	assert.Equal(t, `@keyframes spin{to{opacity:0}}`, Transform(t, prefix("last 2 versions"), `@-webkit-keyframes spin { to { opacity: 0 } } @-moz-keyframes spin { to { opacity: 0 } } @keyframes spin { to { opacity: 0 } }`))
+	assert.Equal(t, `@-webkit-keyframes spin{to{opacity:0}}@keyframes spin{to{opacity:0}}`, Transform(t, prefix("safari 8"), `@-webkit-keyframes spin { to { opacity: 0 } } @keyframes spin { to { opacity: 0 } }`))
+}
+
+func TestPrefixer_InvalidTargets(t *testing.T) {
+	assert.Panics(t, func() { Transform(t, prefix("netscape 4"), `.a { user-select: none }`) })
+	assert.Panics(t, func() { Transform(t, prefix(""), `.a { user-select: none }`) })
+}
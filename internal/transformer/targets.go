@@ -0,0 +1,81 @@
+package transformer
+
+import (
+	"github.com/stephen/cssc/internal/browsers"
+	"github.com/stephen/cssc/transforms"
+)
+
+// resolveTransforms chooses between passing through and transforming for each transform
+// that is set to auto. A feature is transformed if any browser in targets doesn't support
+// it. If targets is nil, every feature is passed through.
+func resolveTransforms(o transforms.Options, targets browsers.Targets) transforms.Options {
+	unsupported := func(f browsers.Feature) bool {
+		return targets != nil && !f.Supported(targets)
+	}
+
+	if o.MediaFeatureRanges == transforms.MediaFeatureRangesAuto {
+		o.MediaFeatureRanges = transforms.MediaFeatureRangesPassthrough
+		if unsupported(browsers.MediaFeatureRanges) {
+			o.MediaFeatureRanges = transforms.MediaFeatureRangesTransform
+		}
+	}
+
+	if o.AnyLink == transforms.AnyLinkAuto {
+		o.AnyLink = transforms.AnyLinkPassthrough
+		if unsupported(browsers.AnyLink) {
+			o.AnyLink = transforms.AnyLinkTransform
+		}
+	}
+
+	if o.CustomProperties == transforms.CustomPropertiesAuto {
+		o.CustomProperties = transforms.CustomPropertiesPassthrough
+		if unsupported(browsers.CustomProperties) {
+			// Keep var() for the browsers that do support custom properties.
+			o.CustomProperties = transforms.CustomPropertiesTransformPreserve
+		}
+	}
+
+	if o.CustomMediaQueries == transforms.CustomMediaQueriesAuto {
+		o.CustomMediaQueries = transforms.CustomMediaQueriesPassthrough
+		if unsupported(browsers.CustomMediaQueries) {
+			o.CustomMediaQueries = transforms.CustomMediaQueriesTransform
+		}
+	}
+
+	if o.CalcReduction == transforms.CalcReductionAuto {
+		o.CalcReduction = transforms.CalcReductionPassthrough
+		if unsupported(browsers.MathFunctions) {
+			o.CalcReduction = transforms.CalcReductionReduce
+		}
+	}
+
+	if o.Nesting == transforms.NestingAuto {
+		o.Nesting = transforms.NestingPassthrough
+		if unsupported(browsers.Nesting) {
+			o.Nesting = transforms.NestingTransform
+		}
+	}
+
+	if o.CascadeLayers == transforms.CascadeLayersAuto {
+		o.CascadeLayers = transforms.CascadeLayersPassthrough
+		if unsupported(browsers.CascadeLayers) {
+			o.CascadeLayers = transforms.CascadeLayersTransform
+		}
+	}
+
+	if o.ColorFunctions == transforms.ColorFunctionsAuto {
+		o.ColorFunctions = transforms.ColorFunctionsPassthrough
+		if unsupported(browsers.ColorFunctions) {
+			o.ColorFunctions = transforms.ColorFunctionsTransform
+		}
+	}
+
+	if o.Prefixer == transforms.PrefixerAuto {
+		o.Prefixer = transforms.PrefixerPassthrough
+		if targets != nil {
+			o.Prefixer = transforms.PrefixerTransform
+		}
+	}
+
+	return o
+}
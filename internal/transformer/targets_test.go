@@ -0,0 +1,46 @@
+package transformer_test
+
+import (
+	"testing"
+
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+)
+
+func targets(query string) func(*transformer.Options) {
+	return func(o *transformer.Options) {
+		o.Targets = query
+	}
+}
+
+func TestTargets(t *testing.T) {
+	const css = `.a:any-link { width: calc(1px + 2px) } @media (width >= 600px) { .b { color: oklch(62.8% 0.2577 29.23) } }`
+
+	assert.Equal(t, `.a:any-link{width:calc(1px + 2px)}@media (width>=600px){.b{color:oklch(62.8% 0.2577 29.23)}}`, Transform(t, nil, css))
+	assert.Equal(t, `.a:any-link{width:calc(1px + 2px)}@media (width>=600px){.b{color:oklch(62.8% 0.2577 29.23)}}`, Transform(t, targets("chrome 120, safari 17"), css))
+	assert.Equal(t, `.a:any-link{width:calc(1px + 2px)}@media (min-width:600px){.b{color:#ff0000}}`, Transform(t, targets("chrome 100, safari 17"), css))
+	assert.Equal(t, `.a:visited,.a:link{width:3px}@media (min-width:600px){.b{color:#ff0000}}`, Transform(t, targets("chrome 60"), css))
+}
+
+func TestTargets_Overrides(t *testing.T) {
+	assert.Equal(t, `.a:any-link{color:red}`, Transform(t, func(o *transformer.Options) {
+		o.Targets = "chrome 60"
+		o.AnyLink = transforms.AnyLinkPassthrough
+	}, `.a:any-link { color: red }`))
+
+	assert.Equal(t, `.a:visited,.a:link{color:red}`, Transform(t, func(o *transformer.Options) {
+		o.Targets = "chrome 120"
+		o.AnyLink = transforms.AnyLinkTransform
+	}, `.a:any-link { color: red }`))
+
+	assert.Equal(t, `.a{user-select:none}`, Transform(t, func(o *transformer.Options) {
+		o.Targets = "chrome 50"
+		o.Prefixer = transforms.PrefixerPassthrough
+	}, `.a { user-select: none }`))
+}
+
+func TestTargets_CustomProperties(t *testing.T) {
+	assert.Equal(t, `:root{--a:red}.a{color:red;color:var(--a)}`, Transform(t, targets("ie 11, chrome 100"), `:root { --a: red } .a { color: var(--a) }`))
+	assert.Equal(t, `.a{--b:1px}.a{width:var(--b)}`, Transform(t, targets("chrome 100"), `.a { --b: 1px } .a { width: var(--b) }`))
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/internal/browsers"
 	"github.com/stephen/cssc/internal/logging"
 	"github.com/stephen/cssc/internal/sources"
 	"github.com/stephen/cssc/transforms"
@@ -25,12 +26,35 @@ type Options struct {
 	transforms.Options
 
 	// ImportReplacements is the set of import references to inline. ImportReplacements must be non-nil
-	// if ImportRules is set to ImportRulesInline.
+	// if ImportRules is set to ImportRulesInline. Imports inside of replacements are inlined as well, so
+	// the replacements must not contain cycles. An import that is replaced with nil is removed.
 	ImportReplacements map[*ast.AtRule]*ast.Stylesheet
+
+	// ImportSources is the source for each stylesheet in ImportReplacements. It is used to report
+	// error locations inside of inlined content and to recognize a file that is imported more than once.
+	ImportSources map[*ast.Stylesheet]*sources.Source
+
+	// Minify rewrites values and selectors into their shortest equivalent forms, e.g. .5 for 0.50
+	// or red for #ff0000, and removes conditional rules that are empty.
+	Minify bool
+
+	// Optimize merges adjacent rules with the same selectors or declarations, removes overridden
+	// declarations and @keyframes, and collapses longhands into shorthands. Only changes that
+	// keep the same declarations applying to each element are made.
+	Optimize bool
+
+	// Modules, if non-nil, compiles the stylesheet as a CSS Module. See Modules.
+	Modules *Modules
+
+	// NodeSources, if non-nil, is filled in with the source of nodes that came from a different
+	// source than their parent, e.g. the rules of an inlined import. See printer.Options.NodeSources.
+	NodeSources map[ast.Node]*sources.Source
 }
 
 // Transform takes a pass over the input AST and runs various
-// transforms.
+// transforms. The transforms run on a copy of s and of each stylesheet in
+// ImportReplacements, so the same stylesheets can be transformed again, e.g. for
+// another output.
 func Transform(s *ast.Stylesheet, opts Options) *ast.Stylesheet {
 	t := &transformer{
 		Options: opts,
@@ -39,12 +63,31 @@ func Transform(s *ast.Stylesheet, opts Options) *ast.Stylesheet {
 	if opts.Reporter == nil {
 		t.Reporter = logging.DefaultReporter
 	}
+	s = t.cloneStylesheet(s)
+
+	if opts.Targets != "" {
+		targets, err := browsers.Parse(opts.Targets)
+		if err != nil {
+			t.Reporter.AddError(fmt.Errorf("invalid Targets: %w", err))
+		}
+		t.targets = targets
+	} else if opts.Prefixer == transforms.PrefixerTransform {
+		t.Reporter.AddError(fmt.Errorf("Prefixer is set to PrefixerTransform, but Targets is not set"))
+	}
+	t.Options.Options = resolveTransforms(opts.Options, t.targets)
 
-	if opts.CustomProperties != transforms.CustomPropertiesPassthrough {
+	switch t.CustomProperties {
+	case transforms.CustomPropertiesTransformRoot:
 		t.variables = make(map[string][]ast.Value)
+
+	case transforms.CustomPropertiesTransform, transforms.CustomPropertiesTransformPreserve:
+		t.customProperties = make(map[string]*customProperty)
+		t.collectedImports = make(map[*ast.Stylesheet]int)
+		t.collectCustomProperties(s.Nodes, t.OriginalSource, false)
+		t.findCustomPropertyCycles()
 	}
 
-	if opts.CustomMediaQueries != transforms.CustomMediaQueriesPassthrough {
+	if t.CustomMediaQueries != transforms.CustomMediaQueriesPassthrough {
 		t.customMedia = make(map[string]*ast.MediaQuery)
 	}
 
@@ -52,8 +95,33 @@ func Transform(s *ast.Stylesheet, opts Options) *ast.Stylesheet {
 		t.Reporter.AddError(fmt.Errorf("ImportRules is set to ImportRulesInline, but ImportReplacements is not set"))
 	}
 
+	if opts.ImportReplacements != nil {
+		t.lastImports = make(map[*ast.AtRule]bool)
+		t.findLastImports(s.Nodes, make(map[interface{}]bool))
+	}
+
+	if t.Modules != nil {
+		t.scopeStylesheet(s)
+	}
+
 	s.Nodes = t.transformNodes(s.Nodes)
 
+	if t.ImportReplacements != nil {
+		s.Nodes = hoistImports(s.Nodes)
+	}
+
+	if t.CascadeLayers == transforms.CascadeLayersTransform {
+		s.Nodes = t.flattenLayers(s.Nodes)
+	}
+
+	if t.Minify {
+		s.Nodes = t.minifyNodes(s.Nodes)
+	}
+
+	if t.Optimize {
+		s.Nodes = t.optimizeNodes(s.Nodes)
+	}
+
 	return s
 }
 
@@ -64,6 +132,50 @@ type transformer struct {
 
 	variables   map[string][]ast.Value
 	customMedia map[string]*ast.MediaQuery
+
+	// customProperties is the set of custom properties defined in the stylesheet, if custom
+	// properties are transformed beyond :root.
+	customProperties map[string]*customProperty
+
+	// collectedImports is the number of times that each imported stylesheet has been
+	// visited by collectCustomProperties.
+	collectedImports map[*ast.Stylesheet]int
+
+	// targets is the set of browsers to support, if Targets is set.
+	targets browsers.Targets
+
+	// selectors is the selector list of the qualified rule that the transformer is currently
+	// inside of, if any.
+	selectors *ast.SelectorList
+
+	// conditionDepth is the number of conditional group rules (e.g. @media) that
+	// the transformer is currently inside of.
+	conditionDepth int
+
+	// lastImports is the set of unconditional imports that are the last import of their
+	// file. Only the last import of a file is inlined, see findLastImports.
+	lastImports map[*ast.AtRule]bool
+
+	// importOriginals is the stylesheet in the original ImportReplacements that each copy
+	// was made from.
+	importOriginals map[*ast.Stylesheet]*ast.Stylesheet
+
+	// conditionalImportDepth is the number of conditional imports that the transformer
+	// is currently inlining.
+	conditionalImportDepth int
+
+	// localKeyframes is the set of @keyframes names that are local to the stylesheet, if it
+	// is compiled as a CSS Module.
+	localKeyframes map[string]bool
+
+	// localClasses is the set of class names that are local to the stylesheet, and compositions
+	// is the set of names that each of them composes, if it is compiled as a CSS Module.
+	localClasses map[string]bool
+	compositions map[string][]composition
+
+	// aliases is the set of values imported by the ICSS :import rules in the stylesheet that
+	// is being scoped, keyed by their alias.
+	aliases map[string]string
 }
 
 func (t *transformer) addError(loc ast.Node, fmt string, args ...interface{}) {
@@ -113,7 +225,18 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 	for _, value := range nodes {
 		switch node := value.(type) {
 		case *ast.QualifiedRule:
+			if t.Nesting == transforms.NestingTransform && hasNestedRules(node) {
+				rv = append(rv, t.transformNodes(t.lowerNesting(node))...)
+				continue
+			}
+
 			func() {
+				// Variables defined inside conditional rules only apply conditionally,
+				// so they can't be substituted.
+				if t.conditionDepth > 0 {
+					return
+				}
+
 				selList, ok := node.Prelude.(*ast.SelectorList)
 				if !ok {
 					return
@@ -167,20 +290,26 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 			selList, ok := node.Prelude.(*ast.SelectorList)
 			if !ok {
 				t.addError(node.Prelude, "expected selector list for qualified rule")
+				rv = append(rv, node)
+				continue
 			}
 			selList.Selectors = t.transformSelectors(selList.Selectors)
+			selectors := t.selectors
+			t.selectors = selList
 			node.Block = t.transformBlock(node.Block)
+			t.selectors = selectors
 
 			if node.Block == nil {
 				continue
 			}
-			rv = append(rv, node)
+			rv = append(rv, t.prefixRule(node, selList)...)
 
 		case *ast.AtRule:
 			switch node.Name {
 			case "import":
 				if t.ImportReplacements == nil {
 					rv = append(rv, node)
+					break
 				}
 
 				imported, ok := t.ImportReplacements[node]
@@ -188,12 +317,12 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 					rv = append(rv, node)
 					break
 				}
-
-				if len(node.Preludes) > 1 {
-					t.addWarn(node, "@import transform does not yet support @supports or media queries")
+				if imported == nil {
+					// The import is removed, e.g. because it would create a cycle.
+					break
 				}
 
-				rv = append(rv, t.transformNodes(imported.Nodes)...)
+				rv = append(rv, t.inlineImport(node, imported)...)
 
 			case "custom-media":
 				func() {
@@ -221,6 +350,31 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 			case "media":
 				mq := node.Preludes[0].(*ast.MediaQueryList)
 				mq.Queries = t.transformMediaQueries(mq.Queries)
+				t.transformConditionalBlock(node.Block)
+				rv = append(rv, node)
+
+			case "supports":
+				t.transformConditionalBlock(node.Block)
+				rv = append(rv, node)
+
+			case "container":
+				t.transformContainerRule(node)
+				t.transformConditionalBlock(node.Block)
+				rv = append(rv, node)
+
+			case "keyframes", "-webkit-keyframes", "-moz-keyframes", "-o-keyframes":
+				t.transformKeyframes(node)
+				rv = append(rv, t.prefixAtRule(node, nodes)...)
+
+			case "layer":
+				// Layers don't make rules conditional, so the block is transformed as if it were top-level.
+				switch b := node.Block.(type) {
+				case *ast.QualifiedRuleBlock:
+					b.Rules = t.transformNodes(b.Rules)
+
+				case *ast.DeclarationBlock:
+					b.Declarations = t.transformDeclarations(b.Declarations)
+				}
 				rv = append(rv, node)
 
 			default:
@@ -234,6 +388,270 @@ func (t *transformer) transformNodes(nodes []ast.Node) []ast.Node {
 	return rv
 }
 
+// inlineImport transforms the imported nodes and wraps them in blocks equivalent to
+// the @import's conditions, i.e. @import "a.css" layer(base) supports(display: grid) print
+// becomes @media print { @supports (display: grid) { @layer base { ... } } }.
+// See: https://www.w3.org/TR/css-cascade-5/#at-import.
+func (t *transformer) inlineImport(node *ast.AtRule, imported *ast.Stylesheet) []ast.Node {
+	var layer, supports, media *ast.AtRule
+	for _, prelude := range node.Preludes[1:] {
+		switch p := prelude.(type) {
+		case *ast.Identifier:
+			layer = &ast.AtRule{Span: node.Span, Name: "layer"}
+
+		case *ast.Function:
+			name := p.Arguments[0].(*ast.Identifier)
+			layer = &ast.AtRule{
+				Span:     node.Span,
+				Name:     "layer",
+				Preludes: []ast.AtPrelude{&ast.LayerList{Span: name.Span, Names: []*ast.Identifier{name}}},
+			}
+
+		case *ast.ImportSupports:
+			supports = &ast.AtRule{
+				Span:     node.Span,
+				Name:     "supports",
+				Preludes: []ast.AtPrelude{p.Condition},
+			}
+
+		case *ast.MediaQueryList:
+			p.Queries = t.transformMediaQueries(p.Queries)
+			media = &ast.AtRule{
+				Span:     node.Span,
+				Name:     "media",
+				Preludes: []ast.AtPrelude{p},
+			}
+		}
+	}
+
+	isUnconditional := len(node.Preludes) == 1
+	if isUnconditional && t.conditionalImportDepth == 0 {
+		// The last import of a file takes precedence over earlier ones, so earlier ones
+		// can be dropped. Imports inside of them won't be inlined either.
+		if !t.lastImports[node] {
+			return nil
+		}
+	}
+
+	if !isUnconditional {
+		t.conditionalImportDepth++
+	}
+	isConditional := supports != nil || media != nil
+	if isConditional {
+		t.conditionDepth++
+	}
+	imported = t.copyImport(imported)
+	originalSource := t.OriginalSource
+	if source, ok := t.ImportSources[imported]; ok {
+		t.OriginalSource = source
+	}
+	nodes := t.transformNodes(imported.Nodes)
+	t.OriginalSource = originalSource
+	if source, ok := t.ImportSources[imported]; ok && t.NodeSources != nil {
+		for _, n := range nodes {
+			// Nodes from nested imports are already marked with their own source.
+			if _, ok := t.NodeSources[n]; !ok {
+				t.NodeSources[n] = source
+			}
+		}
+	}
+	if isConditional {
+		t.conditionDepth--
+	}
+	if !isUnconditional {
+		t.conditionalImportDepth--
+	}
+
+	if layer != nil || supports != nil || media != nil {
+		for _, n := range nodes {
+			if at, ok := n.(*ast.AtRule); ok && at.Name == "import" {
+				source, ok := t.NodeSources[n]
+				if !ok {
+					source = t.ImportSources[imported]
+				}
+				if source == nil {
+					source = t.OriginalSource
+				}
+				t.Reporter.AddError(logging.LocationWarnf(source, at.Span, "@import rules that are not inlined can't be kept inside of a conditional import"))
+			}
+		}
+	}
+
+	for _, wrapper := range []*ast.AtRule{layer, supports, media} {
+		if wrapper == nil {
+			continue
+		}
+
+		wrapper.Block = &ast.QualifiedRuleBlock{Span: node.Span, Rules: nodes}
+		nodes = []ast.Node{wrapper}
+	}
+
+	return nodes
+}
+
+// hoistImports moves @import rules that were not inlined, e.g. external ones, above the
+// inlined rules, since browsers ignore @import rules that come after other rules.
+func hoistImports(nodes []ast.Node) []ast.Node {
+	// @charset and @layer statements can come before @import rules.
+	prefix := 0
+	for ; prefix < len(nodes); prefix++ {
+		at, ok := nodes[prefix].(*ast.AtRule)
+		if !ok || !(at.Name == "charset" || at.Name == "import" || at.Name == "layer" && at.Block == nil) {
+			break
+		}
+	}
+
+	var imports, rest []ast.Node
+	for _, n := range nodes[prefix:] {
+		if at, ok := n.(*ast.AtRule); ok && at.Name == "import" {
+			imports = append(imports, n)
+			continue
+		}
+		rest = append(rest, n)
+	}
+	if len(imports) == 0 {
+		return nodes
+	}
+
+	rv := make([]ast.Node, 0, len(nodes))
+	rv = append(rv, nodes[:prefix]...)
+	rv = append(rv, imports...)
+	return append(rv, rest...)
+}
+
+// inheritSource marks nodes with the source of parent, if it has one. It should be
+// called before nodes are moved out of parent.
+func (t *transformer) inheritSource(parent ast.Node, nodes []ast.Node) {
+	source, ok := t.NodeSources[parent]
+	if !ok {
+		return
+	}
+
+	for _, n := range nodes {
+		if _, ok := t.NodeSources[n]; !ok {
+			t.NodeSources[n] = source
+		}
+	}
+}
+
+// findLastImports adds the last unconditional import of each file in nodes to lastImports,
+// including imports inside of the inlined files. Files are visited from the end, so that
+// imports inside of earlier imports of a file, which are dropped, are skipped. seen is the
+// set of files that have already been visited.
+func (t *transformer) findLastImports(nodes []ast.Node, seen map[interface{}]bool) {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		at, ok := nodes[i].(*ast.AtRule)
+		if !ok || at.Name != "import" || len(at.Preludes) != 1 {
+			continue
+		}
+
+		imported, ok := t.ImportReplacements[at]
+		if !ok || imported == nil {
+			continue
+		}
+
+		key := t.importKey(imported)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		t.lastImports[at] = true
+		t.findLastImports(imported.Nodes, seen)
+	}
+}
+
+// importKey returns a key that identifies the file for an imported stylesheet.
+func (t *transformer) importKey(imported *ast.Stylesheet) interface{} {
+	if source, ok := t.ImportSources[imported]; ok {
+		return source
+	}
+	if original, ok := t.importOriginals[imported]; ok {
+		return original
+	}
+	return imported
+}
+
+// cloneStylesheet returns a copy of s and replaces ImportReplacements and ImportSources with
+// ones for copies of the imported stylesheets. Each imported stylesheet is copied once, even if
+// it is imported more than once. See copyImport for the copy that is made for each inlining.
+func (t *transformer) cloneStylesheet(s *ast.Stylesheet) *ast.Stylesheet {
+	replacements, importSources := t.ImportReplacements, t.ImportSources
+	if replacements != nil {
+		t.ImportReplacements = make(map[*ast.AtRule]*ast.Stylesheet)
+		t.ImportSources = make(map[*ast.Stylesheet]*sources.Source)
+		t.importOriginals = make(map[*ast.Stylesheet]*ast.Stylesheet)
+	}
+
+	clones := make(map[*ast.Stylesheet]*ast.Stylesheet)
+	var clone func(ss *ast.Stylesheet) *ast.Stylesheet
+	clone = func(ss *ast.Stylesheet) *ast.Stylesheet {
+		if copied, ok := clones[ss]; ok {
+			return copied
+		}
+
+		copied := ast.NewCloner().Stylesheet(ss)
+		clones[ss] = copied
+		for i, imp := range ss.Imports {
+			imported, ok := replacements[imp.AtRule]
+			if !ok {
+				continue
+			}
+			if imported == nil {
+				t.ImportReplacements[copied.Imports[i].AtRule] = nil
+				continue
+			}
+
+			copiedImport := clone(imported)
+			t.ImportReplacements[copied.Imports[i].AtRule] = copiedImport
+			t.importOriginals[copiedImport] = imported
+			if source, ok := importSources[imported]; ok {
+				t.ImportSources[copiedImport] = source
+			}
+		}
+		return copied
+	}
+	return clone(s)
+}
+
+// copyImport returns a copy of imported to be inlined. Transforms modify the nodes that they
+// inline, so a stylesheet that is inlined more than once is copied for each inlining. Only
+// imported itself is copied. The stylesheets that it imports are copied when they are inlined.
+func (t *transformer) copyImport(imported *ast.Stylesheet) *ast.Stylesheet {
+	c := ast.NewCloner()
+	copied := c.Stylesheet(imported)
+	for i, imp := range imported.Imports {
+		if nested, ok := t.ImportReplacements[imp.AtRule]; ok {
+			t.ImportReplacements[copied.Imports[i].AtRule] = nested
+		}
+		if t.lastImports[imp.AtRule] {
+			t.lastImports[copied.Imports[i].AtRule] = true
+		}
+	}
+
+	if original, ok := t.importOriginals[imported]; ok {
+		t.importOriginals[copied] = original
+	}
+	if source, ok := t.ImportSources[imported]; ok {
+		t.ImportSources[copied] = source
+	}
+	return copied
+}
+
+// transformConditionalBlock transforms the contents of a conditional group rule, e.g. @media.
+func (t *transformer) transformConditionalBlock(block ast.Block) {
+	switch b := block.(type) {
+	case *ast.QualifiedRuleBlock:
+		t.conditionDepth++
+		b.Rules = t.transformNodes(b.Rules)
+		t.conditionDepth--
+
+	case *ast.DeclarationBlock:
+		t.conditionDepth++
+		b.Declarations = t.transformDeclarations(b.Declarations)
+		t.conditionDepth--
+	}
+}
+
 func (t *transformer) transformMediaQueries(queries []*ast.MediaQuery) []*ast.MediaQuery {
 	newQueries := make([]*ast.MediaQuery, 0, len(queries))
 	for _, q := range queries {
@@ -326,11 +744,27 @@ func (t *transformer) transformMediaFeatureRange(part *ast.MediaFeatureRange) []
 }
 
 func (t *transformer) transformMediaQueryParts(parts []ast.MediaQueryPart) []ast.MediaQueryPart {
+	return t.transformMediaCondition(parts, true, false)
+}
+
+// transformMediaCondition expands custom media queries (if customMedia is set) and lowers
+// feature ranges in parts, including the ones in nested parenthesis. If group is set, a part
+// that is replaced by more than one part is wrapped in parenthesis so that it still
+// composes with the surrounding not, and and or.
+func (t *transformer) transformMediaCondition(parts []ast.MediaQueryPart, customMedia, group bool) []ast.MediaQueryPart {
 	newParts := make([]ast.MediaQueryPart, 0, len(parts))
+	add := func(p ast.MediaQueryPart, replacement []ast.MediaQueryPart) {
+		if group && len(parts) > 1 && len(replacement) > 1 {
+			newParts = append(newParts, &ast.MediaInParens{Span: p.Location(), Parts: replacement})
+			return
+		}
+		newParts = append(newParts, replacement...)
+	}
+
 	for _, p := range parts {
 		switch part := p.(type) {
 		case *ast.MediaFeaturePlain:
-			if part.Value != nil || !strings.HasPrefix(part.Property.Value, "--") {
+			if !customMedia || part.Value != nil || !strings.HasPrefix(part.Property.Value, "--") {
 				newParts = append(newParts, p)
 				break
 			}
@@ -346,10 +780,16 @@ func (t *transformer) transformMediaQueryParts(parts []ast.MediaQueryPart) []ast
 				break
 			}
 
-			newParts = append(newParts, replacement.Parts...)
+			add(p, replacement.Parts)
 
 		case *ast.MediaFeatureRange:
-			newParts = append(newParts, t.transformMediaFeatureRange(part)...)
+			add(p, t.transformMediaFeatureRange(part))
+
+		case *ast.MediaInParens:
+			newParts = append(newParts, &ast.MediaInParens{
+				Span:  part.Span,
+				Parts: t.transformMediaCondition(part.Parts, customMedia, true),
+			})
 
 		default:
 			newParts = append(newParts, p)
@@ -386,8 +826,25 @@ func (t *transformer) transformDeclarations(decls []ast.Declarationish) []ast.De
 	for _, decl := range decls {
 		switch d := decl.(type) {
 		case *ast.Declaration:
+			t.validateContainerDeclaration(d)
+			original := *d
+			substituted := t.substituteCustomProperties(d)
 			d.Values = t.transformValues(d.Values)
-			newDecls = append(newDecls, d)
+			newDecls = append(newDecls, t.prefixDeclaration(d, decls)...)
+
+			// Browsers that support custom properties use the last declaration.
+			if substituted && t.CustomProperties == transforms.CustomPropertiesTransformPreserve {
+				newDecls = append(newDecls, &original)
+			}
+
+		case *ast.QualifiedRule, *ast.AtRule:
+			// Nested rules are only left in the tree if nesting is not being lowered.
+			t.conditionDepth++
+			for _, n := range t.transformNodes([]ast.Node{d}) {
+				newDecls = append(newDecls, n.(ast.Declarationish))
+			}
+			t.conditionDepth--
+
 		default:
 			newDecls = append(newDecls, d)
 		}
@@ -397,90 +854,35 @@ func (t *transformer) transformDeclarations(decls []ast.Declarationish) []ast.De
 }
 
 func (t *transformer) transformValues(values []ast.Value) []ast.Value {
+	if t.variables != nil {
+		values = t.substituteRootVariables(values)
+	}
+
 	rv := make([]ast.Value, 0, len(values))
 	for _, value := range values {
 		switch v := value.(type) {
 		case *ast.Function:
-			newValues := []ast.Value{v}
-			func() {
-				if v.Name != "var" {
-					return
-				}
-
-				if t.variables == nil {
-					return
-				}
-
-				if len(v.Arguments) == 0 {
-					t.addError(v, "expected at least one argument to var()")
-					return
-				}
-
-				varName, ok := v.Arguments[0].(*ast.Identifier)
-				if !ok {
-					t.addError(v, "expected identifier as argument to var()")
-					return
-				}
-
-				vals, ok := t.variables[varName.Value]
-				if !ok {
-					// The first argument is the value, the second is a comma.
-					if len(v.Arguments) > 2 {
-						newValues = v.Arguments[2:]
-						return
-					}
-
-					t.addWarn(v, "use of undefined variable without fallback: %s", varName.Value)
-					return
-				}
-
-				newValues = vals
-			}()
-
-			func() {
-				if v.Name != "calc" {
-					return
-				}
-
-				if t.CalcReduction == transforms.CalcReductionPassthrough {
-					return
-				}
-
-				if len(v.Arguments) != 1 {
-					t.addWarn(v, "expected single argument for calc()")
-					return
-				}
-
-				args := t.transformValues([]ast.Value{v.Arguments[0]})
-				if len(args) != 1 {
-					t.addWarn(v, "expected single argument for calc()")
-					return
-				}
-
-				arg, ok := args[0].(*ast.MathExpression)
-				if !ok {
-					return
+			newValue := ast.Value(v)
+			if t.CalcReduction == transforms.CalcReductionReduce {
+				if v.IsMath() {
+					newValue = t.reduceMathFunction(v)
+				} else {
+					t.reduceMathArguments(v)
 				}
+			}
 
-				l, r := t.transformValues([]ast.Value{arg.Left}), t.transformValues([]ast.Value{arg.Right})
-				if len(l) != 1 {
-					t.addWarn(arg.Left, "expected left-hand side of math expression to be a single value")
-					return
-				}
-				if len(r) != 1 {
-					t.addWarn(arg.Right, "expected right-hand side of math expression to be a single value")
-					return
-				}
+			if t.ColorFunctions == transforms.ColorFunctionsTransform {
+				newValue = t.transformColorValues([]ast.Value{newValue})[0]
+			}
 
-				newValue := t.evaluateMathExpression(l[0], r[0], arg.Operator)
-				if newValue == nil {
-					return
-				}
+			rv = append(rv, newValue)
 
-				newValues = []ast.Value{newValue}
-			}()
-
-			rv = append(rv, newValues...)
+		case *ast.HexColor:
+			if t.ColorFunctions == transforms.ColorFunctionsTransform {
+				rv = append(rv, t.transformColor(v))
+				break
+			}
+			rv = append(rv, v)
 
 		default:
 			rv = append(rv, v)
@@ -490,157 +892,72 @@ func (t *transformer) transformValues(values []ast.Value) []ast.Value {
 	return rv
 }
 
-func (t *transformer) doMath(left, right, op string) (float64, error) {
-	leftValue, err := strconv.ParseFloat(left, 10)
-	if err != nil {
-		return 0, fmt.Errorf("could not parse dimension value: %s", left)
-	}
-
-	rightValue, err := strconv.ParseFloat(right, 10)
-	if err != nil {
-		return 0, fmt.Errorf("could not parse dimension value: %s", right)
-	}
-	switch op {
-	case "+":
-		return leftValue + rightValue, nil
-	case "-":
-		return leftValue - rightValue, nil
-	case "*":
-		return leftValue * rightValue, nil
-	case "/":
-		if rightValue == 0 {
-			return 0, fmt.Errorf("cannot divide by zero")
-		}
-		return leftValue / rightValue, nil
-	default:
-		return 0, fmt.Errorf("unknown op: %s", op)
+// substituteRootVariables replaces var() in values with the values of custom properties
+// from :root, including var() in the arguments of functions, e.g. calc(var(--x) * 2).
+func (t *transformer) substituteRootVariables(values []ast.Value) []ast.Value {
+	rv := make([]ast.Value, 0, len(values))
+	for _, value := range values {
+		rv = append(rv, t.substituteRootVariable(value)...)
 	}
+	return rv
 }
 
-// evaluateMathExpression attempts to add l + r. If sub is true, subtraction will be applied
-// instead. For sum to succeed, both l and r must be of the same type.
-// See notes from https://www.w3.org/TR/css-values-3/#calc-type-checking.
-func (t *transformer) evaluateMathExpression(l, r ast.Value, op string) ast.Value {
-	if expr, ok := l.(*ast.MathExpression); ok {
-		if evaluated := t.evaluateMathExpression(expr.Left, expr.Right, expr.Operator); evaluated != nil {
-			l = evaluated
-		}
-	}
-
-	if expr, ok := r.(*ast.MathExpression); ok {
-		if evaluated := t.evaluateMathExpression(expr.Left, expr.Right, expr.Operator); evaluated != nil {
-			r = evaluated
-		}
+// substituteSingleRootVariable is like substituteRootVariable, but for places where only
+// a single value is allowed, e.g. either side of a math expression.
+func (t *transformer) substituteSingleRootVariable(value ast.Value) ast.Value {
+	values := t.substituteRootVariable(value)
+	if len(values) != 1 {
+		return value
 	}
+	return values[0]
+}
 
-	switch op {
-	case "+", "-":
-		switch left := l.(type) {
-		case *ast.Dimension:
-			right, ok := r.(*ast.Dimension)
-			if !ok {
-				return nil
-			}
-
-			if left.Unit != right.Unit {
-				if left.Unit == "" && right.Unit != "" {
-					// Invalid, because we cannot mix number types and lengths, e.g. (2 + 5rem).
-					t.addError(left, "cannot add number type and %s type together", right.Unit)
-				}
-
-				if left.Unit != "" && right.Unit == "" {
-					// Invalid, because we cannot mix number types and lengths, e.g. (5rem + 2).
-					t.addError(left, "cannot add number type and %s type together", left.Unit)
-				}
-
-				// Valid css, but we cannot reduce (e.g. 2px + 3rem).
-				return nil
-			}
-
-			newValue, err := t.doMath(left.Value, right.Value, op)
-			if err != nil {
-				t.addError(l, err.Error())
-				return nil
-			}
-
-			return &ast.Dimension{
-				Value: strconv.FormatFloat(newValue, 'f', -1, 64),
-				Unit:  left.Unit,
-			}
-
-		// case *ast.MathExpression:
-		// if left.op is not + or -, return nil
-
-		// We know left.Right is an ast.Dimension because of the structure of the parse tree
-		// We know Right is an ast.Dimension
-		// If left.Right.Unit != right.Unit and left.Left.Unit != right.Unit, return nil
-		// left.Right.Unit case: doMath on left.Right.Value and right.Value and return left with Right = [the result of doMath]
-		// left.Left.Unit case: doMath on left.Left.Value and right.Value and return left with Left = [the result of doMath]
-
-		default:
-			t.addError(l, "cannot perform %s on this type", op)
-			return nil
-		}
-
-	case "*":
-		leftAsDimension, leftIsDimension := l.(*ast.Dimension)
-		rightAsDimension, rightIsDimension := r.(*ast.Dimension)
-		if !leftIsDimension || !rightIsDimension {
-			return nil
-		}
-
-		if leftAsDimension.Unit != "" && rightAsDimension.Unit != "" {
-			t.addError(l, "one side of multiplication must be a number (non-percentage/dimension)")
-			return nil
-		}
-
-		maybeWithUnit, number := leftAsDimension, rightAsDimension
-		if leftAsDimension.Unit == "" {
-			maybeWithUnit, number = rightAsDimension, leftAsDimension
-		}
-
-		// XXX: handle cases like calc(var(--x) * 2 * 2)
-
-		newValue, err := t.doMath(maybeWithUnit.Value, number.Value, op)
-		if err != nil {
-			t.addError(l, err.Error())
-			return nil
+func (t *transformer) substituteRootVariable(value ast.Value) []ast.Value {
+	switch v := value.(type) {
+	case *ast.Function:
+		if v.Name == "var" {
+			return t.rootVariable(v)
 		}
+		v.Arguments = t.substituteRootVariables(v.Arguments)
 
-		return &ast.Dimension{
-			Value: strconv.FormatFloat(newValue, 'f', -1, 64),
-			Unit:  maybeWithUnit.Unit,
-		}
+	case *ast.MathExpression:
+		v.Left = t.substituteSingleRootVariable(v.Left)
+		v.Right = t.substituteSingleRootVariable(v.Right)
 
-	case "/":
-		rightAsDimension, rightIsDimension := r.(*ast.Dimension)
-		if !rightIsDimension || rightAsDimension.Unit != "" {
-			t.addError(l, "right side of division must be a number (non-percentage/dimension)")
-			return nil
-		}
+	case *ast.MathParenthesizedExpression:
+		v.Value = t.substituteSingleRootVariable(v.Value)
 
-		switch left := l.(type) {
-		case *ast.Dimension:
-			newValue, err := t.doMath(left.Value, rightAsDimension.Value, op)
-			if err != nil {
-				t.addError(l, err.Error())
-				return nil
-			}
+	case *ast.Brackets:
+		v.Values = t.substituteRootVariables(v.Values)
+	}
+	return []ast.Value{value}
+}
 
-			return &ast.Dimension{
-				Value: strconv.FormatFloat(newValue, 'f', -1, 64),
-				Unit:  left.Unit,
-			}
+// rootVariable returns the value of the custom property from :root that v refers to, or its
+// fallback if there is no such custom property. The value is copied, since it can be used
+// more than once and later transforms change values in place.
+func (t *transformer) rootVariable(v *ast.Function) []ast.Value {
+	if len(v.Arguments) == 0 {
+		t.addError(v, "expected at least one argument to var()")
+		return []ast.Value{v}
+	}
 
-			// case *ast.MathExpression
+	varName, ok := v.Arguments[0].(*ast.Identifier)
+	if !ok {
+		t.addError(v, "expected identifier as argument to var()")
+		return []ast.Value{v}
+	}
 
-		default:
-			t.addError(l, "cannot perform %s on this type", op)
-			return nil
+	vals, ok := t.variables[varName.Value]
+	if !ok {
+		// The first argument is the value, the second is a comma.
+		if len(v.Arguments) > 2 {
+			return t.substituteRootVariables(v.Arguments[2:])
 		}
 
-	default:
-		t.addError(l, "unknown op: %s", op)
-		return nil
+		t.addWarn(v, "use of undefined variable without fallback: %s", varName.Value)
+		return []ast.Value{v}
 	}
+
+	return cloneValues(vals)
 }
@@ -0,0 +1,513 @@
+package transformer
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/internal/logging"
+	"github.com/stephen/cssc/internal/sources"
+)
+
+// customProperty is a custom property defined in the stylesheet.
+type customProperty struct {
+	// decl is the declaration that defines the property.
+	decl *ast.Declaration
+
+	// selectors is the selector list of the rule that decl is in.
+	selectors *ast.SelectorList
+
+	// source is the source that decl is in.
+	source *sources.Source
+
+	// unsafe is set if the value of the property can't be known ahead of time, e.g. because
+	// it is defined more than once or inside of a conditional rule.
+	unsafe bool
+
+	// cyclic is set if the property is part of a reference cycle.
+	cyclic bool
+
+	// resolution and resolved are the memoized result of resolveCustomProperty.
+	resolution resolution
+	resolved   []ast.Value
+}
+
+// resolution is the result of substituting var() references in a value.
+type resolution int
+
+const (
+	// unresolved is a custom property that hasn't been resolved yet.
+	unresolved resolution = iota
+
+	// resolving is a custom property that is currently being resolved.
+	resolving
+
+	// resolved is a value where every var() was substituted.
+	resolved
+
+	// unknown is a value with a var() whose value can't be known ahead of time. The var()
+	// is left as-is.
+	unknown
+
+	// invalid is a value with a var() that references an undefined or cyclic custom property
+	// without a fallback. Browsers treat the declaration as invalid at computed-value time.
+	invalid
+)
+
+// collectCustomProperties records the custom properties defined in nodes, including
+// those in inlined imports. conditional is set if nodes only apply under some condition.
+func (t *transformer) collectCustomProperties(nodes []ast.Node, source *sources.Source, conditional bool) {
+	for _, n := range nodes {
+		switch node := n.(type) {
+		case *ast.QualifiedRule:
+			selList, ok := node.Prelude.(*ast.SelectorList)
+			declBlock, isDeclBlock := node.Block.(*ast.DeclarationBlock)
+			if !ok || !isDeclBlock {
+				t.markUnsafe(node)
+				continue
+			}
+
+			for _, decl := range declBlock.Declarations {
+				switch d := decl.(type) {
+				case *ast.Declaration:
+					if !strings.HasPrefix(d.Property, "--") {
+						continue
+					}
+
+					if p, ok := t.customProperties[d.Property]; ok {
+						p.unsafe = true
+						continue
+					}
+
+					t.customProperties[d.Property] = &customProperty{
+						decl:      d,
+						selectors: selList,
+						source:    source,
+						unsafe:    conditional,
+					}
+
+				case *ast.QualifiedRule, *ast.AtRule:
+					// Nested rules may match elements that the parent's selector doesn't.
+					t.markUnsafe(d)
+				}
+			}
+
+		case *ast.AtRule:
+			block, ok := node.Block.(*ast.QualifiedRuleBlock)
+
+			switch {
+			case node.Name == "import":
+				imported, ok := t.ImportReplacements[node]
+				if !ok || imported == nil {
+					continue
+				}
+
+				// After a stylesheet is collected twice, every property in it is already unsafe,
+				// so shared imports don't need to be collected again.
+				if t.collectedImports[imported] >= 2 {
+					continue
+				}
+				t.collectedImports[imported]++
+
+				isConditional := conditional
+				for _, prelude := range node.Preludes[1:] {
+					switch prelude.(type) {
+					case *ast.ImportSupports, *ast.MediaQueryList:
+						isConditional = true
+					}
+				}
+
+				importSource := source
+				if s, ok := t.ImportSources[imported]; ok {
+					importSource = s
+				}
+				t.collectCustomProperties(imported.Nodes, importSource, isConditional)
+
+			case !ok:
+				t.markUnsafe(node)
+
+			case node.Name == "layer":
+				t.collectCustomProperties(block.Rules, source, conditional)
+
+			case isConditionalGroupRule(node.Name):
+				t.collectCustomProperties(block.Rules, source, true)
+
+			default:
+				t.markUnsafe(node)
+			}
+		}
+	}
+}
+
+// markUnsafe marks every custom property defined inside of n as unsafe.
+func (t *transformer) markUnsafe(n ast.Node) {
+	ast.Walk(n, func(n ast.Node) {
+		d, ok := n.(*ast.Declaration)
+		if !ok || !strings.HasPrefix(d.Property, "--") {
+			return
+		}
+
+		if p, ok := t.customProperties[d.Property]; ok {
+			p.unsafe = true
+			return
+		}
+		t.customProperties[d.Property] = &customProperty{decl: d, unsafe: true}
+	})
+}
+
+// findCustomPropertyCycles marks custom properties that reference each other in a cycle,
+// reporting a warning for each cycle.
+// See: https://www.w3.org/TR/css-variables-1/#cycles.
+func (t *transformer) findCustomPropertyCycles() {
+	names := make([]string, 0, len(t.customProperties))
+	for name := range t.customProperties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(names))
+	var stack []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+
+		p := t.customProperties[name]
+		for _, ref := range references(p.decl.Values) {
+			dep, ok := t.customProperties[ref]
+			if !ok || dep.unsafe || !covers(dep.selectors, p.selectors) {
+				continue
+			}
+
+			switch state[ref] {
+			case unvisited:
+				visit(ref)
+
+			case visiting:
+				var start int
+				for i, n := range stack {
+					if n == ref {
+						start = i
+						break
+					}
+				}
+
+				cycle := append(append([]string{}, stack[start:]...), ref)
+				for _, n := range cycle {
+					t.customProperties[n].cyclic = true
+				}
+				t.Reporter.AddError(logging.LocationWarnf(dep.source, dep.decl.Location(), "custom property cycle: %s", strings.Join(cycle, " -> ")))
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited && !t.customProperties[name].unsafe {
+			visit(name)
+		}
+	}
+}
+
+// references returns the names of the custom properties referenced by var() in values,
+// including var() inside of fallbacks.
+func references(values []ast.Value) []string {
+	var names []string
+	for _, v := range values {
+		ast.Walk(v, func(n ast.Node) {
+			f, ok := n.(*ast.Function)
+			if !ok || f.Name != "var" || len(f.Arguments) == 0 {
+				return
+			}
+
+			if name, ok := f.Arguments[0].(*ast.Identifier); ok {
+				names = append(names, name.Value)
+			}
+		})
+	}
+	return names
+}
+
+// resolveCustomProperty returns the value of a custom property with any var() references
+// substituted.
+func (t *transformer) resolveCustomProperty(p *customProperty) (resolution, []ast.Value) {
+	switch p.resolution {
+	case unresolved:
+	case resolving:
+		return unknown, nil
+	default:
+		return p.resolution, p.resolved
+	}
+
+	if p.cyclic {
+		p.resolution = invalid
+		return p.resolution, nil
+	}
+
+	p.resolution = resolving
+	p.resolution, p.resolved = t.substituteValues(p.decl.Values, p.selectors)
+	return p.resolution, p.resolved
+}
+
+// substituteValues replaces var() references in values with the values of custom properties
+// that are known for every element matched by selectors. The values are copied rather
+// than modified in place.
+func (t *transformer) substituteValues(values []ast.Value, selectors *ast.SelectorList) (resolution, []ast.Value) {
+	rv := make([]ast.Value, 0, len(values))
+	res := resolved
+	for _, value := range values {
+		r, newValues := t.substituteValue(value, selectors)
+		if r > res {
+			res = r
+		}
+		rv = append(rv, newValues...)
+	}
+	return res, rv
+}
+
+// substituteSingleValue is like substituteValue, but for places where only a single value
+// is allowed, e.g. either side of a math expression.
+func (t *transformer) substituteSingleValue(value ast.Value, selectors *ast.SelectorList) (resolution, ast.Value) {
+	res, values := t.substituteValue(value, selectors)
+	if len(values) != 1 {
+		return unknown, value
+	}
+	return res, values[0]
+}
+
+func (t *transformer) substituteValue(value ast.Value, selectors *ast.SelectorList) (resolution, []ast.Value) {
+	switch v := value.(type) {
+	case *ast.Function:
+		if v.Name == "var" {
+			return t.substituteVar(v, selectors)
+		}
+
+		res, args := t.substituteValues(v.Arguments, selectors)
+		f := *v
+		f.Arguments = args
+		return res, []ast.Value{&f}
+
+	case *ast.MathExpression:
+		leftRes, left := t.substituteSingleValue(v.Left, selectors)
+		rightRes, right := t.substituteSingleValue(v.Right, selectors)
+		res := leftRes
+		if rightRes > res {
+			res = rightRes
+		}
+
+		expr := *v
+		expr.Left, expr.Right = left, right
+		return res, []ast.Value{&expr}
+
+	case *ast.MathParenthesizedExpression:
+		res, inner := t.substituteSingleValue(v.Value, selectors)
+		expr := *v
+		expr.Value = inner
+		return res, []ast.Value{&expr}
+
+	case *ast.Brackets:
+		res, values := t.substituteValues(v.Values, selectors)
+		b := *v
+		b.Values = values
+		return res, []ast.Value{&b}
+
+	default:
+		return resolved, []ast.Value{value}
+	}
+}
+
+func (t *transformer) substituteVar(v *ast.Function, selectors *ast.SelectorList) (resolution, []ast.Value) {
+	if len(v.Arguments) == 0 {
+		t.addError(v, "expected at least one argument to var()")
+		return unknown, []ast.Value{v}
+	}
+
+	name, ok := v.Arguments[0].(*ast.Identifier)
+	if !ok {
+		t.addError(v, "expected identifier as argument to var()")
+		return unknown, []ast.Value{v}
+	}
+
+	// The first argument is the name, the second is a comma, and the rest is the fallback.
+	hasFallback := len(v.Arguments) > 1
+	var fallback []ast.Value
+	if hasFallback {
+		fallback = v.Arguments[2:]
+	}
+
+	res := invalid
+	var values []ast.Value
+	if p, ok := t.customProperties[name.Value]; ok {
+		res = unknown
+		if !p.unsafe && covers(p.selectors, selectors) {
+			res, values = t.resolveCustomProperty(p)
+		}
+	}
+
+	switch res {
+	case resolved:
+		return resolved, values
+
+	case invalid:
+		if hasFallback {
+			return t.substituteValues(fallback, selectors)
+		}
+
+		if _, ok := t.customProperties[name.Value]; !ok {
+			t.addWarn(v, "use of undefined variable without fallback: %s", name.Value)
+		}
+		return invalid, []ast.Value{v}
+
+	default:
+		// The var() is kept, but references in the fallback may still be known.
+		if !hasFallback {
+			return unknown, []ast.Value{v}
+		}
+
+		_, fallback = t.substituteValues(fallback, selectors)
+		f := *v
+		f.Arguments = append(append([]ast.Value{}, v.Arguments[:2]...), fallback...)
+		return unknown, []ast.Value{&f}
+	}
+}
+
+// covers returns whether or not every element matched by selectors is, or is a descendant
+// of, an element matched by def. A nil selectors is the declaration block of an at-rule, e.g.
+// @font-face, which is only covered by :root.
+func covers(def, selectors *ast.SelectorList) bool {
+	for _, d := range def.Selectors {
+		if parts := trimWhitespace(d.Parts); len(parts) == 1 {
+			if root, ok := parts[0].(*ast.PseudoClassSelector); ok && root.Name == "root" && root.Arguments == nil {
+				return true
+			}
+		}
+	}
+
+	if selectors == nil {
+		return false
+	}
+
+outer:
+	for _, s := range selectors.Selectors {
+		for _, d := range def.Selectors {
+			if coversSelector(d, s) {
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// coversSelector returns whether or not every element matched by s is, or is a descendant
+// of, an element matched by def. It only recognizes s starting with all of def, e.g. .a for
+// .a, .a.b, .a .b, .a > .b, and .a .b + .c.
+func coversSelector(def, s *ast.Selector) bool {
+	defParts, parts := trimWhitespace(def.Parts), trimWhitespace(s.Parts)
+	if len(parts) < len(defParts) {
+		return false
+	}
+
+	for i, part := range defParts {
+		if !equalNodes(reflect.ValueOf(part), reflect.ValueOf(parts[i])) {
+			return false
+		}
+	}
+
+	// Sibling combinators match elements outside of def, unless they follow a descendant
+	// or child combinator, e.g. .a .b ~ .c.
+	inside, whitespace, combinator := false, false, false
+	for _, part := range parts[len(defParts):] {
+		switch p := part.(type) {
+		case *ast.Whitespace:
+			whitespace = true
+			continue
+
+		case *ast.CombinatorSelector:
+			switch p.Operator {
+			case ">":
+				inside = true
+			case "+", "~":
+				if !inside {
+					return false
+				}
+			}
+			combinator = true
+
+		default:
+			// Whitespace between two compound selectors is a descendant combinator.
+			if whitespace && !combinator {
+				inside = true
+			}
+			combinator = false
+		}
+		whitespace = false
+	}
+	return true
+}
+
+// equalNodes returns whether or not a and b are the same, ignoring spans.
+func equalNodes(a, b reflect.Value) bool {
+	if a.Kind() != b.Kind() || a.Type() != b.Type() {
+		return false
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalNodes(a.Elem(), b.Elem())
+
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equalNodes(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Struct:
+		if a.Type() == reflect.TypeOf(ast.Span{}) {
+			return true
+		}
+		for i := 0; i < a.NumField(); i++ {
+			if !equalNodes(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return a.Interface() == b.Interface()
+	}
+}
+
+// substituteCustomProperties replaces var() references in the values of d, returning whether
+// or not any were replaced. Custom property definitions are left as-is.
+func (t *transformer) substituteCustomProperties(d *ast.Declaration) bool {
+	if t.customProperties == nil || strings.HasPrefix(d.Property, "--") || len(references(d.Values)) == 0 {
+		return false
+	}
+
+	// An invalid value can't be written without var(), so the declaration is left for the browser.
+	res, values := t.substituteValues(d.Values, t.selectors)
+	if res == invalid || equalNodes(reflect.ValueOf(values), reflect.ValueOf(d.Values)) {
+		return false
+	}
+
+	d.Values = values
+	return true
+}
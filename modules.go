@@ -0,0 +1,286 @@
+package cssc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/samsarahq/go/oops"
+	"github.com/stephen/cssc/internal/logging"
+	"github.com/stephen/cssc/internal/sources"
+	"github.com/stephen/cssc/internal/transformer"
+	"github.com/stephen/cssc/transforms"
+)
+
+// ModulesOptions is the set of options for compiling files as CSS Modules.
+// See: https://github.com/css-modules/css-modules.
+type ModulesOptions struct {
+	// Pattern is the pattern for the generated name of each class name, ID, and @keyframes
+	// name. [name] is replaced with the file name without its extension, [local] with the
+	// original name, and [hash] with a hash of the file path and the original name. If not
+	// specified, it is "[name]_[local]_[hash]".
+	Pattern string
+
+	// TypeScript adds a TypeScript declaration file for each module to Result.Files, next to
+	// its output, e.g. button.module.css.d.ts for button.module.css.
+	TypeScript bool
+
+	// GoPackage, if set, adds a Go source file with a constant for each export of each module
+	// to Result.Files, next to its output, e.g. button.module.css.go for button.module.css.
+	// The constants are named after the file and the export, e.g. ButtonPrimary for .primary.
+	GoPackage string
+
+	// Root is the directory that file paths are relative to in [hash], so that generated names
+	// don't depend on where the project is or where it is compiled from. If not specified,
+	// Options.Outbase is used, or else the lowest common ancestor directory of all entries.
+	Root string
+}
+
+const defaultModulesPattern = "[name]_[local]_[hash]"
+
+// pattern returns the pattern for generated names.
+func (o *ModulesOptions) pattern() string {
+	if o.Pattern == "" {
+		return defaultModulesPattern
+	}
+	return o.Pattern
+}
+
+// validate returns an error if the options can generate the same name for different local names.
+func (o *ModulesOptions) validate() error {
+	if p := o.pattern(); !strings.Contains(p, "[local]") && !strings.Contains(p, "[hash]") {
+		return oops.Errorf("Modules.Pattern must contain [local] or [hash], but it is %q", p)
+	}
+
+	if o.GoPackage != "" && !token.IsIdentifier(o.GoPackage) {
+		return oops.Errorf("Modules.GoPackage must be a Go identifier, but it is %q", o.GoPackage)
+	}
+	return nil
+}
+
+// root returns the absolute directory that paths are hashed relative to. See Root.
+func (o *ModulesOptions) root(opts Options, entries []string) (string, error) {
+	root := o.Root
+	if root == "" {
+		root = opts.Outbase
+	}
+	if root == "" {
+		return commonDir(entries)
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", oops.Wrapf(err, "failed to make path absolute: %s", root)
+	}
+	return abs, nil
+}
+
+// localName returns a function that returns the generated name of a local name in source.
+// Paths are hashed relative to root.
+func (o *ModulesOptions) localName(source *sources.Source, root string) func(local string) string {
+	path := source.Path
+	if rel, err := filepath.Rel(root, source.Path); err == nil {
+		path = rel
+	}
+	path = filepath.ToSlash(path)
+	name := moduleName(source.Path)
+
+	return func(local string) string {
+		hash := sha256.Sum256([]byte(path + "\x00" + local))
+		return identifier(strings.NewReplacer(
+			"[name]", name,
+			"[local]", local,
+			"[hash]", hex.EncodeToString(hash[:])[:8],
+		).Replace(o.pattern()))
+	}
+}
+
+// transformerModules returns the options to compile a file as a CSS Module with. Other
+// modules that it depends on are resolved relative to it.
+func (c *compilation) transformerModules(idx int) *transformer.Modules {
+	source := c.source(idx)
+	return &transformer.Modules{
+		LocalName: c.modules.localName(source, c.modulesRoot),
+		Dependency: func(specifier string) (map[string]string, error) {
+			rel, err := c.resolver.Resolve(specifier, filepath.Dir(source.Path))
+			if err != nil {
+				return nil, err
+			}
+
+			abs, err := filepath.Abs(rel)
+			if err != nil {
+				return nil, oops.Wrapf(err, "failed to make path absolute: %s", rel)
+			}
+
+			c.sourcesMu.RLock()
+			dep, ok := c.sources[abs]
+			c.sourcesMu.RUnlock()
+			if !ok {
+				return nil, oops.Errorf("%s was not loaded", displayPath(abs))
+			}
+			return c.moduleExports(dep)
+		},
+		Exports: make(map[string]string),
+	}
+}
+
+// moduleExports returns the exports of a file that another CSS Module depends on. Exports
+// are only computed once for each file, from its parsed stylesheet. Transforms run on copies
+// of it, so the exports don't depend on whether the file was already compiled as an output.
+func (c *compilation) moduleExports(idx int) (map[string]string, error) {
+	if exports, ok := c.exportsByIndex[idx]; ok {
+		return exports, nil
+	}
+
+	source, ss := c.source(idx), c.astsByIndex[idx]
+	if c.modulesPending[idx] {
+		return nil, oops.Errorf("%s depends on itself through composes or :import", displayPath(source.Path))
+	}
+	if ss == nil {
+		return nil, oops.Errorf("%s could not be parsed", displayPath(source.Path))
+	}
+
+	c.modulesPending[idx] = true
+	defer delete(c.modulesPending, idx)
+
+	opts := transformer.Options{
+		OriginalSource: source,
+		Modules:        c.transformerModules(idx),
+		Reporter: reporterFunc(func(err error) {
+			c.addError(logging.WithCode(logging.CodeTransform, err))
+		}),
+	}
+	if c.transforms.ImportRules == transforms.ImportRulesInline {
+		opts.ImportReplacements = c.imports
+		opts.ImportSources = c.importSources
+	}
+
+	exports := transformer.ModuleExports(ss, opts)
+	c.exportsByIndex[idx] = exports
+	return exports, nil
+}
+
+// moduleName returns the name of a file without its extension, e.g. button for
+// button.module.css.
+func moduleName(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.TrimSuffix(name, ".module")
+}
+
+// identifier returns s with characters that can't be written in an identifier without
+// escapes replaced by underscores.
+func identifier(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c >= 0x80) {
+			b[i] = '_'
+		}
+	}
+
+	if len(b) > 0 && (b[0] >= '0' && b[0] <= '9' || b[0] == '-' && (len(b) == 1 || b[1] >= '0' && b[1] <= '9')) {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// outputs returns the additional outputs for a module whose CSS is written to path, keyed
+// by their path.
+func (o *ModulesOptions) outputs(path string, exports map[string]string) (map[string]string, error) {
+	files := make(map[string]string)
+	if o.TypeScript {
+		files[path+".d.ts"] = typeScriptDeclarations(exports)
+	}
+
+	if o.GoPackage != "" {
+		out, err := goConstants(o.GoPackage, path, exports)
+		if err != nil {
+			return nil, err
+		}
+		files[path+".go"] = out
+	}
+	return files, nil
+}
+
+// sortedKeys returns the keys of exports in order.
+func sortedKeys(exports map[string]string) []string {
+	keys := make([]string, 0, len(exports))
+	for key := range exports {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// typeScriptDeclarations returns a TypeScript declaration file for the default export of a
+// module, which is an object with each of its exports.
+func typeScriptDeclarations(exports map[string]string) string {
+	var b strings.Builder
+	b.WriteString("declare const styles: {\n")
+	for _, key := range sortedKeys(exports) {
+		fmt.Fprintf(&b, "  readonly %s: string;\n", strconv.Quote(key))
+	}
+	b.WriteString("};\nexport default styles;\n")
+	return b.String()
+}
+
+// goConstants returns a Go source file with a constant for each export of the module whose
+// CSS is written to path.
+func goConstants(pkg, path string, exports map[string]string) (string, error) {
+	prefix := goName(moduleName(path))
+
+	var b strings.Builder
+	b.WriteString("// Code generated by cssc. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	fmt.Fprintf(&b, "// Exports of %s.\n", filepath.Base(path))
+	b.WriteString("const (\n")
+
+	names := make(map[string]string, len(exports))
+	for _, key := range sortedKeys(exports) {
+		name := prefix + goName(key)
+		if other, ok := names[name]; ok {
+			return "", oops.Errorf("%s and %s in %s both have the Go name %s", other, key, displayPath(path), name)
+		}
+		names[name] = key
+
+		fmt.Fprintf(&b, "\t%s = %s\n", name, strconv.Quote(exports[key]))
+	}
+	b.WriteString(")\n")
+
+	out, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return "", oops.Wrapf(err, "failed to format Go constants for %s", displayPath(path))
+	}
+	return string(out), nil
+}
+
+// goName returns s as an exported Go identifier, e.g. PrimaryButton for primary-button.
+func goName(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+
+	name := b.String()
+	if name == "" || !unicode.IsUpper([]rune(name)[0]) {
+		return "X" + name
+	}
+	return name
+}
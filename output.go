@@ -0,0 +1,151 @@
+package cssc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/samsarahq/go/oops"
+)
+
+// outputPaths decides where outputs are written to. See Options.Outdir,
+// Options.Outbase, and Options.Outfile.
+type outputPaths struct {
+	outdir, outbase, outfile string
+}
+
+func newOutputPaths(opts Options, entries []string) (*outputPaths, error) {
+	if opts.Outfile != "" && opts.Outdir != "" {
+		return nil, oops.Errorf("Outfile and Outdir cannot both be set")
+	}
+
+	if opts.Outdir != "" && opts.Stdin != nil && opts.Stdin.Sourcefile == "" {
+		return nil, oops.Errorf("Stdin.Sourcefile must be set to use Outdir with Stdin")
+	}
+
+	p := &outputPaths{}
+	if opts.Outfile != "" {
+		outfile, err := filepath.Abs(opts.Outfile)
+		if err != nil {
+			return nil, oops.Wrapf(err, "failed to make path absolute: %s", opts.Outfile)
+		}
+		p.outfile = outfile
+	}
+
+	if opts.Outdir == "" {
+		return p, nil
+	}
+
+	outdir, err := filepath.Abs(opts.Outdir)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to make path absolute: %s", opts.Outdir)
+	}
+	p.outdir = outdir
+
+	if opts.Outbase != "" {
+		outbase, err := filepath.Abs(opts.Outbase)
+		if err != nil {
+			return nil, oops.Wrapf(err, "failed to make path absolute: %s", opts.Outbase)
+		}
+		p.outbase = outbase
+		return p, nil
+	}
+
+	// Default to the lowest common ancestor of the entries, so that the output
+	// tree has no extra levels of nesting.
+	p.outbase, err = commonDir(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// commonDir returns the lowest common ancestor directory of paths.
+func commonDir(paths []string) (string, error) {
+	var common string
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", oops.Wrapf(err, "failed to make path absolute: %s", path)
+		}
+
+		dir := filepath.Dir(abs)
+		if i == 0 {
+			common = dir
+			continue
+		}
+
+		for !isInDir(dir, common) {
+			common = filepath.Dir(common)
+		}
+	}
+	return common, nil
+}
+
+// path returns the output path for an input file. If no output location was
+// set, the output path is the input path.
+func (p *outputPaths) path(input string) (string, error) {
+	if p.outfile != "" {
+		return p.outfile, nil
+	}
+
+	if p.outdir == "" {
+		return input, nil
+	}
+
+	if !isInDir(input, p.outbase) {
+		return "", oops.Errorf("%s is outside of the output base directory %s", input, p.outbase)
+	}
+
+	rel, err := filepath.Rel(p.outbase, input)
+	if err != nil {
+		return "", oops.Wrapf(err, "failed to make path relative to output base directory: %s", input)
+	}
+	return filepath.Join(p.outdir, rel), nil
+}
+
+// isInDir returns whether or not path is dir or inside of dir.
+func isInDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// WriteToDisk writes every file in the result to its path, creating
+// directories as needed. Nothing is written if an output would overwrite an
+// input, e.g. when neither Outdir nor Outfile is set.
+func (r *Result) WriteToDisk() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inputs := make(map[string]bool, len(r.Inputs))
+	for _, input := range r.Inputs {
+		inputs[input] = true
+	}
+
+	paths := make([]string, 0, len(r.Files))
+	for path := range r.Files {
+		if inputs[path] {
+			return oops.Errorf("refusing to overwrite input file %s; set Outdir or Outfile", displayPath(path))
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return oops.Wrapf(err, "failed to create output directory: %s", filepath.Dir(path))
+		}
+
+		if err := ioutil.WriteFile(path, []byte(r.Files[path]), 0644); err != nil {
+			return oops.Wrapf(err, "failed to write file: %s", path)
+		}
+	}
+
+	return nil
+}
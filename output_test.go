@@ -0,0 +1,205 @@
+package cssc_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func paths(t *testing.T, files map[string]string) []string {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	var rv []string
+	for path := range files {
+		rel, err := filepath.Rel(wd, path)
+		require.NoError(t, err)
+		rv = append(rv, filepath.ToSlash(rel))
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+func TestOutput_Default(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/simple/index.css"},
+		Reporter: &errors,
+	})
+
+	assert.Len(t, errors, 0)
+	assert.Equal(t, []string{"testdata/simple/index.css"}, paths(t, result.Files))
+}
+
+func TestOutput_Outdir(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/imports/index.css"},
+		Reporter: &errors,
+		Outdir:   "out",
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesFollow,
+		},
+	})
+
+	assert.Len(t, errors, 0)
+	assert.Equal(t, []string{"out/another.css", "out/index.css", "out/other.css"}, paths(t, result.Files))
+}
+
+func TestOutput_Outbase(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/imports/index.css", "testdata/simple/index.css"},
+		Reporter: &errors,
+		Outdir:   "out",
+	})
+
+	assert.Len(t, errors, 0)
+	assert.Equal(t, []string{"out/imports/index.css", "out/simple/index.css"}, paths(t, result.Files))
+
+	errors = nil
+	result = cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/imports/index.css"},
+		Reporter: &errors,
+		Outdir:   "out",
+		Outbase:  ".",
+	})
+
+	assert.Len(t, errors, 0)
+	assert.Equal(t, []string{"out/testdata/imports/index.css"}, paths(t, result.Files))
+
+	errors = nil
+	result = cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/imports/index.css"},
+		Reporter: &errors,
+		Outdir:   "out",
+		Outbase:  "testdata/simple",
+	})
+
+	assert.Len(t, errors, 1)
+	assert.Len(t, result.Files, 0)
+}
+
+func TestOutput_Outfile(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/imports/index.css"},
+		Reporter: &errors,
+		Outfile:  "out/bundle.css",
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+
+	assert.Len(t, errors, 0)
+	assert.Equal(t, []string{"out/bundle.css"}, paths(t, result.Files))
+
+	errors = nil
+	result = cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/imports/index.css"},
+		Reporter: &errors,
+		Outfile:  "out/bundle.css",
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesFollow,
+		},
+	})
+
+	assert.Len(t, errors, 1)
+	assert.Len(t, result.Files, 0)
+
+	errors = nil
+	result = cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/imports/index.css"},
+		Reporter: &errors,
+		Outfile:  "out/bundle.css",
+		Outdir:   "out",
+	})
+
+	assert.Len(t, errors, 1)
+	assert.Len(t, result.Files, 0)
+}
+
+func TestOutput_Stdin(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Stdin:    &cssc.StdinOptions{Contents: ".a { color: red }"},
+		Reporter: &errors,
+		Outdir:   "out",
+	})
+
+	require.Len(t, errors, 1)
+	assert.Equal(t, cssc.CodeOptions, result.Errors[0].Code)
+	assert.Len(t, result.Files, 0)
+
+	errors = nil
+	result = cssc.Compile(cssc.Options{
+		Stdin:    &cssc.StdinOptions{Contents: ".a { color: red }", Sourcefile: "testdata/stdin.css"},
+		Reporter: &errors,
+		Outdir:   "out",
+	})
+
+	assert.Len(t, errors, 0)
+	assert.Equal(t, []string{"out/stdin.css"}, paths(t, result.Files))
+
+	errors = nil
+	result = cssc.Compile(cssc.Options{
+		Stdin:    &cssc.StdinOptions{Contents: ".a { color: red }"},
+		Reporter: &errors,
+		Outfile:  "out/bundle.css",
+	})
+
+	assert.Len(t, errors, 0)
+	assert.Equal(t, []string{"out/bundle.css"}, paths(t, result.Files))
+}
+
+func TestOutput_WriteToDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cssc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/imports/index.css"},
+		Reporter: &errors,
+		Outdir:   filepath.Join(dir, "nested"),
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesFollow,
+		},
+	})
+	require.Len(t, errors, 0)
+	require.NoError(t, result.WriteToDisk())
+
+	for path, content := range result.Files {
+		written, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(written))
+	}
+	assert.FileExists(t, filepath.Join(dir, "nested", "other.css"))
+}
+
+func TestOutput_WriteToDiskInputs(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:    []string{"testdata/imports/index.css"},
+		Reporter: &errors,
+	})
+	require.Len(t, errors, 0)
+
+	before, err := ioutil.ReadFile("testdata/imports/index.css")
+	require.NoError(t, err)
+
+	err = result.WriteToDisk()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refusing to overwrite input file testdata/imports/index.css")
+
+	after, err := ioutil.ReadFile("testdata/imports/index.css")
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+}
@@ -1,8 +1,16 @@
 package cssc
 
+import "errors"
+
 // Resolver implements a method of resolving an import spec (e.g. @import "test.css")
 // into a path on the filesystem.
 type Resolver interface {
 	// Resolve spec relative to from.
 	Resolve(spec, fromDir string) (path string, err error)
 }
+
+// ErrExternal is returned by a Resolver for an import that should be left as-is in the
+// output instead of being followed, e.g. a stylesheet that is served separately. When
+// imports are inlined, external @import rules are moved above the inlined rules, since
+// browsers ignore @import rules that come after other rules.
+var ErrExternal = errors.New("import is external")
@@ -0,0 +1,29 @@
+package cssc
+
+// SourceMap controls whether and how source maps are generated for outputs.
+type SourceMap int
+
+const (
+	// SourceMapNone does not generate source maps. It is the default.
+	SourceMapNone SourceMap = iota
+	// SourceMapInline appends the source map to each output as a base64 data URL.
+	SourceMapInline
+	// SourceMapExternal adds the source map for each output to the result as a .map file
+	// next to the output, and links to it from the output.
+	SourceMapExternal
+	// SourceMapHidden is like SourceMapExternal, but does not link to the source map
+	// from the output.
+	SourceMapHidden
+)
+
+// SourcesContent controls whether or not source maps contain the content of
+// the original sources.
+type SourcesContent int
+
+const (
+	// SourcesContentInclude includes the original sources in source maps. It is the default.
+	SourcesContentInclude SourcesContent = iota
+	// SourcesContentExclude leaves the original sources out of source maps. Tools that
+	// read the source map need access to the original files instead.
+	SourcesContentExclude
+)
@@ -0,0 +1,206 @@
+package cssc_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/internal/printer"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sourceMap struct {
+	Version        int      `json:"version"`
+	File           string   `json:"file"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent"`
+	Mappings       string   `json:"mappings"`
+}
+
+func compileWithSourceMap(t *testing.T, mode cssc.SourceMap, content cssc.SourcesContent) map[string]string {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:          []string{"testdata/simple/index.css"},
+		Reporter:       &errors,
+		Outdir:         "out",
+		SourceMap:      mode,
+		SourcesContent: content,
+	})
+	require.Len(t, errors, 0)
+
+	files := make(map[string]string)
+	for path, content := range result.Files {
+		files[filepath.Base(path)] = content
+	}
+	return files
+}
+
+func TestSourceMap_None(t *testing.T) {
+	files := compileWithSourceMap(t, cssc.SourceMapNone, cssc.SourcesContentInclude)
+	require.Len(t, files, 1)
+	assert.NotContains(t, files["index.css"], "sourceMappingURL")
+}
+
+func TestSourceMap_Inline(t *testing.T) {
+	files := compileWithSourceMap(t, cssc.SourceMapInline, cssc.SourcesContentInclude)
+	require.Len(t, files, 1)
+
+	const prefix = "/*# sourceMappingURL=data:application/json;base64,"
+	out := files["index.css"]
+	i := strings.Index(out, prefix)
+	require.NotEqual(t, -1, i, out)
+
+	encoded := strings.TrimSuffix(out[i+len(prefix):], " */\n")
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+
+	var m sourceMap
+	require.NoError(t, json.Unmarshal(decoded, &m))
+	assert.Equal(t, 3, m.Version)
+	assert.Equal(t, "index.css", m.File)
+	assert.Equal(t, []string{"../testdata/simple/index.css"}, m.Sources)
+	assert.Len(t, m.SourcesContent, 1)
+	assert.NotEmpty(t, m.Mappings)
+}
+
+func TestSourceMap_External(t *testing.T) {
+	files := compileWithSourceMap(t, cssc.SourceMapExternal, cssc.SourcesContentInclude)
+	require.Len(t, files, 2)
+	assert.True(t, strings.HasSuffix(files["index.css"], "\n/*# sourceMappingURL=index.css.map */\n"), files["index.css"])
+
+	var m sourceMap
+	require.NoError(t, json.Unmarshal([]byte(files["index.css.map"]), &m))
+	assert.Equal(t, "index.css", m.File)
+	assert.Equal(t, []string{"../testdata/simple/index.css"}, m.Sources)
+	assert.Len(t, m.SourcesContent, 1)
+}
+
+func TestSourceMap_Hidden(t *testing.T) {
+	files := compileWithSourceMap(t, cssc.SourceMapHidden, cssc.SourcesContentExclude)
+	require.Len(t, files, 2)
+	assert.NotContains(t, files["index.css"], "sourceMappingURL")
+
+	var m sourceMap
+	require.NoError(t, json.Unmarshal([]byte(files["index.css.map"]), &m))
+	assert.Equal(t, []string{"../testdata/simple/index.css"}, m.Sources)
+	assert.Nil(t, m.SourcesContent)
+	assert.NotContains(t, files["index.css.map"], "sourcesContent")
+}
+
+// mapping is a decoded source map segment.
+type mapping struct {
+	generatedColumn, source, originalLine int32
+}
+
+// decodeMappings decodes the mappings of a source map for a single line of output.
+func decodeMappings(t *testing.T, mappings string) []mapping {
+	require.NotContains(t, mappings, ";")
+
+	var rv []mapping
+	var state mapping
+	for _, segment := range strings.Split(mappings, ",") {
+		var fields []int32
+		for in := []byte(segment); len(in) > 0; {
+			value, n := printer.VLQDecode(in)
+			fields = append(fields, value)
+			in = in[n:]
+		}
+		require.Len(t, fields, 4)
+
+		state.generatedColumn += fields[0]
+		state.source += fields[1]
+		state.originalLine += fields[2]
+		rv = append(rv, state)
+	}
+	return rv
+}
+
+// sourceAt returns the source and original line for the rule that starts with prefix.
+func sourceAt(t *testing.T, out string, m sourceMap, prefix string) (string, int32) {
+	col := int32(strings.Index(out, prefix))
+	require.NotEqual(t, int32(-1), col, "%s not in %s", prefix, out)
+
+	for _, segment := range decodeMappings(t, m.Mappings) {
+		if segment.generatedColumn == col {
+			return m.Sources[segment.source], segment.originalLine
+		}
+	}
+
+	require.FailNow(t, "no mapping for "+prefix)
+	return "", 0
+}
+
+func TestSourceMap_Imports(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:     []string{"testdata/imports/index.css"},
+		Reporter:  &errors,
+		Outdir:    "out",
+		SourceMap: cssc.SourceMapHidden,
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+	})
+	require.Len(t, errors, 0)
+
+	files := make(map[string]string)
+	for path, content := range result.Files {
+		files[filepath.Base(path)] = content
+	}
+
+	var m sourceMap
+	require.NoError(t, json.Unmarshal([]byte(files["index.css.map"]), &m))
+	assert.Len(t, m.Sources, 3)
+	assert.Len(t, m.SourcesContent, 3)
+
+	out := files["index.css"]
+	for _, c := range []struct {
+		prefix, source string
+		line           int32
+	}{
+		{".other{", "../testdata/imports/other.css", 0},
+		{".another{", "../testdata/imports/another.css", 0},
+		{"div{", "../testdata/imports/index.css", 2},
+		{"body{", "../testdata/imports/index.css", 6},
+	} {
+		source, line := sourceAt(t, out, m, c.prefix)
+		assert.Equal(t, c.source, source, c.prefix)
+		assert.Equal(t, c.line, line, c.prefix)
+	}
+}
+
+func TestSourceMap_ImportsWithLayers(t *testing.T) {
+	var errors TestReporter
+	result := cssc.Compile(cssc.Options{
+		Entry:     []string{"testdata/layers/index.css"},
+		Reporter:  &errors,
+		Outdir:    "out",
+		SourceMap: cssc.SourceMapHidden,
+		Transforms: transforms.Options{
+			ImportRules:   transforms.ImportRulesInline,
+			CascadeLayers: transforms.CascadeLayersTransform,
+		},
+	})
+	require.Len(t, errors, 0)
+
+	files := make(map[string]string)
+	for path, content := range result.Files {
+		files[filepath.Base(path)] = content
+	}
+
+	var m sourceMap
+	require.NoError(t, json.Unmarshal([]byte(files["index.css.map"]), &m))
+
+	out := files["index.css"]
+	source, _ := sourceAt(t, out, m, "*{")
+	assert.Equal(t, "../testdata/layers/reset.css", source)
+	source, _ = sourceAt(t, out, m, ".title{color:red}")
+	assert.Equal(t, "../testdata/layers/base.css", source)
+	source, _ = sourceAt(t, out, m, ".title{color:blue}")
+	assert.Equal(t, "../testdata/layers/index.css", source)
+}
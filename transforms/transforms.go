@@ -9,8 +9,8 @@ const (
 	// ImportRulesFollow passes @imports down without changes. It also follows import specifiers
 	// and adds them to the compilation output.
 	ImportRulesFollow ImportRules = iota
-	// ImportRulesInline inlines imported content where an @import statement is seen. In this
-	// version, it ignores @supports rules and meedia queries.
+	// ImportRulesInline inlines imported content where an @import statement is seen. Import
+	// conditions are kept by wrapping the content in equivalent @media, @supports, and @layer blocks.
 	ImportRulesInline
 )
 
@@ -20,8 +20,12 @@ const (
 type MediaFeatureRanges int
 
 const (
-	// MediaFeatureRangesPassthrough passes @imports down without changes. It is the default.
-	MediaFeatureRangesPassthrough MediaFeatureRanges = iota
+	// MediaFeatureRangesAuto uses MediaFeatureRangesTransform if any browser in Options.Targets doesn't
+	// support ranges, and passes them through otherwise, including when Targets is not set. It is the
+	// default.
+	MediaFeatureRangesAuto MediaFeatureRanges = iota
+	// MediaFeatureRangesPassthrough passes @imports down without changes.
+	MediaFeatureRangesPassthrough
 	// MediaFeatureRangesTransform transforms ranges into best-effort min- and max- values. When
 	// > and < are used, we follow the guidance from https://www.w3.org/TR/mediaqueries-5/#mq-min-max and
 	// use min/max with a change in .001 precision.
@@ -34,8 +38,11 @@ const (
 type AnyLink int
 
 const (
-	// AnyLinkPassthrough passes :any-link down without changes. It is the default.
-	AnyLinkPassthrough AnyLink = iota
+	// AnyLinkAuto uses AnyLinkTransform if any browser in Options.Targets doesn't support :any-link
+	// selectors, and passes them through otherwise, including when Targets is not set. It is the default.
+	AnyLinkAuto AnyLink = iota
+	// AnyLinkPassthrough passes :any-link down without changes.
+	AnyLinkPassthrough
 	// AnyLinkTransform transforms :any-link selectors into selectors for both :visited and :link.
 	AnyLinkTransform
 )
@@ -46,11 +53,23 @@ const (
 type CustomProperties int
 
 const (
-	// CustomPropertiesPassthrough passes variable declarations and var() down without changes. It is the default.
-	CustomPropertiesPassthrough CustomProperties = iota
+	// CustomPropertiesAuto uses CustomPropertiesTransformPreserve if any browser in Options.Targets
+	// doesn't support custom properties, and passes them through otherwise, including when Targets is not
+	// set. It is the default.
+	CustomPropertiesAuto CustomProperties = iota
+	// CustomPropertiesPassthrough passes variable declarations and var() down without changes.
+	CustomPropertiesPassthrough
 	// CustomPropertiesTransformRoot will transform properties defiend in :root selectors. Custom property definitions
 	// under any other selectors will be ignored and passed through.
 	CustomPropertiesTransformRoot
+	// CustomPropertiesTransform substitutes var() wherever the value is known. A custom property's value is known
+	// if it is defined exactly once, outside of any conditional rule, and either on :root or on a selector that the
+	// var() usage's rule is guaranteed to be inside of, e.g. .a for .a .b. Custom properties that are part of a
+	// reference cycle use their var() fallback. Custom property definitions are passed through.
+	CustomPropertiesTransform
+	// CustomPropertiesTransformPreserve is like CustomPropertiesTransform, but keeps the original declaration
+	// after the substituted one, so that browsers that support custom properties still use var().
+	CustomPropertiesTransformPreserve
 )
 
 // CustomMediaQueries controls transform options for @custom-media usage, specified in CSS Media Queries Level 5.
@@ -58,8 +77,12 @@ const (
 type CustomMediaQueries int
 
 const (
-	// CustomMediaQueriesPassthrough passes custom media query definitions and usages through. It is the default.
-	CustomMediaQueriesPassthrough CustomMediaQueries = iota
+	// CustomMediaQueriesAuto uses CustomMediaQueriesTransform if any browser in Options.Targets doesn't
+	// support custom media queries, and passes them through otherwise, including when Targets is not set.
+	// It is the default.
+	CustomMediaQueriesAuto CustomMediaQueries = iota
+	// CustomMediaQueriesPassthrough passes custom media query definitions and usages through.
+	CustomMediaQueriesPassthrough
 	// CustomMediaQueriesTransform will transform custom media queries when used in @media rules.
 	CustomMediaQueriesTransform
 )
@@ -70,15 +93,87 @@ const (
 type CalcReduction int
 
 const (
-	// CalcReductionPassthrough passes through any math functions. It is the default.
-	CalcReductionPassthrough CalcReduction = iota
-	// CalcReductionReduce will attempt to reduce all math functions. If the call cannot be fully reduced, it
-	// will be left.
+	// CalcReductionAuto uses CalcReductionReduce if any browser in Options.Targets doesn't support math
+	// functions, and passes them through otherwise, including when Targets is not set. It is the default.
+	CalcReductionAuto CalcReduction = iota
+	// CalcReductionPassthrough passes through any math functions.
+	CalcReductionPassthrough
+	// CalcReductionReduce will attempt to reduce all math functions. Absolute lengths, angles, times, frequencies, and
+	// resolutions are converted between units as needed. If the call cannot be fully reduced, it will be reduced as
+	// much as possible, e.g. calc(100% - 10px - 5px) becomes calc(100% - 15px).
 	CalcReductionReduce
 )
 
-// Options sets options about what transforms to run. By default,
-// no transforms are run.
+// Nesting controls transform options for nested style rules, specified in CSS Nesting.
+// See: https://www.w3.org/TR/css-nesting-1/.
+type Nesting int
+
+const (
+	// NestingAuto uses NestingTransform if any browser in Options.Targets doesn't support nested rules,
+	// and passes them through otherwise, including when Targets is not set. It is the default.
+	NestingAuto Nesting = iota
+	// NestingPassthrough passes nested rules through without changes.
+	NestingPassthrough
+	// NestingTransform flattens nested rules into top-level rules. The & selector is replaced with
+	// the parent selector, wrapped in :is() when a direct substitution would change the meaning or
+	// specificity of the selector. Nested conditional rules like @media are hoisted around the rule.
+	NestingTransform
+)
+
+// CascadeLayers controls transform options for @layer rules, specified in CSS Cascade Level 5.
+// See: https://www.w3.org/TR/css-cascade-5/#layering.
+type CascadeLayers int
+
+const (
+	// CascadeLayersAuto uses CascadeLayersTransform if any browser in Options.Targets doesn't support
+	// @layer rules, and passes them through otherwise, including when Targets is not set. It is the
+	// default.
+	CascadeLayersAuto CascadeLayers = iota
+	// CascadeLayersPassthrough passes @layer rules through without changes.
+	CascadeLayersPassthrough
+	// CascadeLayersTransform removes @layer rules and reorders layered rules so that they appear in
+	// layer order, followed by unlayered rules. This preserves precedence between layers when selectors
+	// have equal specificity, but cannot emulate layers beating higher specificity selectors or the
+	// reversed order of !important declarations.
+	CascadeLayersTransform
+)
+
+// ColorFunctions controls transform options for colors from CSS Color 4 and 5, e.g. rgb(0 0 0 / 50%),
+// oklch(), or color-mix().
+// See: https://www.w3.org/TR/css-color-4/.
+type ColorFunctions int
+
+const (
+	// ColorFunctionsAuto uses ColorFunctionsTransform if any browser in Options.Targets doesn't support
+	// colors, and passes them through otherwise, including when Targets is not set. It is the default.
+	ColorFunctionsAuto ColorFunctions = iota
+	// ColorFunctionsPassthrough passes colors through without changes.
+	ColorFunctionsPassthrough
+	// ColorFunctionsTransform converts colors that use newer syntax or color spaces to hex colors, or rgba()
+	// for colors with alpha. Colors outside of sRGB are gamut mapped. Colors that use var() or other values
+	// that aren't known ahead of time are passed through.
+	ColorFunctionsTransform
+)
+
+// Prefixer controls adding and removing vendor prefixes, e.g. -webkit-user-select, for the
+// browsers in Options.Targets.
+type Prefixer int
+
+const (
+	// PrefixerAuto uses PrefixerTransform if Options.Targets is set, and PrefixerPassthrough otherwise.
+	// It is the default.
+	PrefixerAuto Prefixer = iota
+	// PrefixerPassthrough passes prefixed and unprefixed features through without changes.
+	PrefixerPassthrough
+	// PrefixerTransform adds prefixed declarations, values, selectors, and at-rules before the unprefixed
+	// ones when a browser in Targets needs them, e.g. position: -webkit-sticky or ::-moz-selection. Prefixed
+	// forms that no browser in Targets needs are removed.
+	PrefixerTransform
+)
+
+// Options sets options about what transforms to run. By default, transforms are chosen
+// based on which features the browsers in Targets support, and no transforms are run if
+// Targets is not set. Setting a transform explicitly overrides the choice for Targets.
 type Options struct {
 	ImportRules
 	MediaFeatureRanges
@@ -86,4 +181,15 @@ type Options struct {
 	CustomProperties
 	CustomMediaQueries
 	CalcReduction
+	Nesting
+	CascadeLayers
+	ColorFunctions
+	Prefixer
+
+	// Targets is a browserslist-style query for the browsers to support, e.g. "chrome 80, safari >= 13"
+	// or "last 2 versions". It is used by the prefixer and to choose transforms that are not set.
+	// Queries that depend on usage statistics, like "> 1%", are not supported. Targets are resolved
+	// against a table of browser releases and features that is built into cssc, so updating cssc can
+	// change the output for the same targets.
+	Targets string
 }
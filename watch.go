@@ -0,0 +1,248 @@
+package cssc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/samsarahq/go/oops"
+	"github.com/stephen/cssc/internal/ast"
+	"github.com/stephen/cssc/internal/sources"
+)
+
+// watchInterval is how often Watch checks files for changes.
+var watchInterval = 100 * time.Millisecond
+
+// Watch runs a compilation with the specified Options, then recompiles whenever a
+// file in the compilation changes. onRebuild is called with the result of every
+// compilation, including the first. Watch returns immediately; call stop to stop
+// watching. onRebuild is not called after stop returns, and stop can be called more
+// than once.
+//
+// Files are checked for changes by polling. Only files that changed are parsed
+// again. Resolved imports are cached until a file is added to or removed from a
+// directory that contains a source or that an import was resolved from.
+func Watch(opts Options, onRebuild func(*Result)) (stop func()) {
+	cache := newBuildCache()
+	if opts.Resolver != nil {
+		cache.resolver.Resolver = opts.Resolver
+	}
+	opts.Resolver = cache.resolver
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+
+		for changed := true; ; {
+			if changed {
+				onRebuild(compile(opts, cache))
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				changed = cache.invalidate()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}
+}
+
+// buildCache holds the sources, parsed stylesheets, and resolved imports from a
+// previous compilation so that they can be reused.
+type buildCache struct {
+	mu sync.Mutex
+
+	// files is the cached state of every file read by a compilation, keyed by absolute path.
+	files map[string]*cachedFile
+
+	// dirs is the modification time of every directory that sources were read or imports
+	// were resolved from.
+	dirs map[string]time.Time
+
+	resolver *cachingResolver
+}
+
+// cachedFile is a file that has been read in.
+type cachedFile struct {
+	modTime time.Time
+	size    int64
+
+	source *sources.Source
+
+	// ast is the stylesheet parsed from source. Transforms run on copies of it, so it
+	// can be used by later compilations.
+	ast *ast.Stylesheet
+}
+
+func newBuildCache() *buildCache {
+	b := &buildCache{
+		files: make(map[string]*cachedFile),
+		dirs:  make(map[string]time.Time),
+	}
+	b.resolver = &cachingResolver{Resolver: defaultResolver(), cache: b, resolved: make(map[resolveKey]string)}
+	return b
+}
+
+// readSource reads the file at path, or returns the cached source if the file hasn't changed.
+func (b *buildCache) readSource(path string) (*sources.Source, error) {
+	b.watchDir(filepath.Dir(path))
+
+	b.mu.Lock()
+	f, ok := b.files[path]
+	b.mu.Unlock()
+	if ok {
+		return f.source, nil
+	}
+
+	// Stat before reading, so that a write in between is picked up by the next check.
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to read file: %s", path)
+	}
+
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, oops.Wrapf(err, "failed to read file: %s", path)
+	}
+
+	source := &sources.Source{
+		Content: string(in),
+		Path:    path,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.files[path] = &cachedFile{modTime: info.ModTime(), size: info.Size(), source: source}
+	return source, nil
+}
+
+// ast returns the cached stylesheet for path, if it has been parsed.
+func (b *buildCache) ast(path string) *ast.Stylesheet {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if f, ok := b.files[path]; ok {
+		return f.ast
+	}
+	return nil
+}
+
+// setAST caches the stylesheet parsed from the source at path.
+func (b *buildCache) setAST(path string, ss *ast.Stylesheet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if f, ok := b.files[path]; ok {
+		f.ast = ss
+	}
+}
+
+// watchDir starts tracking changes to the entries of dir.
+func (b *buildCache) watchDir(dir string) {
+	b.mu.Lock()
+	_, ok := b.dirs[dir]
+	b.mu.Unlock()
+	if ok {
+		return
+	}
+
+	// A missing directory is tracked with a zero time, so that its creation is a change.
+	var modTime time.Time
+	if info, err := os.Stat(dir); err == nil {
+		modTime = info.ModTime()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.dirs[dir]; !ok {
+		b.dirs[dir] = modTime
+	}
+}
+
+// invalidate removes changed files from the cache and returns whether or not anything
+// changed since the last compilation. If a directory changed, all resolved imports are
+// removed from the cache.
+func (b *buildCache) invalidate() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changed := false
+	for path, f := range b.files {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(f.modTime) || info.Size() != f.size {
+			delete(b.files, path)
+			changed = true
+		}
+	}
+
+	for dir, modTime := range b.dirs {
+		var current time.Time
+		if info, err := os.Stat(dir); err == nil {
+			current = info.ModTime()
+		}
+
+		if !current.Equal(modTime) {
+			b.dirs = make(map[string]time.Time)
+			b.resolver.reset()
+			changed = true
+			break
+		}
+	}
+
+	return changed
+}
+
+// resolveKey is the arguments to Resolver.Resolve.
+type resolveKey struct {
+	spec, fromDir string
+}
+
+// cachingResolver is a Resolver that caches successful resolutions.
+type cachingResolver struct {
+	Resolver
+
+	cache *buildCache
+
+	mu       sync.Mutex
+	resolved map[resolveKey]string
+}
+
+// Resolve implements Resolver.
+func (r *cachingResolver) Resolve(spec, fromDir string) (string, error) {
+	r.cache.watchDir(fromDir)
+
+	key := resolveKey{spec, fromDir}
+	r.mu.Lock()
+	path, ok := r.resolved[key]
+	r.mu.Unlock()
+	if ok {
+		return path, nil
+	}
+
+	path, err := r.Resolver.Resolve(spec, fromDir)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resolved[key] = path
+	return path, nil
+}
+
+// reset removes all cached resolutions.
+func (r *cachingResolver) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resolved = make(map[resolveKey]string)
+}
@@ -0,0 +1,83 @@
+package cssc_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stephen/cssc"
+	"github.com/stephen/cssc/transforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+
+	// Move the modification time forward, in case the filesystem's timestamps
+	// are too coarse to notice the write.
+	later := time.Now().Add(time.Duration(len(content)) * time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+}
+
+func nextResult(t *testing.T, results <-chan *cssc.Result) *cssc.Result {
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for rebuild")
+		return nil
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cssc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	index := filepath.Join(dir, "index.css")
+	writeFile(t, index, `@import "./a.css"; @import "./b.css"; .index { color: red }`)
+	writeFile(t, filepath.Join(dir, "a.css"), `.a { color: red }`)
+
+	var errors TestReporter
+	results, done := make(chan *cssc.Result), make(chan struct{})
+	stop := cssc.Watch(cssc.Options{
+		Entry: []string{index},
+		Transforms: transforms.Options{
+			ImportRules: transforms.ImportRulesInline,
+		},
+		Reporter: &errors,
+	}, func(result *cssc.Result) {
+		// If the test fails before reading a result, stop must not wait on this send.
+		select {
+		case results <- result:
+		case <-done:
+		}
+	})
+	defer stop()
+	defer close(done)
+
+	// b.css doesn't exist yet, so the import fails to resolve.
+	assert.Equal(t, `@import "./b.css";.a{color:red}.index{color:red}`, nextResult(t, results).Files[index])
+	assert.Len(t, errors, 1)
+
+	writeFile(t, filepath.Join(dir, "a.css"), `.a { color: blue }`)
+	assert.Equal(t, `@import "./b.css";.a{color:blue}.index{color:red}`, nextResult(t, results).Files[index])
+
+	// Adding b.css resolves the import.
+	writeFile(t, filepath.Join(dir, "b.css"), `.b { color: green }`)
+	assert.Equal(t, `.a{color:blue}.b{color:green}.index{color:red}`, nextResult(t, results).Files[index])
+
+	writeFile(t, index, `@import "./b.css"; .index { color: blue }`)
+	assert.Equal(t, `.b{color:green}.index{color:blue}`, nextResult(t, results).Files[index])
+
+	stop()
+	writeFile(t, index, `.index { color: green }`)
+	select {
+	case <-results:
+		assert.Fail(t, "rebuilt after stop")
+	case <-time.After(300 * time.Millisecond):
+	}
+}